@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"io/ioutil"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/spf13/cobra"
+)
+
+var ExportCmd = &cobra.Command{
+	Use:   "export [profile_id] [tar_path]",
+	Short: "Export profile without saved credentials",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			cobra.CheckErr("cmd: Missing profile ID or path")
+		}
+
+		sprfl, err := sprofile.Match(args[0])
+		cobra.CheckErr(err)
+
+		data, err := sprfl.Export()
+		cobra.CheckErr(err)
+
+		err = ioutil.WriteFile(args[1], data, 0600)
+		if err != nil {
+			cobra.CheckErr(errors.Wrap(err, "cmd: Failed to write export"))
+		}
+	},
+}
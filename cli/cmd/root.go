@@ -27,11 +27,13 @@ func init() {
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.AddCommand(AddCmd)
 	RootCmd.AddCommand(RemoveCmd)
+	RootCmd.AddCommand(ExportCmd)
 	RootCmd.AddCommand(EnableCmd)
 	RootCmd.AddCommand(DisableCmd)
 	RootCmd.AddCommand(LogsCmd)
 	RootCmd.AddCommand(ListCmd)
 	RootCmd.AddCommand(StartCmd)
 	RootCmd.AddCommand(StopCmd)
+	RootCmd.AddCommand(StatusCmd)
 	RootCmd.AddCommand(WatchCmd)
 }
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+	"github.com/pritunl/pritunl-client-electron/cli/service"
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/spf13/cobra"
+)
+
+type Status struct {
+	Connected bool
+	Profiles  []*Profile
+}
+
+var StatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show connection status",
+	Run: func(cmd *cobra.Command, args []string) {
+		if diagnostics {
+			output, err := service.GetDiagnostics()
+			cobra.CheckErr(err)
+
+			fmt.Println(output)
+			return
+		}
+
+		status, err := service.GetStatus()
+		cobra.CheckErr(err)
+
+		sprfls, err := sprofile.GetAll()
+		cobra.CheckErr(err)
+
+		prfls := []*Profile{}
+		for _, sprfl := range sprfls {
+			if sprfl.Profile == nil {
+				continue
+			}
+
+			prfls = append(prfls, &Profile{
+				Id:            sprfl.Id,
+				Name:          sprfl.FormatedName(),
+				State:         sprfl.FormatedState(),
+				RunState:      sprfl.FormatedRunState(),
+				Connected:     sprfl.Profile.Status == "connected",
+				Uptime:        sprfl.Profile.Uptime(),
+				Status:        sprfl.Profile.FormatedTime(),
+				ServerAddress: sprfl.Profile.ServerAddr,
+				ClientAddress: sprfl.Profile.ClientAddr,
+			})
+		}
+
+		if jsonFormat || jsonFormated {
+			data := &Status{
+				Connected: status.Status,
+				Profiles:  prfls,
+			}
+
+			var output []byte
+			if jsonFormated {
+				output, err = json.MarshalIndent(data, "", "  ")
+			} else {
+				output, err = json.Marshal(data)
+			}
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "cmd: Failed to marshal status"),
+				}
+				cobra.CheckErr(err)
+			}
+
+			fmt.Println(string(output))
+			return
+		}
+
+		if status.Status {
+			fmt.Println("Connected")
+		} else {
+			fmt.Println("Disconnected")
+		}
+
+		if len(prfls) == 0 {
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{
+			"ID",
+			"Name",
+			"Online For",
+			"Server Address",
+			"Client Address",
+		})
+		table.SetBorder(true)
+
+		for _, prfl := range prfls {
+			table.Append([]string{
+				prfl.Id,
+				prfl.Name,
+				prfl.Status,
+				prfl.ServerAddress,
+				prfl.ClientAddress,
+			})
+		}
+
+		table.Render()
+	},
+}
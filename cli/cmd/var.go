@@ -6,6 +6,7 @@ var (
 	passwordPrompt bool
 	jsonFormat     bool
 	jsonFormated   bool
+	diagnostics    bool
 )
 
 func init() {
@@ -46,4 +47,28 @@ func init() {
 		false,
 		"Format output in indented JSON",
 	)
+
+	StatusCmd.Flags().BoolVarP(
+		&jsonFormat,
+		"json",
+		"j",
+		false,
+		"Format output in JSON",
+	)
+
+	StatusCmd.Flags().BoolVarP(
+		&jsonFormated,
+		"json-formatted",
+		"f",
+		false,
+		"Format output in indented JSON",
+	)
+
+	StatusCmd.Flags().BoolVarP(
+		&diagnostics,
+		"diagnostics",
+		"d",
+		false,
+		"Show service diagnostics",
+	)
 }
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+)
+
+func GetDiagnostics() (output string, err error) {
+	reqUrl := GetAddress() + "/diagnostics"
+
+	authKey, err := GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "service: Get request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "service: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("service: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = errortypes.ReadError{
+			errors.Wrap(err, "service: Failed to read response"),
+		}
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	err = json.Indent(buf, body, "", "  ")
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "service: Failed to parse response"),
+		}
+		return
+	}
+
+	output = buf.String()
+
+	return
+}
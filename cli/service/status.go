@@ -0,0 +1,65 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+)
+
+type Status struct {
+	Status bool `json:"status"`
+}
+
+func GetStatus() (status *Status, err error) {
+	reqUrl := GetAddress() + "/status"
+
+	authKey, err := GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "service: Get request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "service: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("service: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	status = &Status{}
+	err = json.NewDecoder(resp.Body).Decode(status)
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "service: Failed to parse response"),
+		}
+		return
+	}
+
+	return
+}
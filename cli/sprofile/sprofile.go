@@ -13,6 +13,12 @@ import (
 	"github.com/pritunl/pritunl-client-electron/cli/service"
 )
 
+type CustomRoute struct {
+	Network   string `json:"network"`
+	Metric    int    `json:"metric"`
+	ViaTunnel bool   `json:"via_tunnel"`
+}
+
 type Sprofile struct {
 	Id                 string           `json:"id"`
 	Name               string           `json:"name"`
@@ -28,6 +34,10 @@ type Sprofile struct {
 	PreConnectMsg      string           `json:"pre_connect_msg"`
 	DynamicFirewall    bool             `json:"dynamic_firewall"`
 	DisableGateway     bool             `json:"disable_gateway"`
+	ExcludeRoutes      []string         `json:"exclude_routes"`
+	CustomRoutes       []*CustomRoute   `json:"custom_routes"`
+	KillSwitch         bool             `json:"kill_switch"`
+	BlockIpv6          bool             `json:"block_ipv6"`
 	SsoAuth            bool             `json:"sso_auth"`
 	PasswordMode       string           `json:"password_mode"`
 	Token              bool             `json:"token"`
@@ -37,6 +47,9 @@ type Sprofile struct {
 	SyncHash           string           `json:"sync_hash"`
 	SyncSecret         string           `json:"sync_secret"`
 	SyncToken          string           `json:"sync_token"`
+	ImportUrl          string           `json:"import_url"`
+	ImportName         string           `json:"import_name"`
+	Stale              bool             `json:"stale"`
 	ServerPublicKey    []string         `json:"server_public_key"`
 	ServerBoxPublicKey string           `json:"server_box_public_key"`
 	OvpnData           string           `json:"ovpn_data"`
@@ -123,3 +136,53 @@ func (s *Sprofile) GetLogs() (data string, err error) {
 
 	return
 }
+
+func (s *Sprofile) Export() (data []byte, err error) {
+	reqUrl := service.GetAddress() + "/sprofile/" + s.Id + "/export"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Get request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Wrapf(err, "sprofile: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read response"),
+		}
+		return
+	}
+
+	return
+}
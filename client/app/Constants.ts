@@ -64,10 +64,16 @@ export const dataPath = args.get('dataPath');
 
 export let state: State = {}
 
+export interface RestoreState {
+	id?: string
+	mode?: string
+}
+
 export interface State {
 	wg?: boolean
 	version?: string
 	upgrade?: boolean
+	restore?: RestoreState[]
 }
 
 function syncState(): void {
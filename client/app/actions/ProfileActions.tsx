@@ -5,6 +5,7 @@ import * as React from "react"
 import Dispatcher from '../dispatcher/Dispatcher';
 import EventDispatcher from '../dispatcher/EventDispatcher';
 import * as Alert from '../Alert';
+import * as Constants from '../Constants';
 import * as Paths from '../Paths';
 import Loader from '../Loader';
 import * as ProfileTypes from '../types/ProfileTypes';
@@ -334,6 +335,20 @@ export function commit(prfl: ProfileTypes.Profile): Promise<void> {
 	})
 }
 
+Constants.addChangeListener((): void => {
+	for (let restore of (Constants.state.restore || [])) {
+		let prfl = ProfilesStore.profile(restore.id)
+		if (prfl) {
+			Alert.warning("Connection to " + prfl.formattedName() +
+				" was interrupted by a service restart, connect again to " +
+				"reconnect", 0)
+		} else {
+			Alert.warning("A connection was interrupted by a service " +
+				"restart, connect again to reconnect", 0)
+		}
+	}
+})
+
 EventDispatcher.register((action: ProfileTypes.ProfileDispatch) => {
 	switch (action.type) {
 		case "update":
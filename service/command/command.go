@@ -0,0 +1,57 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+)
+
+func Run(ctx context.Context, cmd *exec.Cmd) (err error) {
+	prepare(cmd)
+
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+
+	tree, err := attach(cmd)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return
+	}
+	defer tree.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			tree.Kill()
+		case <-done:
+		}
+	}()
+
+	err = cmd.Wait()
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	return
+}
+
+func Output(ctx context.Context, name string, arg ...string) (
+	output []byte, err error) {
+
+	buf := &bytes.Buffer{}
+
+	cmd := CommandContext(ctx, name, arg...)
+	cmd.Stdout = buf
+	cmd.Stderr = buf
+
+	err = Run(ctx, cmd)
+	output = buf.Bytes()
+
+	return
+}
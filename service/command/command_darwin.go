@@ -1,10 +1,48 @@
 package command
 
 import (
+	"context"
 	"os/exec"
+	"syscall"
 )
 
 func Command(name string, arg ...string) *exec.Cmd {
 	cmd := exec.Command(name, arg...)
 	return cmd
 }
+
+func CommandContext(ctx context.Context, name string,
+	arg ...string) *exec.Cmd {
+
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	return cmd
+}
+
+type processTree struct {
+	pid int
+}
+
+func (t *processTree) Kill() {
+	_ = syscall.Kill(-t.pid, syscall.SIGKILL)
+}
+
+func (t *processTree) Close() {
+}
+
+// Start every command in its own process group so the tree can be killed
+func prepare(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func attach(cmd *exec.Cmd) (tree *processTree, err error) {
+	tree = &processTree{
+		pid: cmd.Process.Pid,
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func processAlive(pid int) bool {
+	data, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+
+	// Killed processes may remain as zombies until reaped
+	fields := strings.Fields(string(data))
+	return len(fields) > 2 && fields[2] != "Z"
+}
+
+func TestRunKillsTree(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		300*time.Millisecond)
+	defer cancel()
+
+	output := &bytes.Buffer{}
+
+	cmd := Command("/bin/sh", "-c", "sleep 5 & echo $!; wait")
+	cmd.Stdout = output
+
+	start := time.Now()
+	err := Run(ctx, cmd)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("run waited for the grandchild to exit")
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(output.String()))
+	if err != nil {
+		t.Fatalf("failed to read grandchild pid %q", output.String())
+	}
+
+	for i := 0; i < 20 && processAlive(pid); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if processAlive(pid) {
+		t.Errorf("grandchild %d still running", pid)
+	}
+}
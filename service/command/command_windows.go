@@ -1,8 +1,12 @@
 package command
 
 import (
+	"context"
 	"os/exec"
 	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
 func Command(name string, arg ...string) *exec.Cmd {
@@ -12,3 +16,110 @@ func Command(name string, arg ...string) *exec.Cmd {
 	}
 	return cmd
 }
+
+func CommandContext(ctx context.Context, name string,
+	arg ...string) *exec.Cmd {
+
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true,
+	}
+	return cmd
+}
+
+type processTree struct {
+	job windows.Handle
+}
+
+func (t *processTree) Kill() {
+	_ = windows.TerminateJobObject(t.job, 1)
+}
+
+func (t *processTree) Close() {
+	_ = windows.CloseHandle(t.job)
+}
+
+// Processes are started suspended so they can be assigned to the job before
+// any child processes are created
+func prepare(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			HideWindow: true,
+		}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+}
+
+func resume(pid uint32) (err error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(
+		windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(snapshot)
+
+	entry := windows.ThreadEntry32{}
+	entry.Size = uint32(unsafe.Sizeof(entry))
+
+	err = windows.Thread32First(snapshot, &entry)
+	for err == nil {
+		if entry.OwnerProcessID == pid {
+			thread, e := windows.OpenThread(
+				windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+			if e != nil {
+				err = e
+				return
+			}
+
+			_, e = windows.ResumeThread(thread)
+			_ = windows.CloseHandle(thread)
+			if e != nil {
+				err = e
+				return
+			}
+		}
+
+		err = windows.Thread32Next(snapshot, &entry)
+	}
+
+	if err == windows.ERROR_NO_MORE_FILES {
+		err = nil
+	}
+
+	return
+}
+
+func attach(cmd *exec.Cmd) (tree *processTree, err error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return
+	}
+
+	proc, err := windows.OpenProcess(
+		windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE,
+		false,
+		uint32(cmd.Process.Pid),
+	)
+	if err != nil {
+		_ = windows.CloseHandle(job)
+		return
+	}
+	defer windows.CloseHandle(proc)
+
+	err = windows.AssignProcessToJobObject(job, proc)
+	if err != nil {
+		_ = windows.CloseHandle(job)
+		return
+	}
+
+	err = resume(uint32(cmd.Process.Pid))
+	if err != nil {
+		_ = windows.CloseHandle(job)
+		return
+	}
+
+	tree = &processTree{
+		job: job,
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package command
+
+import (
+	"os/exec"
+	"strings"
+)
+
+const Redacted = "***"
+
+var (
+	sensitiveFlags = map[string]bool{
+		"private-key":   true,
+		"preshared-key": true,
+		"--auth-token":  true,
+		"--password":    true,
+		"-password":     true,
+		"-passin":       true,
+		"-passout":      true,
+	}
+	sensitivePrefixes = []string{
+		"password=",
+		"pass:",
+		"secret=",
+		"token=",
+		"PrivateKey=",
+	}
+)
+
+type Cmd struct {
+	*exec.Cmd
+	sensitive map[int]bool
+}
+
+func (c *Cmd) Sensitive(indexes ...int) *Cmd {
+	if c.sensitive == nil {
+		c.sensitive = map[int]bool{}
+	}
+	for _, index := range indexes {
+		c.sensitive[index] = true
+	}
+	return c
+}
+
+func redactArg(prev, arg string) string {
+	if sensitiveFlags[prev] {
+		return Redacted
+	}
+
+	for _, prefix := range sensitivePrefixes {
+		if strings.HasPrefix(arg, prefix) {
+			return prefix + Redacted
+		}
+	}
+
+	return arg
+}
+
+// Redact values that follow known secret flags or use a secret prefix
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		prev := ""
+		if i > 0 {
+			prev = args[i-1]
+		}
+		redacted[i] = redactArg(prev, arg)
+	}
+
+	return redacted
+}
+
+func (c *Cmd) RedactedArgs() []string {
+	if len(c.Args) < 2 {
+		return []string{}
+	}
+
+	args := RedactArgs(c.Args[1:])
+	for i := range args {
+		if c.sensitive[i] {
+			args[i] = Redacted
+		}
+	}
+
+	return args
+}
+
+func (c *Cmd) String() string {
+	return strings.Join(append([]string{c.Path}, c.RedactedArgs()...), " ")
+}
+
+func New(name string, arg ...string) *Cmd {
+	return &Cmd{
+		Cmd: Command(name, arg...),
+	}
+}
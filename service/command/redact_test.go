@@ -0,0 +1,59 @@
+package command
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSensitiveRedacted(t *testing.T) {
+	cmd := New("sc.exe", "config", "pritunl", "obj=user",
+		"secretvalue").Sensitive(3)
+
+	args := cmd.RedactedArgs()
+	if args[3] != Redacted {
+		t.Errorf("expected redacted arg, got %q", args[3])
+	}
+	if args[0] != "config" || args[2] != "obj=user" {
+		t.Errorf("unexpected redacted args %v", args)
+	}
+
+	if cmd.Args[4] != "secretvalue" {
+		t.Errorf("exec argv modified %v", cmd.Args)
+	}
+	if strings.Contains(cmd.String(), "secretvalue") {
+		t.Errorf("secret in command string %q", cmd.String())
+	}
+}
+
+func TestRedactPatterns(t *testing.T) {
+	args := RedactArgs([]string{
+		"set", "wg0", "private-key", "/tmp/key",
+		"peer", "pubkey", "password=hunter2",
+	})
+
+	expected := []string{
+		"set", "wg0", "private-key", Redacted,
+		"peer", "pubkey", "password=" + Redacted,
+	}
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Errorf("arg %d expected %q got %q", i, expected[i], args[i])
+		}
+	}
+}
+
+func TestRedactExec(t *testing.T) {
+	cmd := New("echo", "--password", "hunter2")
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Skip("echo not available")
+	}
+
+	if strings.TrimSpace(string(output)) != "--password hunter2" {
+		t.Errorf("exec received wrong args %q", string(output))
+	}
+	if strings.Contains(cmd.String(), "hunter2") {
+		t.Errorf("secret in command string %q", cmd.String())
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+var Config = &ConfigData{}
+
+type ConfigData struct {
+	AllowUserScripts    bool                `json:"allow_user_scripts"`
+	AllowedUids         []int               `json:"allowed_uids"`
+	ServerPins          map[string][]string `json:"server_pins"`
+	ServerSyncInterval  int                 `json:"server_sync_interval"`
+	EventWebhookUrl     string              `json:"event_webhook_url"`
+	EventWebhookTimeout int                 `json:"event_webhook_timeout"`
+	EventWebhookRetries int                 `json:"event_webhook_retries"`
+	OnDemandInterval    int                 `json:"on_demand_interval"`
+	TlsVersionMin       string              `json:"tls_version_min"`
+	DataCiphers         []string            `json:"data_ciphers"`
+	TlsCipher           string              `json:"tls_cipher"`
+}
+
+func Load() (err error) {
+	pth := utils.GetSettingsPath()
+
+	data, err := ioutil.ReadFile(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "config: Failed to read settings"),
+		}
+		return
+	}
+
+	conf := &ConfigData{}
+	err = json.Unmarshal(data, conf)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "config: Failed to parse settings"),
+		}
+		return
+	}
+
+	Config = conf
+
+	return
+}
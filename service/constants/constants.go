@@ -1,5 +1,9 @@
 package constants
 
+import (
+	"time"
+)
+
 const (
 	Version = "1.3.3420.31"
 )
@@ -7,4 +11,7 @@ const (
 var (
 	Development = false
 	Macos10     = false
+	StartTime   = time.Now()
+	Commit      = "unknown"
+	BuildDate   = "unknown"
 )
@@ -2,15 +2,20 @@
 package event
 
 import (
-	"github.com/dropbox/godropbox/container/set"
-	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
 )
 
 var (
 	LastAwake = time.Now()
 	LastPong  = time.Time{}
+	sequence  uint64
 	listeners = struct {
 		sync.RWMutex
 		s set.Set
@@ -20,17 +25,47 @@ var (
 )
 
 type Event struct {
-	Id   string      `json:"id"`
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Id        string          `json:"id"`
+	Sequence  uint64          `json:"sequence"`
+	Type      string          `json:"type"`
+	ProfileId string          `json:"profile_id,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+	Count     int             `json:"count,omitempty"`
 }
 
-func (e *Event) Init() {
+func (e *Event) Init(data interface{}) {
 	e.Id = utils.Uuid()
+	e.Timestamp = time.Now()
+
+	if data != nil {
+		dataByt, err := json.Marshal(data)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"type":  e.Type,
+				"error": err,
+			}).Error("event: Failed to marshal event data")
+		} else {
+			e.Data = dataByt
+		}
+	}
+	if e.Data == nil {
+		e.Data = json.RawMessage("null")
+	}
 
+	if !throttle(e) {
+		return
+	}
+
+	e.publish()
+}
+
+func (e *Event) publish() {
 	listeners.RLock()
 	defer listeners.RUnlock()
 
+	e.Sequence = atomic.AddUint64(&sequence, 1)
+
 	for listInf := range listeners.s.Iter() {
 		list := listInf.(*Listener)
 
@@ -41,4 +76,13 @@ func (e *Event) Init() {
 			list.stream <- e
 		}()
 	}
+
+	subscribers.RLock()
+	for sub := range subscribers.m {
+		select {
+		case sub <- *e:
+		default:
+		}
+	}
+	subscribers.RUnlock()
 }
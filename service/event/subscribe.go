@@ -0,0 +1,37 @@
+package event
+
+import (
+	"sync"
+)
+
+const subscriberBuffer = 64
+
+var (
+	subscribers = struct {
+		sync.RWMutex
+		m map[chan Event]struct{}
+	}{
+		m: map[chan Event]struct{}{},
+	}
+)
+
+func Subscribe() (stream <-chan Event, cancel func()) {
+	sub := make(chan Event, subscriberBuffer)
+
+	subscribers.Lock()
+	subscribers.m[sub] = struct{}{}
+	subscribers.Unlock()
+
+	once := sync.Once{}
+	stream = sub
+	cancel = func() {
+		once.Do(func() {
+			subscribers.Lock()
+			delete(subscribers.m, sub)
+			subscribers.Unlock()
+			close(sub)
+		})
+	}
+
+	return
+}
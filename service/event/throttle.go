@@ -0,0 +1,162 @@
+package event
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	throttleWindow = 5 * time.Second
+	throttleRate   = 10
+	throttlePeriod = 1 * time.Minute
+)
+
+var (
+	throttles = struct {
+		sync.Mutex
+		m      map[string]*throttleState
+		limits map[string]*throttleLimit
+	}{
+		m:      map[string]*throttleState{},
+		limits: map[string]*throttleLimit{},
+	}
+)
+
+type throttleState struct {
+	last  *Event
+	start time.Time
+	count int
+	timer *time.Timer
+}
+
+type throttleLimit struct {
+	sent []time.Time
+}
+
+func (s *throttleState) pending() (evt *Event) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if s.count == 0 || s.last == nil {
+		return
+	}
+
+	evt = &Event{
+		Id:        utils.Uuid(),
+		Type:      s.last.Type,
+		ProfileId: s.last.ProfileId,
+		Timestamp: time.Now(),
+		Data:      s.last.Data,
+		Count:     s.count,
+	}
+	s.count = 0
+
+	return
+}
+
+func (l *throttleLimit) allow(now time.Time) bool {
+	sent := []time.Time{}
+	for _, timestamp := range l.sent {
+		if now.Sub(timestamp) < throttlePeriod {
+			sent = append(sent, timestamp)
+		}
+	}
+	l.sent = sent
+
+	if len(l.sent) >= throttleRate {
+		return false
+	}
+
+	l.sent = append(l.sent, now)
+	return true
+}
+
+func throttled(typ string) bool {
+	return strings.HasSuffix(typ, "_error")
+}
+
+func throttleKey(typ, prflId string) string {
+	return typ + "\x00" + prflId
+}
+
+// Rate limit events per profile, must be called with throttles locked
+func throttleAllow(e *Event, now time.Time) bool {
+	limit := throttles.limits[e.ProfileId]
+	if limit == nil {
+		limit = &throttleLimit{}
+		throttles.limits[e.ProfileId] = limit
+	}
+
+	if !limit.allow(now) {
+		logrus.WithFields(logrus.Fields{
+			"type":       e.Type,
+			"profile_id": e.ProfileId,
+		}).Debug("event: Event rate limit exceeded, dropping event")
+		return false
+	}
+
+	return true
+}
+
+func throttleFlush(key string) {
+	throttles.Lock()
+	defer throttles.Unlock()
+
+	state := throttles.m[key]
+	if state == nil {
+		return
+	}
+
+	evt := state.pending()
+	if evt != nil && throttleAllow(evt, time.Now()) {
+		evt.publish()
+	}
+}
+
+func throttle(e *Event) bool {
+	if !throttled(e.Type) {
+		return true
+	}
+
+	throttles.Lock()
+	defer throttles.Unlock()
+
+	now := time.Now()
+	key := throttleKey(e.Type, e.ProfileId)
+
+	state := throttles.m[key]
+	if state == nil {
+		state = &throttleState{}
+		throttles.m[key] = state
+	} else if now.Sub(state.start) < throttleWindow {
+		state.count += 1
+		state.last = e
+
+		if state.timer == nil {
+			state.timer = time.AfterFunc(
+				throttleWindow-now.Sub(state.start),
+				func() {
+					throttleFlush(key)
+				},
+			)
+		}
+
+		return false
+	} else {
+		evt := state.pending()
+		if evt != nil && throttleAllow(evt, now) {
+			evt.publish()
+		}
+	}
+
+	state.last = e
+	state.start = now
+
+	return throttleAllow(e, now)
+}
@@ -0,0 +1,185 @@
+package event
+
+import (
+	"fmt"
+	"testing"
+)
+
+func resetThrottles(t *testing.T) {
+	reset := func() {
+		throttles.Lock()
+		for _, state := range throttles.m {
+			if state.timer != nil {
+				state.timer.Stop()
+			}
+		}
+		throttles.m = map[string]*throttleState{}
+		throttles.limits = map[string]*throttleLimit{}
+		throttles.Unlock()
+	}
+
+	reset()
+	t.Cleanup(reset)
+}
+
+func drain(stream <-chan Event) (evts []Event) {
+	for {
+		select {
+		case evt := <-stream:
+			evts = append(evts, evt)
+		default:
+			return
+		}
+	}
+}
+
+func TestThrottleCollapse(t *testing.T) {
+	resetThrottles(t)
+
+	stream, cancel := Subscribe()
+	defer cancel()
+
+	for i := 0; i < 100; i++ {
+		evt := &Event{
+			Type:      "auth_error",
+			ProfileId: "prfl0",
+		}
+		evt.Init(nil)
+
+		if i == 0 {
+			evts := drain(stream)
+			if len(evts) != 1 || evts[0].Count != 0 {
+				t.Fatalf("first event not delivered immediately %v", evts)
+			}
+		}
+	}
+
+	evts := drain(stream)
+	if len(evts) != 0 {
+		t.Fatalf("duplicate events delivered %v", evts)
+	}
+
+	throttleFlush(throttleKey("auth_error", "prfl0"))
+
+	evts = drain(stream)
+	if len(evts) != 1 {
+		t.Fatalf("expected one collapsed event, got %d", len(evts))
+	}
+	if evts[0].Type != "auth_error" || evts[0].ProfileId != "prfl0" {
+		t.Errorf("unexpected collapsed event %v", evts[0])
+	}
+	if evts[0].Count != 99 {
+		t.Errorf("expected collapsed count 99, got %d", evts[0].Count)
+	}
+
+	throttleFlush(throttleKey("auth_error", "prfl0"))
+	if evts = drain(stream); len(evts) != 0 {
+		t.Errorf("flush repeated collapsed event %v", evts)
+	}
+}
+
+func TestThrottleRate(t *testing.T) {
+	resetThrottles(t)
+
+	stream, cancel := Subscribe()
+	defer cancel()
+
+	for i := 0; i < 40; i++ {
+		evt := &Event{
+			Type:      fmt.Sprintf("test%d_error", i),
+			ProfileId: "prfl0",
+		}
+		evt.Init(nil)
+	}
+
+	evts := drain(stream)
+	if len(evts) != throttleRate {
+		t.Errorf("expected %d events, got %d", throttleRate, len(evts))
+	}
+
+	evt := &Event{
+		Type:      "auth_error",
+		ProfileId: "prfl1",
+	}
+	evt.Init(nil)
+
+	evt = &Event{
+		Type:      "update",
+		ProfileId: "prfl0",
+	}
+	evt.Init(nil)
+
+	evts = drain(stream)
+	if len(evts) != 2 {
+		t.Errorf("expected events for other profile and type, got %d",
+			len(evts))
+	}
+}
+
+func TestThrottleProfiles(t *testing.T) {
+	resetThrottles(t)
+
+	stream, cancel := Subscribe()
+	defer cancel()
+
+	for i := 0; i < 5; i++ {
+		for _, prflId := range []string{"prfl0", "prfl1"} {
+			evt := &Event{
+				Type:      "auth_error",
+				ProfileId: prflId,
+			}
+			evt.Init(nil)
+		}
+	}
+
+	evts := drain(stream)
+	if len(evts) != 2 {
+		t.Fatalf("expected one event per profile, got %d", len(evts))
+	}
+	if evts[0].ProfileId != "prfl0" || evts[1].ProfileId != "prfl1" {
+		t.Errorf("unexpected profile events %v", evts)
+	}
+
+	for _, prflId := range []string{"prfl0", "prfl1"} {
+		throttleFlush(throttleKey("auth_error", prflId))
+
+		evts = drain(stream)
+		if len(evts) != 1 {
+			t.Fatalf("expected one collapsed event for %s, got %d",
+				prflId, len(evts))
+		}
+		if evts[0].ProfileId != prflId || evts[0].Count != 4 {
+			t.Errorf("unexpected collapsed event %v", evts[0])
+		}
+	}
+}
+
+func TestThrottleFlushRate(t *testing.T) {
+	resetThrottles(t)
+
+	stream, cancel := Subscribe()
+	defer cancel()
+
+	for i := 0; i < throttleRate; i++ {
+		for j := 0; j < 2; j++ {
+			evt := &Event{
+				Type:      fmt.Sprintf("test%d_error", i),
+				ProfileId: "prfl0",
+			}
+			evt.Init(nil)
+		}
+	}
+
+	evts := drain(stream)
+	if len(evts) != throttleRate {
+		t.Fatalf("expected %d events, got %d", throttleRate, len(evts))
+	}
+
+	for i := 0; i < throttleRate; i++ {
+		throttleFlush(throttleKey(fmt.Sprintf("test%d_error", i), "prfl0"))
+	}
+
+	if evts = drain(stream); len(evts) != 0 {
+		t.Errorf("collapsed events bypassed rate limit %v", evts)
+	}
+}
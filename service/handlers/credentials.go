@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func credentialsGet(c *gin.Context) {
+	creds, err := profile.SavedCredentials()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, creds)
+}
+
+func credentialsDelete(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+
+	var err error
+	if prflId == "" {
+		err = profile.ClearAllCredentials()
+	} else {
+		err = profile.ClearCredentials(prflId)
+	}
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/tuntap"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type diagnosticsData struct {
+	Version     string                `json:"version"`
+	Commit      string                `json:"commit"`
+	BuildDate   string                `json:"build_date"`
+	GoVersion   string                `json:"go_version"`
+	Platform    string                `json:"platform"`
+	Uptime      int64                 `json:"uptime"`
+	Status      bool                  `json:"status"`
+	RootDir     string                `json:"root_dir"`
+	BinaryPath  string                `json:"binary_path"`
+	LogPath     string                `json:"log_path"`
+	TapPresent  bool                  `json:"tap_present"`
+	TapAdapters *tuntap.Inventory     `json:"tap_adapters,omitempty"`
+	Wg          bool                  `json:"wg"`
+	Profiles    []*profile.Diagnostic `json:"profiles"`
+	Connections []*profile.Connection `json:"connections"`
+}
+
+func tapPresent() bool {
+	switch runtime.GOOS {
+	case "windows":
+		adapters, err := tuntap.Get()
+		if err != nil {
+			return false
+		}
+		return len(adapters) > 0
+	case "linux":
+		exists, _ := utils.Exists("/dev/net/tun")
+		return exists
+	default:
+		return true
+	}
+}
+
+func diagnosticsGet(c *gin.Context) {
+	binaryPath, _ := os.Executable()
+
+	data := &diagnosticsData{
+		Version:     constants.Version,
+		Commit:      constants.Commit,
+		BuildDate:   constants.BuildDate,
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS,
+		Uptime:      int64(time.Since(constants.StartTime).Seconds()),
+		Status:      profile.GetStatus(),
+		RootDir:     utils.GetRootDir(),
+		BinaryPath:  binaryPath,
+		LogPath:     utils.GetLogPath(),
+		TapPresent:  tapPresent(),
+		Wg:          profile.GetWgPath() != "",
+		Profiles:    profile.GetDiagnostics(),
+		Connections: profile.Registry.All(),
+	}
+
+	if runtime.GOOS == "windows" {
+		data.TapAdapters, _ = tuntap.GetInventory()
+	}
+
+	c.JSON(200, data)
+}
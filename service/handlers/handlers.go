@@ -63,14 +63,20 @@ func Register(engine *gin.Engine) {
 	engine.POST("/profile", profilePost)
 	engine.DELETE("/profile", profileDel)
 	engine.DELETE("/profile/:profile_id", profileDel2)
+	engine.PUT("/profile/:profile_id/wg", profileWgPut)
 	engine.GET("/sprofile", sprofilesGet)
 	engine.PUT("/sprofile", sprofilePut)
+	engine.POST("/sprofile/server", sprofileServerPost)
+	engine.POST("/sprofile/import", sprofileImportPost)
 	engine.DELETE("/sprofile", sprofileDel)
 	engine.DELETE("/sprofile/:profile_id", sprofileDel2)
 	// TODO classic client
 	engine.GET("/sprofile/:profile_id/log", sprofileLogGet)
 	// TODO classic client
 	engine.DELETE("/sprofile/:profile_id/log", sprofileLogDel)
+	engine.GET("/sprofile/:profile_id/export", sprofileExportGet)
+	engine.POST("/sprofile/enroll", sprofileEnrollPost)
+	engine.DELETE("/sprofile/:profile_id/enroll", sprofileEnrollDel)
 	engine.GET("/log/:log_id", logGet)
 	engine.DELETE("/log/:log_id", logDel)
 	engine.PUT("/token", tokenPut)
@@ -80,6 +86,12 @@ func Register(engine *gin.Engine) {
 	engine.POST("/stop", stopPost)
 	engine.POST("/restart", restartPost)
 	engine.GET("/status", statusGet)
+	engine.GET("/diagnostics", diagnosticsGet)
+	engine.GET("/stats", statsGet)
+	engine.GET("/stats/:profile_id", statsGet)
+	engine.GET("/credentials", credentialsGet)
+	engine.DELETE("/credentials", credentialsDelete)
+	engine.DELETE("/credentials/:profile_id", credentialsDelete)
 	engine.GET("/state", stateGet)
 	engine.POST("/wakeup", wakeupPost)
 }
@@ -1,34 +1,146 @@
 package handlers
 
 import (
+	"encoding/json"
+	"sync"
+
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
 )
 
+var pendingRestores = struct {
+	sync.Mutex
+	m map[string]*profileState
+}{
+	m: map[string]*profileState{},
+}
+
+// Only non-secret fields are persisted for a reconnect after a service
+// restart
+type profileState struct {
+	Id                string `json:"id"`
+	Mode              string `json:"mode"`
+	Timeout           bool   `json:"timeout"`
+	Reconnect         bool   `json:"reconnect"`
+	ReconnectAttempts int    `json:"reconnect_max_attempts"`
+}
+
 type profileData struct {
-	Id                 string   `json:"id"`
-	Mode               string   `json:"mode"`
-	OrgId              string   `json:"org_id"`
-	UserId             string   `json:"user_id"`
-	ServerId           string   `json:"server_id"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncToken          string   `json:"sync_token"`
-	SyncSecret         string   `json:"sync_secret"`
-	Data               string   `json:"data"`
-	Username           string   `json:"username"`
-	Password           string   `json:"password"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DisableGateway     bool     `json:"disable_gateway"`
-	SsoAuth            bool     `json:"sso_auth"`
-	ServerPublicKey    string   `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	TokenTtl           int      `json:"token_ttl"`
-	Reconnect          bool     `json:"reconnect"`
-	Timeout            bool     `json:"timeout"`
+	Id                   string                 `json:"id"`
+	Mode                 string                 `json:"mode"`
+	OrgId                string                 `json:"org_id"`
+	UserId               string                 `json:"user_id"`
+	ServerId             string                 `json:"server_id"`
+	SyncHosts            []string               `json:"sync_hosts"`
+	SyncToken            string                 `json:"sync_token"`
+	SyncSecret           string                 `json:"sync_secret"`
+	Data                 string                 `json:"data"`
+	Username             string                 `json:"username"`
+	Password             string                 `json:"password"`
+	DynamicFirewall      bool                   `json:"dynamic_firewall"`
+	DisableGateway       bool                   `json:"disable_gateway"`
+	ExcludeRoutes        []string               `json:"exclude_routes"`
+	CustomRoutes         []*profile.CustomRoute `json:"custom_routes"`
+	PreConnectCmd        string                 `json:"pre_connect_cmd"`
+	PostConnectCmd       string                 `json:"post_connect_cmd"`
+	PreDisconnectCmd     string                 `json:"pre_disconnect_cmd"`
+	PostDisconnectCmd    string                 `json:"post_disconnect_cmd"`
+	SsoAuth              bool                   `json:"sso_auth"`
+	ServerPublicKey      string                 `json:"server_public_key"`
+	ServerBoxPublicKey   string                 `json:"server_box_public_key"`
+	TokenTtl             int                    `json:"token_ttl"`
+	OtpCacheTtl          int                    `json:"otp_cache_ttl"`
+	Reconnect            bool                   `json:"reconnect"`
+	ReconnectAttempts    int                    `json:"reconnect_max_attempts"`
+	ForceDns             bool                   `json:"force_dns"`
+	KillSwitch           bool                   `json:"kill_switch"`
+	BytesInterval        int                    `json:"bytes_interval"`
+	BlockIpv6            bool                   `json:"block_ipv6"`
+	ConnectTimeout       int                    `json:"connect_timeout"`
+	ProxyType            string                 `json:"proxy_type"`
+	ProxyHost            string                 `json:"proxy_host"`
+	ProxyPort            int                    `json:"proxy_port"`
+	ProxyUser            string                 `json:"proxy_user"`
+	ProxyPass            string                 `json:"proxy_pass"`
+	AutoMtu              bool                   `json:"auto_mtu"`
+	DnsOverHttps         bool                   `json:"dns_over_https"`
+	DnsOverHttpsUpstream string                 `json:"dns_over_https_upstream"`
+	DisconnectOnSleep    bool                   `json:"disconnect_on_sleep"`
+	Pkcs11Provider       string                 `json:"pkcs11_provider"`
+	Pkcs11Id             string                 `json:"pkcs11_id"`
+	Pkcs11Pin            string                 `json:"pkcs11_pin"`
+	WgPorts              []int                  `json:"wg_ports"`
+	RemotePorts          []int                  `json:"remote_ports"`
+	AllowLocalNetwork    bool                   `json:"allow_local_network"`
+	SearchDomains        []string               `json:"search_domains"`
+	SplitDns             bool                   `json:"split_dns"`
+	TlsVersionMin        string                 `json:"tls_version_min"`
+	DataCiphers          []string               `json:"data_ciphers"`
+	TlsCipher            string                 `json:"tls_cipher"`
+	Timeout              bool                   `json:"timeout"`
+}
+
+func (d *profileData) Profile() (prfl *profile.Profile) {
+	prfl = &profile.Profile{
+		Id:                   d.Id,
+		Mode:                 d.Mode,
+		OrgId:                d.OrgId,
+		UserId:               d.UserId,
+		ServerId:             d.ServerId,
+		SyncHosts:            d.SyncHosts,
+		SyncToken:            d.SyncToken,
+		SyncSecret:           d.SyncSecret,
+		Data:                 d.Data,
+		Username:             d.Username,
+		Password:             d.Password,
+		DynamicFirewall:      d.DynamicFirewall,
+		DisableGateway:       d.DisableGateway,
+		ExcludeRoutes:        d.ExcludeRoutes,
+		CustomRoutes:         d.CustomRoutes,
+		PreConnectCmd:        d.PreConnectCmd,
+		PostConnectCmd:       d.PostConnectCmd,
+		PreDisconnectCmd:     d.PreDisconnectCmd,
+		PostDisconnectCmd:    d.PostDisconnectCmd,
+		SsoAuth:              d.SsoAuth,
+		ServerPublicKey:      d.ServerPublicKey,
+		ServerBoxPublicKey:   d.ServerBoxPublicKey,
+		TokenTtl:             d.TokenTtl,
+		OtpCacheTtl:          d.OtpCacheTtl,
+		Reconnect:            d.Reconnect,
+		ReconnectMaxAttempts: d.ReconnectAttempts,
+		ForceDns:             d.ForceDns,
+		KillSwitch:           d.KillSwitch,
+		BytesInterval:        d.BytesInterval,
+		BlockIpv6:            d.BlockIpv6,
+		ConnectTimeout:       d.ConnectTimeout,
+		ProxyType:            d.ProxyType,
+		ProxyHost:            d.ProxyHost,
+		ProxyPort:            d.ProxyPort,
+		ProxyUser:            d.ProxyUser,
+		ProxyPass:            d.ProxyPass,
+		AutoMtu:              d.AutoMtu,
+		DnsOverHttps:         d.DnsOverHttps,
+		DnsOverHttpsUpstream: d.DnsOverHttpsUpstream,
+		DisconnectOnSleep:    d.DisconnectOnSleep,
+		Pkcs11Provider:       d.Pkcs11Provider,
+		Pkcs11Id:             d.Pkcs11Id,
+		Pkcs11Pin:            d.Pkcs11Pin,
+		WgPorts:              d.WgPorts,
+		RemotePorts:          d.RemotePorts,
+		AllowLocalNetwork:    d.AllowLocalNetwork,
+		SearchDomains:        d.SearchDomains,
+		SplitDns:             d.SplitDns,
+		TlsVersionMin:        d.TlsVersionMin,
+		DataCiphers:          d.DataCiphers,
+		TlsCipher:            d.TlsCipher,
+	}
+
+	return
 }
 
 func profileGet(c *gin.Context) {
@@ -56,8 +168,12 @@ func profilePost(c *gin.Context) {
 		return
 	}
 
+	clearRestore(data.Id)
+
 	sprfl := sprofile.Get(data.Id)
 	if sprfl != nil {
+		profile.EnableOnDemand(data.Id)
+
 		err = sprofile.Activate(data.Id, data.Mode, data.Password)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -73,26 +189,7 @@ func profilePost(c *gin.Context) {
 		prfl.Stop()
 	}
 
-	prfl = &profile.Profile{
-		Id:                 data.Id,
-		Mode:               data.Mode,
-		OrgId:              data.OrgId,
-		UserId:             data.UserId,
-		ServerId:           data.ServerId,
-		SyncHosts:          data.SyncHosts,
-		SyncToken:          data.SyncToken,
-		SyncSecret:         data.SyncSecret,
-		Data:               data.Data,
-		Username:           data.Username,
-		Password:           data.Password,
-		DynamicFirewall:    data.DynamicFirewall,
-		DisableGateway:     data.DisableGateway,
-		SsoAuth:            data.SsoAuth,
-		ServerPublicKey:    data.ServerPublicKey,
-		ServerBoxPublicKey: data.ServerBoxPublicKey,
-		TokenTtl:           data.TokenTtl,
-		Reconnect:          data.Reconnect,
-	}
+	prfl = data.Profile()
 	prfl.Init()
 
 	go func() {
@@ -108,6 +205,12 @@ func profilePost(c *gin.Context) {
 		return
 	}
 
+	if data.Reconnect {
+		saveProfileState(data)
+	} else {
+		sprofile.ClearUserState(data.Id)
+	}
+
 	c.JSON(200, nil)
 }
 
@@ -129,22 +232,29 @@ func profileDel(c *gin.Context) {
 		return
 	}
 
-	sprfl := sprofile.Get(data.Id)
-	if sprfl != nil {
-		sprofile.Deactivate(data.Id)
-		c.JSON(200, nil)
+	clearRestore(data.Id)
+	profile.Disconnect(data.Id)
+
+	c.JSON(200, nil)
+}
+
+func profileDel2(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
 		return
 	}
 
-	prfl := profile.GetProfile(data.Id)
-	if prfl != nil {
-		prfl.Stop()
-	}
+	clearRestore(prflId)
+	profile.Disconnect(prflId)
 
 	c.JSON(200, nil)
 }
 
-func profileDel2(c *gin.Context) {
+func profileWgPut(c *gin.Context) {
 	prflId := utils.FilterStr(c.Param("profile_id"))
 	if prflId == "" {
 		err := &errortypes.ParseError{
@@ -154,17 +264,105 @@ func profileDel2(c *gin.Context) {
 		return
 	}
 
-	sprfl := sprofile.Get(prflId)
-	if sprfl != nil {
-		sprofile.Deactivate(prflId)
-		c.JSON(200, nil)
+	data := &profile.WgConf{}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
 		return
 	}
 
-	prfl := profile.GetProfile(prflId)
-	if prfl != nil {
-		prfl.Stop()
+	err = profile.ReloadWg(prflId, data)
+	if err != nil {
+		switch err.(type) {
+		case *errortypes.NotFoundError:
+			utils.AbortWithError(c, 404, err)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+			break
+		}
+		return
 	}
 
 	c.JSON(200, nil)
 }
+
+func saveProfileState(data *profileData) {
+	stateData, err := json.Marshal(&profileState{
+		Id:                data.Id,
+		Mode:              data.Mode,
+		Timeout:           data.Timeout,
+		Reconnect:         data.Reconnect,
+		ReconnectAttempts: data.ReconnectAttempts,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Failed to marshal profile state"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"profile_id": data.Id,
+			"error":      err,
+		}).Error("handler: Failed to save profile state")
+		return
+	}
+
+	sprofile.SetUserState(data.Id, string(stateData))
+}
+
+func clearRestore(prflId string) {
+	pendingRestores.Lock()
+	delete(pendingRestores.m, prflId)
+	pendingRestores.Unlock()
+}
+
+func takeRestores() (states []*profileState) {
+	pendingRestores.Lock()
+	defer pendingRestores.Unlock()
+
+	states = []*profileState{}
+	for _, state := range pendingRestores.m {
+		states = append(states, state)
+	}
+	pendingRestores.m = map[string]*profileState{}
+
+	return
+}
+
+// Queue a reconnect prompt for user profiles that were connected with
+// reconnect enabled when the service stopped, credentials are not stored
+// so the client must connect again
+func RestoreProfiles() {
+	for prflId, stateData := range sprofile.GetUserStates() {
+		state := &profileState{}
+
+		err := json.Unmarshal([]byte(stateData), state)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "handler: Failed to parse profile state"),
+			}
+			logrus.WithFields(logrus.Fields{
+				"profile_id": prflId,
+				"error":      err,
+			}).Error("handler: Failed to load profile state")
+			sprofile.ClearUserState(prflId)
+			continue
+		}
+
+		sprofile.ClearUserState(prflId)
+
+		if state.Id != prflId || !state.Reconnect ||
+			profile.GetProfile(prflId) != nil {
+
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+		}).Info("handler: Profile reconnect requires credentials")
+
+		pendingRestores.Lock()
+		pendingRestores.m[prflId] = state
+		pendingRestores.Unlock()
+	}
+}
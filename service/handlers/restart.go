@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"github.com/sirupsen/logrus"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/sirupsen/logrus"
 )
 
 func restartPost(c *gin.Context) {
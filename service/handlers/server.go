@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type serverImportData struct {
+	ServerUrl string `json:"server_url"`
+	Token     string `json:"token"`
+}
+
+func sprofileServerPost(c *gin.Context) {
+	data := &serverImportData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	prfls, err := profile.SyncFromServer(data.ServerUrl, data.Token)
+	if err != nil {
+		switch err.(type) {
+		case *errortypes.ParseError, *errortypes.NotFoundError:
+			utils.AbortWithError(c, 400, err)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	sprfls := []*sprofile.SprofileClient{}
+	for _, prfl := range prfls {
+		sprfls = append(sprfls, prfl.SystemProfile.Client())
+	}
+
+	c.JSON(200, sprfls)
+}
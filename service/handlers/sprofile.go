@@ -10,33 +10,90 @@ import (
 )
 
 type sprofileData struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	State              bool     `json:"state"`
-	Wg                 bool     `json:"wg"`
-	LastMode           string   `json:"last_mode"`
-	OrganizationId     string   `json:"organization_id"`
-	Organization       string   `json:"organization"`
-	ServerId           string   `json:"server_id"`
-	Server             string   `json:"server"`
-	UserId             string   `json:"user_id"`
-	User               string   `json:"user"`
-	PreConnectMsg      string   `json:"pre_connect_msg"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DisableGateway     bool     `json:"disable_gateway"`
-	SsoAuth            bool     `json:"sso_auth"`
-	PasswordMode       string   `json:"password_mode"`
-	Token              bool     `json:"token"`
-	TokenTtl           int      `json:"token_ttl"`
-	Disabled           bool     `json:"disabled"`
-	SyncTime           int64    `json:"sync_time"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncHash           string   `json:"sync_hash"`
-	SyncSecret         string   `json:"sync_secret"`
-	SyncToken          string   `json:"sync_token"`
-	ServerPublicKey    []string `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	OvpnData           string   `json:"ovpn_data"`
+	Id                 string                  `json:"id"`
+	Name               string                  `json:"name"`
+	State              bool                    `json:"state"`
+	Wg                 bool                    `json:"wg"`
+	LastMode           string                  `json:"last_mode"`
+	OrganizationId     string                  `json:"organization_id"`
+	Organization       string                  `json:"organization"`
+	ServerId           string                  `json:"server_id"`
+	Server             string                  `json:"server"`
+	UserId             string                  `json:"user_id"`
+	User               string                  `json:"user"`
+	PreConnectMsg      string                  `json:"pre_connect_msg"`
+	DynamicFirewall    bool                    `json:"dynamic_firewall"`
+	DisableGateway     bool                    `json:"disable_gateway"`
+	ExcludeRoutes      []string                `json:"exclude_routes"`
+	CustomRoutes       []*sprofile.CustomRoute `json:"custom_routes"`
+	KillSwitch         bool                    `json:"kill_switch"`
+	BlockIpv6          bool                    `json:"block_ipv6"`
+	SsoAuth            bool                    `json:"sso_auth"`
+	PasswordMode       string                  `json:"password_mode"`
+	Token              bool                    `json:"token"`
+	TokenTtl           int                     `json:"token_ttl"`
+	Disabled           bool                    `json:"disabled"`
+	SyncTime           int64                   `json:"sync_time"`
+	SyncHosts          []string                `json:"sync_hosts"`
+	SyncHash           string                  `json:"sync_hash"`
+	SyncSecret         string                  `json:"sync_secret"`
+	SyncToken          string                  `json:"sync_token"`
+	ImportUrl          string                  `json:"import_url"`
+	ImportName         string                  `json:"import_name"`
+	Stale              bool                    `json:"stale"`
+	ServerPublicKey    []string                `json:"server_public_key"`
+	ServerBoxPublicKey string                  `json:"server_box_public_key"`
+	OvpnData           string                  `json:"ovpn_data"`
+	OnDemandDomains    []string                `json:"on_demand_domains"`
+	OnDemandCidrs      []string                `json:"on_demand_cidrs"`
+	AutoStart          bool                    `json:"auto_start"`
+	CertPath           string                  `json:"cert_path"`
+	KeyPath            string                  `json:"key_path"`
+}
+
+func (d *sprofileData) Sprofile() (prfl *sprofile.Sprofile) {
+	prfl = &sprofile.Sprofile{
+		Id:                 d.Id,
+		Name:               d.Name,
+		Wg:                 d.Wg,
+		LastMode:           d.LastMode,
+		OrganizationId:     d.OrganizationId,
+		Organization:       d.Organization,
+		ServerId:           d.ServerId,
+		Server:             d.Server,
+		UserId:             d.UserId,
+		User:               d.User,
+		PreConnectMsg:      d.PreConnectMsg,
+		DynamicFirewall:    d.DynamicFirewall,
+		DisableGateway:     d.DisableGateway,
+		ExcludeRoutes:      d.ExcludeRoutes,
+		CustomRoutes:       d.CustomRoutes,
+		KillSwitch:         d.KillSwitch,
+		BlockIpv6:          d.BlockIpv6,
+		SsoAuth:            d.SsoAuth,
+		PasswordMode:       d.PasswordMode,
+		Token:              d.Token,
+		TokenTtl:           d.TokenTtl,
+		Disabled:           d.Disabled,
+		SyncTime:           d.SyncTime,
+		SyncHosts:          d.SyncHosts,
+		SyncHash:           d.SyncHash,
+		SyncSecret:         d.SyncSecret,
+		SyncToken:          d.SyncToken,
+		ImportUrl:          d.ImportUrl,
+		ImportName:         d.ImportName,
+		Stale:              d.Stale,
+		ServerPublicKey:    d.ServerPublicKey,
+		ServerBoxPublicKey: d.ServerBoxPublicKey,
+		OvpnData:           d.OvpnData,
+		OnDemandDomains:    d.OnDemandDomains,
+		OnDemandCidrs:      d.OnDemandCidrs,
+		AutoStart:          d.AutoStart,
+		CertPath:           d.CertPath,
+		KeyPath:            d.KeyPath,
+	}
+
+	return
 }
 
 func sprofilesGet(c *gin.Context) {
@@ -76,33 +133,20 @@ func sprofilePut(c *gin.Context) {
 		return
 	}
 
-	prfl := &sprofile.Sprofile{
-		Id:                 data.Id,
-		Name:               data.Name,
-		Wg:                 data.Wg,
-		LastMode:           data.LastMode,
-		OrganizationId:     data.OrganizationId,
-		Organization:       data.Organization,
-		ServerId:           data.ServerId,
-		Server:             data.Server,
-		UserId:             data.UserId,
-		User:               data.User,
-		PreConnectMsg:      data.PreConnectMsg,
-		DynamicFirewall:    data.DynamicFirewall,
-		DisableGateway:     data.DisableGateway,
-		SsoAuth:            data.SsoAuth,
-		PasswordMode:       data.PasswordMode,
-		Token:              data.Token,
-		TokenTtl:           data.TokenTtl,
-		Disabled:           data.Disabled,
-		SyncTime:           data.SyncTime,
-		SyncHosts:          data.SyncHosts,
-		SyncHash:           data.SyncHash,
-		SyncSecret:         data.SyncSecret,
-		SyncToken:          data.SyncToken,
-		ServerPublicKey:    data.ServerPublicKey,
-		ServerBoxPublicKey: data.ServerBoxPublicKey,
-		OvpnData:           data.OvpnData,
+	prfl := data.Sprofile()
+
+	if prfl.OvpnData != "" {
+		err = profile.ValidateConfig([]byte(prfl.OvpnData))
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+
+	err = prfl.ValidateAutoStart()
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
 	}
 
 	err = prfl.Commit()
@@ -208,3 +252,138 @@ func sprofileLogDel(c *gin.Context) {
 
 	c.JSON(200, nil)
 }
+
+func sprofileExportGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	data, err := profile.Export(prflId)
+	if err != nil {
+		switch err.(type) {
+		case *errortypes.NotFoundError:
+			utils.AbortWithError(c, 404, err)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+			break
+		}
+		return
+	}
+
+	c.Data(200, "application/x-tar", data)
+}
+
+func sprofileImportPost(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "handler: Failed to read import data"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	sprfl, err := profile.Import(data)
+	if err != nil {
+		switch err.(type) {
+		case *errortypes.ParseError:
+			utils.AbortWithError(c, 400, err)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+			break
+		}
+		return
+	}
+
+	c.JSON(200, sprfl.Client())
+}
+
+func sprofileEnrollPost(c *gin.Context) {
+	data := &sprofileData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	data.Id = utils.FilterStr(data.Id)
+	if data.Id == "" {
+		data.Id, err = sprofile.NewId()
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	prfl := data.Sprofile()
+	prfl.AutoStart = true
+
+	err = profile.ValidateConfig([]byte(prfl.OvpnData))
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	err = prfl.ValidateAutoStart()
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	err = prfl.Commit()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = sprofile.Reload(false)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = sprofile.Activate(prfl.Id, prfl.LastMode, "")
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, prfl.Client())
+}
+
+func sprofileEnrollDel(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	sprfl := sprofile.Get(prflId)
+	if sprfl == nil || !sprfl.AutoStart {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	prfl := profile.GetProfile(prflId)
+	if prfl != nil {
+		prfl.Stop()
+	}
+
+	sprofile.Remove(prflId)
+
+	c.JSON(200, nil)
+}
@@ -10,9 +10,10 @@ import (
 )
 
 type stateData struct {
-	Wg      bool   `json:"wg"`
-	Version string `json:"version"`
-	Upgrade bool   `json:"upgrade"`
+	Wg      bool            `json:"wg"`
+	Version string          `json:"version"`
+	Upgrade bool            `json:"upgrade"`
+	Restore []*profileState `json:"restore"`
 }
 
 func stateGet(c *gin.Context) {
@@ -20,6 +21,7 @@ func stateGet(c *gin.Context) {
 		Wg:      false,
 		Version: constants.Version,
 		Upgrade: update.Upgrade,
+		Restore: takeRestores(),
 	}
 
 	switch runtime.GOOS {
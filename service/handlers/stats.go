@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func statsGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+
+	since := time.Now().Add(-30 * 24 * time.Hour)
+	sinceStr := c.Query("since")
+	if sinceStr != "" {
+		sinceUnix, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "handler: Invalid since timestamp"),
+			}
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+		since = time.Unix(sinceUnix, 0)
+	}
+
+	sessions, err := stats.Query(prflId, since)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, sessions)
+}
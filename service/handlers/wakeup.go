@@ -13,7 +13,7 @@ func wakeupPost(c *gin.Context) {
 		Id:   utils.Uuid(),
 		Type: "wakeup",
 	}
-	evt.Init()
+	evt.Init(nil)
 
 	if time.Since(event.LastPong) > 45*time.Second {
 		c.String(404, "")
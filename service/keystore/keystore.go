@@ -0,0 +1,167 @@
+// Encryption of stored credentials using the OS keystore.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	keyLen = 32
+)
+
+var (
+	masterKey  []byte
+	masterLock = sync.Mutex{}
+)
+
+func getKey() (key []byte, err error) {
+	masterLock.Lock()
+	defer masterLock.Unlock()
+
+	if masterKey != nil {
+		key = masterKey
+		return
+	}
+
+	key, err = loadKey()
+	if err != nil {
+		return
+	}
+
+	if len(key) != keyLen {
+		key = nil
+		err = &errortypes.ReadError{
+			errors.New("keystore: Invalid master key length"),
+		}
+		return
+	}
+
+	masterKey = key
+
+	return
+}
+
+func newKey() (key []byte, err error) {
+	key, err = utils.RandBytes(keyLen)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func sealData(data []byte) (enc string, err error) {
+	key, err := getKey()
+	if err != nil {
+		return
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "keystore: Failed to init cipher"),
+		}
+		return
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "keystore: Failed to init gcm"),
+		}
+		return
+	}
+
+	nonce, err := utils.RandBytes(gcm.NonceSize())
+	if err != nil {
+		return
+	}
+
+	enc = base64.StdEncoding.EncodeToString(
+		gcm.Seal(nonce, nonce, data, nil))
+
+	return
+}
+
+func openData(enc string) (data []byte, err error) {
+	key, err := getKey()
+	if err != nil {
+		return
+	}
+
+	encData, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "keystore: Failed to decode data"),
+		}
+		return
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "keystore: Failed to init cipher"),
+		}
+		return
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "keystore: Failed to init gcm"),
+		}
+		return
+	}
+
+	if len(encData) < gcm.NonceSize() {
+		err = &errortypes.ParseError{
+			errors.New("keystore: Encrypted data too short"),
+		}
+		return
+	}
+
+	data, err = gcm.Open(nil, encData[:gcm.NonceSize()],
+		encData[gcm.NonceSize():], nil)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "keystore: Failed to decrypt data"),
+		}
+		return
+	}
+
+	return
+}
+
+func Encrypt(data string) (enc string, err error) {
+	if data == "" {
+		return
+	}
+
+	enc, err = encrypt([]byte(data))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Decrypt(enc string) (data string, err error) {
+	if enc == "" {
+		return
+	}
+
+	dataByt, err := decrypt(enc)
+	if err != nil {
+		return
+	}
+	data = string(dataByt)
+
+	return
+}
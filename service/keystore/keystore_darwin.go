@@ -0,0 +1,118 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	keychainPath    = "/Library/Keychains/System.keychain"
+	keychainService = "pritunl-client-credentials"
+	keychainAccount = "pritunl"
+
+	// errSecItemNotFound exit status from security
+	keychainNotFound = 44
+)
+
+func readKey() (key []byte, found bool, err error) {
+	output, err := command.Command(
+		"/usr/bin/security", "find-generic-password",
+		"-a", keychainAccount,
+		"-s", keychainService,
+		"-w", keychainPath,
+	).Output()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if ok && exitErr.ExitCode() == keychainNotFound {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "keystore: Failed to read keychain key"),
+		}
+		return
+	}
+
+	found = true
+	key, err = base64.StdEncoding.DecodeString(
+		strings.TrimSpace(string(output)))
+	if err != nil || len(key) != keyLen {
+		key = nil
+		err = &errortypes.ReadError{
+			errors.New("keystore: Invalid keychain key"),
+		}
+		return
+	}
+
+	return
+}
+
+func storeKey(key []byte) (err error) {
+	// Pass the key through the interactive command input to keep it out
+	// of the process arguments, without -U an existing key is never
+	// replaced
+	cmd := command.Command("/usr/bin/security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf(
+		"add-generic-password -a %s -s %s -w %s %s\n",
+		keychainAccount, keychainService,
+		base64.StdEncoding.EncodeToString(key), keychainPath,
+	))
+
+	err = cmd.Run()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "keystore: Failed to store keychain key"),
+		}
+		return
+	}
+
+	return
+}
+
+func loadKey() (key []byte, err error) {
+	key, found, err := readKey()
+	if err != nil || found {
+		return
+	}
+
+	created, err := newKey()
+	if err != nil {
+		return
+	}
+
+	err = storeKey(created)
+	if err != nil {
+		return
+	}
+
+	// Read back the stored key, if another key was added concurrently
+	// the existing key is used
+	key, found, err = readKey()
+	if err != nil {
+		return
+	}
+
+	if !found {
+		err = &errortypes.WriteError{
+			errors.New("keystore: Keychain key not stored"),
+		}
+		return
+	}
+
+	return
+}
+
+func encrypt(data []byte) (string, error) {
+	return sealData(data)
+}
+
+func decrypt(enc string) ([]byte, error) {
+	return openData(enc)
+}
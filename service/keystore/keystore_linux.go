@@ -0,0 +1,224 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	secretService = "pritunl-client-credentials"
+	secretAccount = "pritunl"
+	secretMarker  = "secret-service"
+)
+
+func keyPath() string {
+	if constants.Development {
+		return filepath.Join(utils.GetRootDir(), "..", "dev",
+			"credentials.key")
+	}
+
+	return filepath.Join(string(filepath.Separator),
+		"var", "lib", "pritunl-client", "credentials.key")
+}
+
+func lookupSecretKey() (key []byte, found bool, err error) {
+	output, err := command.Command(
+		"secret-tool", "lookup",
+		"service", secretService,
+		"account", secretAccount,
+	).Output()
+	if err != nil {
+		// Lookup exits without output when the key does not exist, other
+		// failures are reported on stderr
+		exitErr, ok := err.(*exec.ExitError)
+		if ok && exitErr.ExitCode() == 1 &&
+			len(bytes.TrimSpace(exitErr.Stderr)) == 0 {
+
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "keystore: Failed to read secret service key"),
+		}
+		return
+	}
+
+	found = true
+	key, err = base64.StdEncoding.DecodeString(
+		strings.TrimSpace(string(output)))
+	if err != nil || len(key) != keyLen {
+		key = nil
+		err = &errortypes.ReadError{
+			errors.New("keystore: Invalid secret service key"),
+		}
+		return
+	}
+
+	return
+}
+
+func storeSecretKey(key []byte) (err error) {
+	cmd := command.Command(
+		"secret-tool", "store",
+		"--label=Pritunl Client Credentials",
+		"service", secretService,
+		"account", secretAccount,
+	)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(key))
+
+	err = cmd.Run()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "keystore: Failed to store secret service key"),
+		}
+		return
+	}
+
+	return
+}
+
+func readFileKey() (data string, found bool, err error) {
+	dataByt, err := ioutil.ReadFile(keyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "keystore: Failed to read key file"),
+		}
+		return
+	}
+
+	found = true
+	data = strings.TrimSpace(string(dataByt))
+
+	return
+}
+
+func writeFileKey(data string) (err error) {
+	pth := keyPath()
+
+	err = os.MkdirAll(filepath.Dir(pth), 0755)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "keystore: Failed to create key directory"),
+		}
+		return
+	}
+
+	err = utils.CreateWrite(pth, data, 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func loadSecretKey() (key []byte, err error) {
+	key, found, err := lookupSecretKey()
+	if err != nil {
+		return
+	}
+
+	if !found {
+		key, err = newKey()
+		if err != nil {
+			return
+		}
+
+		err = storeSecretKey(key)
+		if err != nil {
+			key = nil
+			return
+		}
+	}
+
+	// Record that the secret service holds the key so a later failure
+	// of the secret service never creates a replacement key file
+	e := writeFileKey(secretMarker)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": e,
+		}).Error("keystore: Failed to write secret service marker")
+	}
+
+	return
+}
+
+// The key is loaded from the backend that first stored it and is never
+// replaced, a new key is only created when no key exists
+func loadKey() (key []byte, err error) {
+	data, found, err := readFileKey()
+	if err != nil {
+		return
+	}
+
+	if found {
+		if data == secretMarker {
+			var secretFound bool
+			key, secretFound, err = lookupSecretKey()
+			if err == nil && !secretFound {
+				err = &errortypes.NotFoundError{
+					errors.New("keystore: Secret service key missing"),
+				}
+			}
+			return
+		}
+
+		key, err = base64.StdEncoding.DecodeString(data)
+		if err != nil || len(key) != keyLen {
+			key = nil
+			err = &errortypes.ReadError{
+				errors.New("keystore: Invalid key file"),
+			}
+			return
+		}
+
+		return
+	}
+
+	key, err = loadSecretKey()
+	if err == nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"error": err,
+	}).Info("keystore: Secret service unavailable, using key file")
+
+	key, err = newKey()
+	if err != nil {
+		return
+	}
+
+	err = writeFileKey(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		key = nil
+		return
+	}
+
+	return
+}
+
+func encrypt(data []byte) (string, error) {
+	return sealData(data)
+}
+
+func decrypt(enc string) ([]byte, error) {
+	return openData(enc)
+}
@@ -0,0 +1,79 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+func loadKey() (key []byte, err error) {
+	err = &errortypes.UnknownError{
+		errors.New("keystore: Master key not used on windows"),
+	}
+	return
+}
+
+func blobBytes(blob *windows.DataBlob) []byte {
+	if blob.Size == 0 {
+		return []byte{}
+	}
+
+	data := make([]byte, blob.Size)
+	copy(data, unsafe.Slice(blob.Data, blob.Size))
+	return data
+}
+
+func encrypt(data []byte) (enc string, err error) {
+	in := &windows.DataBlob{
+		Size: uint32(len(data)),
+		Data: &data[0],
+	}
+	out := &windows.DataBlob{}
+
+	err = windows.CryptProtectData(in, nil, nil, 0, nil,
+		windows.CRYPTPROTECT_UI_FORBIDDEN, out)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "keystore: Failed to protect data"),
+		}
+		return
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+
+	enc = base64.StdEncoding.EncodeToString(blobBytes(out))
+
+	return
+}
+
+func decrypt(enc string) (data []byte, err error) {
+	encData, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil || len(encData) == 0 {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "keystore: Failed to decode data"),
+		}
+		return
+	}
+
+	in := &windows.DataBlob{
+		Size: uint32(len(encData)),
+		Data: &encData[0],
+	}
+	out := &windows.DataBlob{}
+
+	err = windows.CryptUnprotectData(in, nil, nil, 0, nil,
+		windows.CRYPTPROTECT_UI_FORBIDDEN, out)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "keystore: Failed to unprotect data"),
+		}
+		return
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+
+	data = blobBytes(out)
+
+	return
+}
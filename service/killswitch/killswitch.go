@@ -0,0 +1,382 @@
+package killswitch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	rules        = map[string]*ruleset{}
+	curState     *state
+	rulesLock    = sync.Mutex{}
+	resolveCache = map[string][]net.IP{}
+)
+
+type ruleset struct {
+	Remotes   []string
+	Resolvers []string
+	Ifaces    []string
+}
+
+type allowList struct {
+	Addrs4 []string
+	Addrs6 []string
+	Dns4   []string
+	Dns6   []string
+	Ifaces []string
+}
+
+type state struct {
+	Backend string            `json:"backend"`
+	Token   string            `json:"token"`
+	Policy  map[string]string `json:"policy"`
+	Filters int               `json:"filters"`
+}
+
+func splitAddrs(addrs []string) (addrs4, addrs6 []string) {
+	addrs4Set := set.NewSet()
+	addrs6Set := set.NewSet()
+
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil || ip.IsLoopback() {
+			continue
+		}
+
+		if ip.To4() != nil {
+			addrs4Set.Add(ip.String())
+		} else {
+			addrs6Set.Add(ip.String())
+		}
+	}
+
+	addrs4 = []string{}
+	for addr := range addrs4Set.Iter() {
+		addrs4 = append(addrs4, addr.(string))
+	}
+	sort.Strings(addrs4)
+
+	addrs6 = []string{}
+	for addr := range addrs6Set.Iter() {
+		addrs6 = append(addrs6, addr.(string))
+	}
+	sort.Strings(addrs6)
+
+	return
+}
+
+func resolve(hosts []string) (addrs4, addrs6 []string) {
+	addrs4Set := set.NewSet()
+	addrs6Set := set.NewSet()
+
+	for _, host := range hosts {
+		hostname, _, e := net.SplitHostPort(host)
+		if e == nil {
+			host = hostname
+		}
+
+		ips := []net.IP{}
+		ip := net.ParseIP(host)
+		if ip != nil {
+			ips = append(ips, ip)
+		} else {
+			resolved, err := net.LookupIP(host)
+			if err != nil {
+				cached := resolveCache[host]
+				if cached == nil {
+					logrus.WithFields(logrus.Fields{
+						"host":  host,
+						"error": err,
+					}).Warn("killswitch: Failed to resolve remote")
+					continue
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"host":  host,
+					"error": err,
+				}).Warn("killswitch: Failed to resolve remote, " +
+					"using cached addresses")
+				resolved = cached
+			} else {
+				resolveCache[host] = resolved
+			}
+			ips = append(ips, resolved...)
+		}
+
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				addrs4Set.Add(ip.String())
+			} else {
+				addrs6Set.Add(ip.String())
+			}
+		}
+	}
+
+	addrs4 = []string{}
+	for addr := range addrs4Set.Iter() {
+		addrs4 = append(addrs4, addr.(string))
+	}
+	sort.Strings(addrs4)
+
+	addrs6 = []string{}
+	for addr := range addrs6Set.Iter() {
+		addrs6 = append(addrs6, addr.(string))
+	}
+	sort.Strings(addrs6)
+
+	return
+}
+
+func loadState() (st *state, err error) {
+	data, err := ioutil.ReadFile(utils.GetKillSwitchPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "killswitch: Failed to read state"),
+		}
+		return
+	}
+
+	st = &state{}
+	err = json.Unmarshal(data, st)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "killswitch: Failed to parse state"),
+		}
+		return
+	}
+
+	return
+}
+
+func saveState(st *state) (err error) {
+	data, err := json.Marshal(st)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "killswitch: Failed to marshal state"),
+		}
+		return
+	}
+
+	err = ioutil.WriteFile(
+		utils.GetKillSwitchPath(),
+		data,
+		os.FileMode(0600),
+	)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "killswitch: Failed to write state"),
+		}
+		return
+	}
+
+	return
+}
+
+func apply() (err error) {
+	remotes := []string{}
+	resolvers := []string{}
+	ifacesSet := set.NewSet()
+	ifaces := []string{}
+
+	for _, rule := range rules {
+		remotes = append(remotes, rule.Remotes...)
+		resolvers = append(resolvers, rule.Resolvers...)
+		for _, iface := range rule.Ifaces {
+			if iface != "" && !ifacesSet.Contains(iface) {
+				ifacesSet.Add(iface)
+				ifaces = append(ifaces, iface)
+			}
+		}
+	}
+	sort.Strings(ifaces)
+
+	allow := &allowList{
+		Ifaces: ifaces,
+	}
+	allow.Addrs4, allow.Addrs6 = resolve(remotes)
+	allow.Dns4, allow.Dns6 = splitAddrs(resolvers)
+
+	if curState == nil {
+		st := &state{}
+
+		err = prepare(st)
+		if err != nil {
+			return
+		}
+
+		err = saveState(st)
+		if err != nil {
+			return
+		}
+
+		curState = st
+	}
+
+	err = install(curState, allow)
+	if err != nil {
+		return
+	}
+
+	err = saveState(curState)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func teardown() (err error) {
+	if curState == nil {
+		return
+	}
+
+	err = remove(curState)
+	if err != nil {
+		return
+	}
+
+	curState = nil
+
+	err = os.Remove(utils.GetKillSwitchPath())
+	if err != nil && !os.IsNotExist(err) {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "killswitch: Failed to remove state"),
+		}
+		return
+	}
+	err = nil
+
+	return
+}
+
+func Enable(prflId string, remotes, resolvers []string) (err error) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	rules[prflId] = &ruleset{
+		Remotes:   remotes,
+		Resolvers: resolvers,
+		Ifaces:    []string{},
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+	}).Info("killswitch: Enabling kill switch")
+
+	err = apply()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func AllowInterface(prflId, iface string) (err error) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	rule := rules[prflId]
+	if rule == nil || iface == "" {
+		return
+	}
+
+	exists := false
+	for _, ruleIface := range rule.Ifaces {
+		if ruleIface == iface {
+			exists = true
+			break
+		}
+	}
+	if exists && rule.Resolvers == nil {
+		return
+	}
+
+	if !exists {
+		rule.Ifaces = append(rule.Ifaces, iface)
+	}
+	rule.Resolvers = nil
+
+	err = apply()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Disable(prflId string) (err error) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	if _, ok := rules[prflId]; !ok {
+		return
+	}
+	delete(rules, prflId)
+
+	if len(rules) > 0 {
+		err = apply()
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+	}).Info("killswitch: Disabling kill switch")
+
+	err = teardown()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Active() bool {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	return curState != nil
+}
+
+func Clean() (err error) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	st, err := loadState()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("killswitch: Failed to load stale state")
+		st = &state{}
+		err = nil
+	} else if st == nil {
+		return
+	}
+
+	logrus.Warn("killswitch: Removing stale kill switch rules")
+
+	curState = st
+	err = teardown()
+	if err != nil {
+		return
+	}
+
+	return
+}
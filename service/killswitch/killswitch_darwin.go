@@ -0,0 +1,99 @@
+package killswitch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	pfAnchor = "com.apple/pritunl.killswitch"
+)
+
+func prepare(st *state) (err error) {
+	st.Backend = "pf"
+
+	output, err := utils.ExecCombinedOutputLogged(
+		[]string{"pf already enabled"},
+		"pfctl", "-E",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "Token" && fields[1] == ":" {
+			st.Token = fields[2]
+			break
+		}
+	}
+
+	return
+}
+
+func pfList(items []string) string {
+	return "{ " + strings.Join(items, ", ") + " }"
+}
+
+func install(st *state, allow *allowList) (err error) {
+	output := "block drop out all\n"
+	output += "block drop in all\n"
+	output += "pass quick on lo0 all\n"
+	output += "pass out quick inet proto udp from any port 68 " +
+		"to any port 67 keep state\n"
+	output += "pass out quick inet6 proto udp from any port 546 " +
+		"to any port 547 keep state\n"
+	output += "pass quick inet6 proto icmp6 all icmp6-type " +
+		"{ routersol, routeradv, neighbrsol, neighbradv } keep state\n"
+	if len(allow.Addrs4) > 0 {
+		output += fmt.Sprintf("pass out quick inet to %s keep state\n",
+			pfList(allow.Addrs4))
+	}
+	if len(allow.Addrs6) > 0 {
+		output += fmt.Sprintf("pass out quick inet6 to %s keep state\n",
+			pfList(allow.Addrs6))
+	}
+	if len(allow.Dns4) > 0 {
+		output += fmt.Sprintf("pass out quick inet proto { udp, tcp } "+
+			"to %s port 53 keep state\n", pfList(allow.Dns4))
+	}
+	if len(allow.Dns6) > 0 {
+		output += fmt.Sprintf("pass out quick inet6 proto { udp, tcp } "+
+			"to %s port 53 keep state\n", pfList(allow.Dns6))
+	}
+	if len(allow.Ifaces) > 0 {
+		output += fmt.Sprintf("pass quick on %s all\n",
+			pfList(allow.Ifaces))
+	}
+
+	_, err = utils.ExecInputOutput(output, "pfctl", "-a", pfAnchor, "-f", "-")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func remove(st *state) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"pfctl", "-a", pfAnchor, "-F", "all",
+	)
+	if err != nil {
+		return
+	}
+
+	if st.Token != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"pfctl", "-X", st.Token,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
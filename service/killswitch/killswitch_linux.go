@@ -0,0 +1,263 @@
+package killswitch
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	nftTable    = "pritunl_killswitch"
+	iptChainIn  = "PRITUNL-KILLSWITCH-IN"
+	iptChainOut = "PRITUNL-KILLSWITCH-OUT"
+)
+
+func prepare(st *state) (err error) {
+	_, e := exec.LookPath("nft")
+	if e == nil {
+		st.Backend = "nftables"
+	} else {
+		st.Backend = "iptables"
+	}
+
+	return
+}
+
+func nftSet(addrs []string) string {
+	return "{ " + strings.Join(addrs, ", ") + " }"
+}
+
+func installNft(allow *allowList) (err error) {
+	output := fmt.Sprintf("add table inet %s\n", nftTable)
+	output += fmt.Sprintf("delete table inet %s\n", nftTable)
+	output += fmt.Sprintf("table inet %s {\n", nftTable)
+
+	output += "\tchain output {\n"
+	output += "\t\ttype filter hook output priority 0; policy drop;\n"
+	output += "\t\toifname \"lo\" accept\n"
+	output += "\t\tudp sport 68 udp dport 67 accept\n"
+	output += "\t\tudp sport 546 udp dport 547 accept\n"
+	output += "\t\ticmpv6 type { nd-router-solicit, nd-neighbor-solicit, " +
+		"nd-neighbor-advert } accept\n"
+	if len(allow.Addrs4) > 0 {
+		output += fmt.Sprintf("\t\tip daddr %s accept\n",
+			nftSet(allow.Addrs4))
+	}
+	if len(allow.Addrs6) > 0 {
+		output += fmt.Sprintf("\t\tip6 daddr %s accept\n",
+			nftSet(allow.Addrs6))
+	}
+	if len(allow.Dns4) > 0 {
+		output += fmt.Sprintf("\t\tip daddr %s meta l4proto "+
+			"{ tcp, udp } th dport 53 accept\n", nftSet(allow.Dns4))
+	}
+	if len(allow.Dns6) > 0 {
+		output += fmt.Sprintf("\t\tip6 daddr %s meta l4proto "+
+			"{ tcp, udp } th dport 53 accept\n", nftSet(allow.Dns6))
+	}
+	for _, iface := range allow.Ifaces {
+		output += fmt.Sprintf("\t\toifname \"%s\" accept\n", iface)
+	}
+	output += "\t}\n"
+
+	output += "\tchain input {\n"
+	output += "\t\ttype filter hook input priority 0; policy drop;\n"
+	output += "\t\tiifname \"lo\" accept\n"
+	output += "\t\tct state established,related accept\n"
+	output += "\t\tudp sport 67 udp dport 68 accept\n"
+	output += "\t\tudp sport 547 udp dport 546 accept\n"
+	output += "\t\ticmpv6 type { nd-router-advert, nd-neighbor-solicit, " +
+		"nd-neighbor-advert } accept\n"
+	for _, iface := range allow.Ifaces {
+		output += fmt.Sprintf("\t\tiifname \"%s\" accept\n", iface)
+	}
+	output += "\t}\n"
+
+	output += "}\n"
+
+	_, err = utils.ExecInputOutput(output, "nft", "-f", "-")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func iptRules(ipv6 bool, addrs, dnsAddrs, ifaces []string) (
+	rulesIn, rulesOut [][]string) {
+
+	rulesOut = [][]string{
+		{"-o", "lo", "-j", "ACCEPT"},
+	}
+	rulesIn = [][]string{
+		{"-i", "lo", "-j", "ACCEPT"},
+		{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED",
+			"-j", "ACCEPT"},
+	}
+
+	if ipv6 {
+		rulesOut = append(rulesOut,
+			[]string{"-p", "udp", "--sport", "546", "--dport", "547",
+				"-j", "ACCEPT"},
+			[]string{"-p", "ipv6-icmp", "-j", "ACCEPT"},
+		)
+		rulesIn = append(rulesIn,
+			[]string{"-p", "udp", "--sport", "547", "--dport", "546",
+				"-j", "ACCEPT"},
+			[]string{"-p", "ipv6-icmp", "-j", "ACCEPT"},
+		)
+	} else {
+		rulesOut = append(rulesOut,
+			[]string{"-p", "udp", "--sport", "68", "--dport", "67",
+				"-j", "ACCEPT"},
+		)
+		rulesIn = append(rulesIn,
+			[]string{"-p", "udp", "--sport", "67", "--dport", "68",
+				"-j", "ACCEPT"},
+		)
+	}
+
+	for _, addr := range addrs {
+		rulesOut = append(rulesOut, []string{"-d", addr, "-j", "ACCEPT"})
+	}
+
+	for _, addr := range dnsAddrs {
+		rulesOut = append(rulesOut,
+			[]string{"-d", addr, "-p", "udp", "--dport", "53",
+				"-j", "ACCEPT"},
+			[]string{"-d", addr, "-p", "tcp", "--dport", "53",
+				"-j", "ACCEPT"},
+		)
+	}
+
+	for _, iface := range ifaces {
+		rulesOut = append(rulesOut, []string{"-o", iface, "-j", "ACCEPT"})
+		rulesIn = append(rulesIn, []string{"-i", iface, "-j", "ACCEPT"})
+	}
+
+	rulesOut = append(rulesOut, []string{"-j", "DROP"})
+	rulesIn = append(rulesIn, []string{"-j", "DROP"})
+
+	return
+}
+
+func installIptChain(cmd, parent, chain string, rules [][]string) (
+	err error) {
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"already exists"},
+		cmd, "-N", chain,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, cmd, "-F", chain)
+	if err != nil {
+		return
+	}
+
+	for _, rule := range rules {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			cmd, append([]string{"-A", chain}, rule...)...,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, e := utils.ExecCombinedOutput(cmd, "-C", parent, "-j", chain)
+	if e != nil {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			cmd, "-I", parent, "1", "-j", chain,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func installIpt(allow *allowList) (err error) {
+	rulesIn, rulesOut := iptRules(false, allow.Addrs4, allow.Dns4,
+		allow.Ifaces)
+
+	err = installIptChain("iptables", "OUTPUT", iptChainOut, rulesOut)
+	if err != nil {
+		return
+	}
+	err = installIptChain("iptables", "INPUT", iptChainIn, rulesIn)
+	if err != nil {
+		return
+	}
+
+	rulesIn, rulesOut = iptRules(true, allow.Addrs6, allow.Dns6,
+		allow.Ifaces)
+
+	err = installIptChain("ip6tables", "OUTPUT", iptChainOut, rulesOut)
+	if err != nil {
+		return
+	}
+	err = installIptChain("ip6tables", "INPUT", iptChainIn, rulesIn)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func install(st *state, allow *allowList) (err error) {
+	if st.Backend == "iptables" {
+		err = installIpt(allow)
+	} else {
+		err = installNft(allow)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func removeIptChain(cmd, parent, chain string) {
+	for i := 0; i < 10; i++ {
+		_, e := utils.ExecCombinedOutput(cmd, "-D", parent, "-j", chain)
+		if e != nil {
+			break
+		}
+	}
+
+	_, _ = utils.ExecCombinedOutput(cmd, "-F", chain)
+	_, _ = utils.ExecCombinedOutput(cmd, "-X", chain)
+}
+
+func remove(st *state) (err error) {
+	if st.Backend != "nftables" {
+		for _, cmd := range []string{"iptables", "ip6tables"} {
+			removeIptChain(cmd, "OUTPUT", iptChainOut)
+			removeIptChain(cmd, "INPUT", iptChainIn)
+		}
+	}
+
+	if st.Backend != "iptables" {
+		_, e := exec.LookPath("nft")
+		if e != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"No such file or directory"},
+			"nft", "delete", "table", "inet", nftTable,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,701 @@
+package killswitch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fwGroup    = "Pritunl Kill Switch"
+	wfpBackend = "wfp"
+
+	rpcAuthnWinnt = 10
+
+	fwpUint8            = 1
+	fwpUint16           = 2
+	fwpUint32           = 3
+	fwpUint64           = 4
+	fwpByteArray16Type  = 11
+	fwpMatchEqual       = 0
+	fwpMatchFlagsAllSet = 6
+	fwpActionBlock      = 0x1001
+	fwpActionPermit     = 0x1002
+	fwpConditionLoop    = 0x1
+
+	fwpErrFilterNotFound   = 0x80320003
+	fwpErrSubLayerNotFound = 0x80320007
+	fwpErrAlreadyExists    = 0x80320009
+
+	wfpSubLayerWeight = 0xffff
+	wfpPermitWeight   = 15
+	wfpBlockWeight    = 0
+	wfpMaxFilters     = 256
+)
+
+var (
+	fwpuclnt                  = windows.NewLazySystemDLL("fwpuclnt.dll")
+	iphlpapi                  = windows.NewLazySystemDLL("iphlpapi.dll")
+	procFwpmEngineOpen0       = fwpuclnt.NewProc("FwpmEngineOpen0")
+	procFwpmEngineClose0      = fwpuclnt.NewProc("FwpmEngineClose0")
+	procFwpmTransactionBegin0 = fwpuclnt.NewProc("FwpmTransactionBegin0")
+	procFwpmTransactionCommit = fwpuclnt.NewProc("FwpmTransactionCommit0")
+	procFwpmTransactionAbort0 = fwpuclnt.NewProc("FwpmTransactionAbort0")
+	procFwpmSubLayerAdd0      = fwpuclnt.NewProc("FwpmSubLayerAdd0")
+	procFwpmSubLayerDelete    = fwpuclnt.NewProc("FwpmSubLayerDeleteByKey0")
+	procFwpmFilterAdd0        = fwpuclnt.NewProc("FwpmFilterAdd0")
+	procFwpmFilterDelete      = fwpuclnt.NewProc("FwpmFilterDeleteByKey0")
+	procConvertAliasToLuid    = iphlpapi.NewProc(
+		"ConvertInterfaceAliasToLuid")
+
+	wfpSubLayerKey = windows.GUID{
+		Data1: 0x7d1c3a0f,
+		Data2: 0x5b2e,
+		Data3: 0x4f7a,
+		Data4: [8]byte{0x9c, 0x41, 0x2d, 0x8e, 0x6f, 0x10, 0x00, 0x00},
+	}
+	layerAleAuthConnectV4 = windows.GUID{
+		Data1: 0xc38d57d1,
+		Data2: 0x05a7,
+		Data3: 0x4c33,
+		Data4: [8]byte{0x90, 0x4f, 0x7f, 0xbc, 0xee, 0xe6, 0x0e, 0x82},
+	}
+	layerAleAuthConnectV6 = windows.GUID{
+		Data1: 0x4a72393b,
+		Data2: 0x319f,
+		Data3: 0x44bc,
+		Data4: [8]byte{0x84, 0xc3, 0xba, 0x54, 0xdc, 0xb3, 0xb6, 0xb4},
+	}
+	conditionRemoteAddress = windows.GUID{
+		Data1: 0xb235ae9a,
+		Data2: 0x1d64,
+		Data3: 0x49b8,
+		Data4: [8]byte{0xa4, 0x4c, 0x5f, 0xf3, 0xd9, 0x09, 0x50, 0x45},
+	}
+	conditionLocalInterface = windows.GUID{
+		Data1: 0x4cd62a49,
+		Data2: 0x59c3,
+		Data3: 0x4969,
+		Data4: [8]byte{0xb7, 0xf3, 0xbd, 0xa5, 0xd3, 0x28, 0x90, 0xa4},
+	}
+	conditionRemotePort = windows.GUID{
+		Data1: 0xc35a604d,
+		Data2: 0xd22b,
+		Data3: 0x4e1a,
+		Data4: [8]byte{0x91, 0xb4, 0x68, 0xf6, 0x74, 0xee, 0x67, 0x4b},
+	}
+	conditionProtocol = windows.GUID{
+		Data1: 0x3971ef2b,
+		Data2: 0x623e,
+		Data3: 0x4f9a,
+		Data4: [8]byte{0x8c, 0xb1, 0x6e, 0x79, 0xb8, 0x06, 0xb9, 0xa7},
+	}
+	conditionFlags = windows.GUID{
+		Data1: 0x632ce23b,
+		Data2: 0x5167,
+		Data3: 0x435c,
+		Data4: [8]byte{0x86, 0xd7, 0xe9, 0x03, 0x68, 0x4a, 0xa8, 0x0c},
+	}
+)
+
+// Structure layouts follow the 64-bit Windows ABI used by the service build.
+type fwpmDisplayData0 struct {
+	Name        *uint16
+	Description *uint16
+}
+
+type fwpByteBlob struct {
+	Size uint32
+	Data *uint8
+}
+
+type fwpValue0 struct {
+	Type  uint32
+	Value uintptr
+}
+
+type fwpmFilterCondition0 struct {
+	FieldKey  windows.GUID
+	MatchType uint32
+	Value     fwpValue0
+}
+
+type fwpmAction0 struct {
+	Type uint32
+	Key  windows.GUID
+}
+
+type fwpmSubLayer0 struct {
+	SubLayerKey  windows.GUID
+	DisplayData  fwpmDisplayData0
+	Flags        uint32
+	ProviderKey  *windows.GUID
+	ProviderData fwpByteBlob
+	Weight       uint16
+}
+
+type fwpmFilter0 struct {
+	FilterKey           windows.GUID
+	DisplayData         fwpmDisplayData0
+	Flags               uint32
+	ProviderKey         *windows.GUID
+	ProviderData        fwpByteBlob
+	LayerKey            windows.GUID
+	SubLayerKey         windows.GUID
+	Weight              fwpValue0
+	NumFilterConditions uint32
+	FilterCondition     *fwpmFilterCondition0
+	Action              fwpmAction0
+	ProviderContextKey  [2]uint64
+	Reserved            *windows.GUID
+	FilterId            uint64
+	EffectiveWeight     fwpValue0
+}
+
+type wfpFilter struct {
+	layer      windows.GUID
+	action     uint32
+	weight     uint8
+	conditions []fwpmFilterCondition0
+}
+
+type wfpEngine struct {
+	handle  windows.Handle
+	keep    []interface{}
+	filters int
+}
+
+func wfpError(ret uintptr, msg string) error {
+	if ret == 0 {
+		return nil
+	}
+
+	return &errortypes.WriteError{
+		errors.Wrapf(windows.Errno(ret), "killswitch: %s (0x%x)",
+			msg, uint32(ret)),
+	}
+}
+
+func wfpFilterKey(index int) (key windows.GUID) {
+	key = wfpSubLayerKey
+	key.Data4[6] = byte(index >> 8)
+	key.Data4[7] = byte(index)
+	key.Data4[5] = 0x11
+	return
+}
+
+func openEngine() (engine *wfpEngine, err error) {
+	engine = &wfpEngine{}
+
+	ret, _, _ := procFwpmEngineOpen0.Call(
+		0,
+		rpcAuthnWinnt,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&engine.handle)),
+	)
+	err = wfpError(ret, "Failed to open filter engine")
+	if err != nil {
+		engine = nil
+		return
+	}
+
+	return
+}
+
+func (e *wfpEngine) Close() {
+	_, _, _ = procFwpmEngineClose0.Call(uintptr(e.handle))
+	runtime.KeepAlive(e.keep)
+	e.keep = nil
+}
+
+func (e *wfpEngine) begin() (err error) {
+	ret, _, _ := procFwpmTransactionBegin0.Call(uintptr(e.handle), 0)
+	err = wfpError(ret, "Failed to begin filter transaction")
+	return
+}
+
+func (e *wfpEngine) commit() (err error) {
+	ret, _, _ := procFwpmTransactionCommit.Call(uintptr(e.handle))
+	err = wfpError(ret, "Failed to commit filter transaction")
+	return
+}
+
+func (e *wfpEngine) abort() {
+	_, _, _ = procFwpmTransactionAbort0.Call(uintptr(e.handle))
+}
+
+func (e *wfpEngine) addSubLayer() (err error) {
+	name, _ := windows.UTF16PtrFromString(fwGroup)
+
+	subLayer := &fwpmSubLayer0{
+		SubLayerKey: wfpSubLayerKey,
+		DisplayData: fwpmDisplayData0{
+			Name: name,
+		},
+		Weight: wfpSubLayerWeight,
+	}
+
+	ret, _, _ := procFwpmSubLayerAdd0.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(subLayer)),
+		0,
+	)
+	runtime.KeepAlive(subLayer)
+	runtime.KeepAlive(name)
+	if ret == fwpErrAlreadyExists {
+		return
+	}
+	err = wfpError(ret, "Failed to add filter sublayer")
+	return
+}
+
+func (e *wfpEngine) deleteSubLayer() (err error) {
+	key := wfpSubLayerKey
+
+	ret, _, _ := procFwpmSubLayerDelete.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(&key)),
+	)
+	if ret == fwpErrSubLayerNotFound {
+		return
+	}
+	err = wfpError(ret, "Failed to remove filter sublayer")
+	return
+}
+
+func (e *wfpEngine) deleteFilters(count int) (err error) {
+	for i := 0; i < count; i++ {
+		key := wfpFilterKey(i)
+
+		ret, _, _ := procFwpmFilterDelete.Call(
+			uintptr(e.handle),
+			uintptr(unsafe.Pointer(&key)),
+		)
+		if ret == fwpErrFilterNotFound {
+			continue
+		}
+		err = wfpError(ret, "Failed to remove filter")
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (e *wfpEngine) addFilter(fltr *wfpFilter) (err error) {
+	name, _ := windows.UTF16PtrFromString(fwGroup)
+
+	filter := &fwpmFilter0{
+		FilterKey: wfpFilterKey(e.filters),
+		DisplayData: fwpmDisplayData0{
+			Name: name,
+		},
+		LayerKey:    fltr.layer,
+		SubLayerKey: wfpSubLayerKey,
+		Weight: fwpValue0{
+			Type:  fwpUint8,
+			Value: uintptr(fltr.weight),
+		},
+		Action: fwpmAction0{
+			Type: fltr.action,
+		},
+	}
+
+	if len(fltr.conditions) > 0 {
+		filter.NumFilterConditions = uint32(len(fltr.conditions))
+		filter.FilterCondition = &fltr.conditions[0]
+	}
+
+	var filterId uint64
+	ret, _, _ := procFwpmFilterAdd0.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(filter)),
+		0,
+		uintptr(unsafe.Pointer(&filterId)),
+	)
+	runtime.KeepAlive(filter)
+	runtime.KeepAlive(fltr)
+	runtime.KeepAlive(name)
+	err = wfpError(ret, "Failed to add filter")
+	if err != nil {
+		return
+	}
+
+	e.filters += 1
+
+	return
+}
+
+func (e *wfpEngine) ptr(val interface{}) uintptr {
+	e.keep = append(e.keep, val)
+
+	switch v := val.(type) {
+	case *uint64:
+		return uintptr(unsafe.Pointer(v))
+	case *[16]byte:
+		return uintptr(unsafe.Pointer(v))
+	}
+
+	panic("killswitch: Unknown condition value")
+}
+
+func (e *wfpEngine) addrConditions(addrs []string, ipv6 bool) (
+	conds []fwpmFilterCondition0) {
+
+	conds = []fwpmFilterCondition0{}
+
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+
+		cond := fwpmFilterCondition0{
+			FieldKey:  conditionRemoteAddress,
+			MatchType: fwpMatchEqual,
+		}
+
+		if ipv6 {
+			ip6 := &[16]byte{}
+			copy(ip6[:], ip.To16())
+			cond.Value = fwpValue0{
+				Type:  fwpByteArray16Type,
+				Value: e.ptr(ip6),
+			}
+		} else {
+			cond.Value = fwpValue0{
+				Type:  fwpUint32,
+				Value: uintptr(binary.BigEndian.Uint32(ip.To4())),
+			}
+		}
+
+		conds = append(conds, cond)
+	}
+
+	return
+}
+
+func ifaceLuid(iface string) (luid uint64, err error) {
+	alias, err := windows.UTF16PtrFromString(iface)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "killswitch: Invalid interface name"),
+		}
+		return
+	}
+
+	ret, _, _ := procConvertAliasToLuid.Call(
+		uintptr(unsafe.Pointer(alias)),
+		uintptr(unsafe.Pointer(&luid)),
+	)
+	runtime.KeepAlive(alias)
+	if ret != 0 {
+		err = &errortypes.NotFoundError{
+			errors.Wrapf(windows.Errno(ret),
+				"killswitch: Failed to find interface '%s'", iface),
+		}
+		return
+	}
+
+	return
+}
+
+func (e *wfpEngine) layerFilters(allow *allowList, ifaceConds []fwpmFilterCondition0,
+	layer windows.GUID, ipv6 bool) (filters []*wfpFilter) {
+
+	addrs := allow.Addrs4
+	dnsAddrs := allow.Dns4
+	dhcpPort := uintptr(67)
+	if ipv6 {
+		addrs = allow.Addrs6
+		dnsAddrs = allow.Dns6
+		dhcpPort = 547
+	}
+
+	filters = []*wfpFilter{
+		{
+			layer:  layer,
+			action: fwpActionBlock,
+			weight: wfpBlockWeight,
+		},
+		{
+			layer:  layer,
+			action: fwpActionPermit,
+			weight: wfpPermitWeight,
+			conditions: []fwpmFilterCondition0{
+				{
+					FieldKey:  conditionFlags,
+					MatchType: fwpMatchFlagsAllSet,
+					Value: fwpValue0{
+						Type:  fwpUint32,
+						Value: fwpConditionLoop,
+					},
+				},
+			},
+		},
+		{
+			layer:  layer,
+			action: fwpActionPermit,
+			weight: wfpPermitWeight,
+			conditions: []fwpmFilterCondition0{
+				{
+					FieldKey:  conditionProtocol,
+					MatchType: fwpMatchEqual,
+					Value: fwpValue0{
+						Type:  fwpUint8,
+						Value: 17,
+					},
+				},
+				{
+					FieldKey:  conditionRemotePort,
+					MatchType: fwpMatchEqual,
+					Value: fwpValue0{
+						Type:  fwpUint16,
+						Value: dhcpPort,
+					},
+				},
+			},
+		},
+	}
+
+	if len(ifaceConds) > 0 {
+		filters = append(filters, &wfpFilter{
+			layer:      layer,
+			action:     fwpActionPermit,
+			weight:     wfpPermitWeight,
+			conditions: ifaceConds,
+		})
+	}
+
+	addrConds := e.addrConditions(addrs, ipv6)
+	if len(addrConds) > 0 {
+		filters = append(filters, &wfpFilter{
+			layer:      layer,
+			action:     fwpActionPermit,
+			weight:     wfpPermitWeight,
+			conditions: addrConds,
+		})
+	}
+
+	dnsConds := e.addrConditions(dnsAddrs, ipv6)
+	if len(dnsConds) > 0 {
+		filters = append(filters, &wfpFilter{
+			layer:  layer,
+			action: fwpActionPermit,
+			weight: wfpPermitWeight,
+			conditions: append(dnsConds, fwpmFilterCondition0{
+				FieldKey:  conditionRemotePort,
+				MatchType: fwpMatchEqual,
+				Value: fwpValue0{
+					Type:  fwpUint16,
+					Value: 53,
+				},
+			}),
+		})
+	}
+
+	return
+}
+
+func prepare(st *state) (err error) {
+	st.Backend = wfpBackend
+	st.Policy = nil
+
+	return
+}
+
+func install(st *state, allow *allowList) (err error) {
+	if len(st.Policy) > 0 {
+		err = removePolicy(st)
+		if err != nil {
+			return
+		}
+		st.Policy = nil
+	}
+
+	engine, err := openEngine()
+	if err != nil {
+		return
+	}
+	defer engine.Close()
+
+	ifaceConds := []fwpmFilterCondition0{}
+	for _, iface := range allow.Ifaces {
+		luid, e := ifaceLuid(iface)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"iface": iface,
+				"error": e,
+			}).Error("killswitch: Failed to allow interface")
+			continue
+		}
+
+		ifaceConds = append(ifaceConds, fwpmFilterCondition0{
+			FieldKey:  conditionLocalInterface,
+			MatchType: fwpMatchEqual,
+			Value: fwpValue0{
+				Type:  fwpUint64,
+				Value: engine.ptr(&luid),
+			},
+		})
+	}
+
+	filters := engine.layerFilters(allow, ifaceConds,
+		layerAleAuthConnectV4, false)
+	filters = append(filters, engine.layerFilters(allow, ifaceConds,
+		layerAleAuthConnectV6, true)...)
+
+	if len(filters) > wfpMaxFilters {
+		err = &errortypes.WriteError{
+			errors.New("killswitch: Too many filters"),
+		}
+		return
+	}
+
+	prevFilters := st.Filters
+	if len(filters) > st.Filters {
+		st.Filters = len(filters)
+		err = saveState(st)
+		if err != nil {
+			return
+		}
+	}
+
+	err = engine.begin()
+	if err != nil {
+		return
+	}
+
+	err = engine.deleteFilters(prevFilters)
+	if err != nil {
+		engine.abort()
+		return
+	}
+
+	err = engine.addSubLayer()
+	if err != nil {
+		engine.abort()
+		return
+	}
+
+	for _, filter := range filters {
+		err = engine.addFilter(filter)
+		if err != nil {
+			engine.abort()
+			return
+		}
+	}
+
+	err = engine.commit()
+	if err != nil {
+		return
+	}
+
+	st.Filters = len(filters)
+
+	return
+}
+
+func removePolicy(st *state) (err error) {
+	script := []string{
+		fmt.Sprintf("Remove-NetFirewallRule -Group %s "+
+			"-ErrorAction SilentlyContinue", psQuote(fwGroup)),
+	}
+
+	for name, policy := range st.Policy {
+		fields := strings.Fields(policy)
+		if len(fields) != 2 {
+			continue
+		}
+
+		script = append(script, fmt.Sprintf(
+			"Set-NetFirewallProfile -Name %s "+
+				"-DefaultOutboundAction %s -Enabled %s",
+			psQuote(name), fields[0], fields[1]))
+	}
+
+	_, err = powershell(strings.Join(script, "; "))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func remove(st *state) (err error) {
+	if len(st.Policy) > 0 {
+		err = removePolicy(st)
+		if err != nil {
+			return
+		}
+		st.Policy = nil
+	}
+
+	engine, err := openEngine()
+	if err != nil {
+		return
+	}
+	defer engine.Close()
+
+	count := st.Filters
+	if count == 0 {
+		count = wfpMaxFilters
+	}
+
+	err = engine.begin()
+	if err != nil {
+		return
+	}
+
+	err = engine.deleteFilters(count)
+	if err != nil {
+		engine.abort()
+		return
+	}
+
+	err = engine.deleteSubLayer()
+	if err != nil {
+		engine.abort()
+		return
+	}
+
+	err = engine.commit()
+	if err != nil {
+		return
+	}
+
+	st.Filters = 0
+
+	return
+}
+
+func powershell(script string) (output string, err error) {
+	output, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"powershell.exe",
+		"-NoProfile",
+		"-NonInteractive",
+		"-Command",
+		script,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func psQuote(val string) string {
+	return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/dropbox/godropbox/errors"
@@ -9,8 +10,35 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	logMaxSize   = 1000000
+	logRetention = 5
+)
+
 type fileSender struct{}
 
+func rotateLogs() (err error) {
+	logPth := utils.GetLogPath()
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", logPth, logRetention))
+	for i := logRetention - 1; i > 0; i-- {
+		_ = os.Rename(
+			fmt.Sprintf("%s.%d", logPth, i),
+			fmt.Sprintf("%s.%d", logPth, i+1),
+		)
+	}
+
+	err = os.Rename(logPth, utils.GetLogPath2())
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "logger: Failed to rotate log file"),
+		}
+		return
+	}
+
+	return
+}
+
 func (s *fileSender) Init() {}
 
 func (s *fileSender) Parse(entry *logrus.Entry) {
@@ -43,13 +71,9 @@ func (s *fileSender) send(entry *logrus.Entry) (err error) {
 		return
 	}
 
-	if stat.Size() >= 200000 {
-		os.Remove(utils.GetLogPath2())
-		err = os.Rename(utils.GetLogPath(), utils.GetLogPath2())
+	if stat.Size() >= logMaxSize {
+		err = rotateLogs()
 		if err != nil {
-			err = &errortypes.WriteError{
-				errors.Wrap(err, "logger: Failed to rotate log file"),
-			}
 			return
 		}
 
@@ -22,6 +22,7 @@ func (h *logHook) Fire(entry *logrus.Entry) (err error) {
 
 func (h *logHook) Levels() []logrus.Level {
 	return []logrus.Level{
+		logrus.DebugLevel,
 		logrus.InfoLevel,
 		logrus.WarnLevel,
 		logrus.ErrorLevel,
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const levelEnv = "PRITUNL_LOG_LEVEL"
+
+func getLevel() (level logrus.Level) {
+	level = logrus.InfoLevel
+
+	levelStr := strings.ToLower(strings.TrimSpace(os.Getenv(levelEnv)))
+	switch levelStr {
+	case "":
+		break
+	case "debug":
+		level = logrus.DebugLevel
+		break
+	case "info":
+		level = logrus.InfoLevel
+		break
+	case "warn", "warning":
+		level = logrus.WarnLevel
+		break
+	case "error":
+		level = logrus.ErrorLevel
+		break
+	default:
+		logrus.WithFields(logrus.Fields{
+			"level": levelStr,
+		}).Warn("logger: Unknown log level, using info")
+		break
+	}
+
+	return
+}
@@ -2,6 +2,7 @@
 package logger
 
 import (
+	"os"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -38,5 +39,11 @@ func Init() {
 	logrus.SetFormatter(&formatter{})
 	logrus.AddHook(&logHook{})
 	logrus.SetOutput(&Writer{})
-	logrus.SetLevel(logrus.InfoLevel)
+	logrus.SetLevel(getLevel())
+}
+
+func InitStdout() {
+	logrus.SetFormatter(&formatter{})
+	logrus.SetOutput(os.Stdout)
+	logrus.SetLevel(getLevel())
 }
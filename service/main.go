@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"flag"
-	"net"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,16 +14,17 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-client-electron/service/auth"
 	"github.com/pritunl/pritunl-client-electron/service/autoclean"
+	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/pritunl/pritunl-client-electron/service/constants"
-	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/handlers"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
 	"github.com/pritunl/pritunl-client-electron/service/logger"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
 	"github.com/pritunl/pritunl-client-electron/service/setup"
+	"github.com/pritunl/pritunl-client-electron/service/socket"
 	"github.com/pritunl/pritunl-client-electron/service/tuntap"
 	"github.com/pritunl/pritunl-client-electron/service/update"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
@@ -32,19 +33,67 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	shutdownTimeout = 15 * time.Second
+)
+
 func main() {
 	install := flag.Bool("install", false, "run post install")
+	serviceAccount := flag.String("service-account", "",
+		"service account for install")
+	servicePasswordStdin := flag.Bool("service-password-stdin", false,
+		"read service account password for install from stdin")
 	uninstall := flag.Bool("uninstall", false, "run pre uninstall")
+	dryRun := flag.Bool("dry-run", false,
+		"print install commands without running")
 	devPtr := flag.Bool("dev", false, "development mode")
+	versionPtr := flag.Bool("version", false, "print version and exit")
 	flag.Parse()
 
+	if *versionPtr {
+		fmt.Printf("Pritunl Service v%s\n", constants.Version)
+		fmt.Printf("Commit: %s\n", constants.Commit)
+		fmt.Printf("Build Date: %s\n", constants.BuildDate)
+		fmt.Printf("Go Version: %s\n", runtime.Version())
+		return
+	}
+
+	if *dryRun {
+		setup.DryRun = true
+	}
+
 	if *install {
-		setup.Install()
+		logger.InitStdout()
+
+		password, err := setup.ReadPassword(os.Stdin, *servicePasswordStdin)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("main: Failed to read service password")
+			os.Exit(1)
+		}
+
+		err = setup.InstallWithAccount(*serviceAccount, password)
+		password = ""
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("main: Failed to install")
+			os.Exit(1)
+		}
 		return
 	}
 
 	if *uninstall {
-		setup.Uninstall()
+		logger.InitStdout()
+
+		err := setup.Uninstall()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("main: Failed to uninstall")
+			os.Exit(1)
+		}
 		return
 	}
 
@@ -78,7 +127,10 @@ func main() {
 	logger.Init()
 
 	logrus.WithFields(logrus.Fields{
-		"version": constants.Version,
+		"version":    constants.Version,
+		"commit":     constants.Commit,
+		"build_date": constants.BuildDate,
+		"go_version": runtime.Version(),
 	}).Info("main: Service starting")
 
 	_ = update.Check()
@@ -94,6 +146,14 @@ func main() {
 		}
 	}()
 
+	err = config.Load()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to load settings")
+		err = nil
+	}
+
 	err = auth.Init()
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -120,6 +180,14 @@ func main() {
 		}
 	}
 
+	err = killswitch.Clean()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to clean kill switch")
+		err = nil
+	}
+
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
@@ -157,24 +225,8 @@ func main() {
 				panic(err)
 			}
 		} else {
-			_ = os.Remove("/var/run/pritunl.sock")
-
-			listener, err := net.Listen("unix", "/var/run/pritunl.sock")
+			listener, err := socket.Listen("/var/run/pritunl.sock")
 			if err != nil {
-				err = &errortypes.WriteError{
-					errors.Wrap(err, "main: Failed to create unix socket"),
-				}
-				logrus.WithFields(logrus.Fields{
-					"error": err,
-				}).Error("main: Server error")
-				panic(err)
-			}
-
-			err = os.Chmod("/var/run/pritunl.sock", 0777)
-			if err != nil {
-				err = &errortypes.WriteError{
-					errors.Wrap(err, "main: Failed to chmod unix socket"),
-				}
 				logrus.WithFields(logrus.Fields{
 					"error": err,
 				}).Error("main: Server error")
@@ -186,9 +238,15 @@ func main() {
 	}()
 
 	profile.WatchSystemProfiles()
+	handlers.RestoreProfiles()
 
 	if winsvc.IsWindowsService() {
-		service := winsvc.New()
+		service := winsvc.New(func() {
+			logrus.Info("main: Service stop requested")
+
+			profile.Shutdown()
+			profile.StopAll(shutdownTimeout)
+		})
 
 		err = service.Run()
 		if err != nil {
@@ -200,7 +258,11 @@ func main() {
 	} else {
 		sig := make(chan os.Signal, 2)
 		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-		<-sig
+		recvSig := <-sig
+
+		logrus.WithFields(logrus.Fields{
+			"signal": recvSig.String(),
+		}).Info("main: Service stop requested")
 	}
 
 	webCtx, webCancel := context.WithTimeout(
@@ -220,15 +282,7 @@ func main() {
 	time.Sleep(250 * time.Millisecond)
 
 	profile.Shutdown()
-
-	prfls := profile.GetProfiles()
-	for _, prfl := range prfls {
-		prfl.StopBackground()
-	}
-
-	for _, prfl := range prfls {
-		prfl.Wait()
-	}
+	profile.StopAll(shutdownTimeout)
 
 	time.Sleep(750 * time.Millisecond)
 }
@@ -48,6 +48,70 @@ type Ovpn struct {
 	Key               string
 
 	DisableGateway bool
+	ExcludeRoutes  []string
+	BlockIpv6      bool
+	RemotePorts    []int
+}
+
+func (o *Ovpn) remotePorts(remotes []Remote) (ported []Remote) {
+	ported = []Remote{}
+	exists := map[Remote]bool{}
+
+	for _, remote := range remotes {
+		if !exists[remote] {
+			exists[remote] = true
+			ported = append(ported, remote)
+		}
+	}
+
+	for _, port := range o.RemotePorts {
+		if port <= 0 || port > 65535 {
+			continue
+		}
+
+		for _, remote := range remotes {
+			remote.Port = port
+			if !exists[remote] {
+				exists[remote] = true
+				ported = append(ported, remote)
+			}
+		}
+	}
+
+	return
+}
+
+func (o *Ovpn) remotes() (remotes []Remote) {
+	if !o.BlockIpv6 {
+		return o.remotePorts(o.Remotes)
+	}
+
+	remotes = []Remote{}
+	for _, remote := range o.Remotes {
+		switch remote.Proto {
+		case "udp":
+			remote.Proto = "udp4"
+			break
+		case "tcp":
+			remote.Proto = "tcp4"
+			break
+		case "tcp-client":
+			remote.Proto = "tcp4-client"
+			break
+		case "udp6", "tcp6", "tcp6-client":
+			continue
+		}
+
+		remotes = append(remotes, remote)
+	}
+
+	if len(remotes) == 0 {
+		remotes = o.Remotes
+	}
+
+	remotes = o.remotePorts(remotes)
+
+	return
 }
 
 func (o *Ovpn) Export() string {
@@ -63,7 +127,7 @@ func (o *Ovpn) Export() string {
 	output += fmt.Sprintf("dev %s\n", o.Dev)
 	output += fmt.Sprintf("dev-type %s\n", o.DevType)
 	output += "single-session\n"
-	for _, remote := range o.Remotes {
+	for _, remote := range o.remotes() {
 		output += fmt.Sprintf(
 			"remote %s %d %s\n",
 			remote.Host,
@@ -74,6 +138,9 @@ func (o *Ovpn) Export() string {
 	if o.RemoteRandom {
 		output += "remote-random\n"
 	}
+	if len(o.RemotePorts) > 0 {
+		output += "connect-retry 2 10\n"
+	}
 	if o.NoBind {
 		output += "nobind\n"
 	}
@@ -106,6 +173,8 @@ func (o *Ovpn) Export() string {
 	}
 	if o.ServerPollTimeout > 0 {
 		output += fmt.Sprintf("server-poll-timeout %d\n", o.ServerPollTimeout)
+	} else if len(o.RemotePorts) > 0 {
+		output += "server-poll-timeout 10\n"
 	}
 	if o.RenegSec > 0 {
 		output += fmt.Sprintf("reneg-sec %d\n", o.RenegSec)
@@ -142,6 +211,14 @@ func (o *Ovpn) Export() string {
 	if o.DisableGateway {
 		output += "pull-filter ignore \"redirect-gateway\"\n"
 	}
+	for _, route := range o.ExcludeRoutes {
+		output += fmt.Sprintf("route %s net_gateway\n", route)
+	}
+	if o.BlockIpv6 {
+		output += "pull-filter ignore \"ifconfig-ipv6\"\n"
+		output += "pull-filter ignore \"route-ipv6\"\n"
+		output += "pull-filter ignore \"dhcp-option DNS6\"\n"
+	}
 
 	if o.CaCert != "" {
 		output += fmt.Sprintf("<ca>\n%s</ca>\n", o.CaCert)
@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRemotePorts(t *testing.T) {
+	prfl := &Ovpn{
+		Remotes: []Remote{
+			{Host: "10.0.0.1", Port: 1194, Proto: "udp"},
+			{Host: "10.0.0.2", Port: 1194, Proto: "udp"},
+		},
+		RemotePorts: []int{443, 1194, 0},
+	}
+
+	remotes := prfl.remotes()
+	expected := []Remote{
+		{Host: "10.0.0.1", Port: 1194, Proto: "udp"},
+		{Host: "10.0.0.2", Port: 1194, Proto: "udp"},
+		{Host: "10.0.0.1", Port: 443, Proto: "udp"},
+		{Host: "10.0.0.2", Port: 443, Proto: "udp"},
+	}
+	if len(remotes) != len(expected) {
+		t.Fatalf("unexpected remotes %v", remotes)
+	}
+	for i := range expected {
+		if remotes[i] != expected[i] {
+			t.Errorf("remote %d expected %v got %v",
+				i, expected[i], remotes[i])
+		}
+	}
+
+	output := prfl.Export()
+	if !strings.Contains(output, "connect-retry 2 10\n") {
+		t.Error("missing connect-retry with remote ports")
+	}
+	if !strings.Contains(output, "server-poll-timeout 10\n") {
+		t.Error("missing server-poll-timeout with remote ports")
+	}
+}
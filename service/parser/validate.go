@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+var (
+	validProtos = map[string]bool{
+		"udp":         true,
+		"udp4":        true,
+		"udp6":        true,
+		"udp-client":  true,
+		"udp6-client": true,
+		"tcp":         true,
+		"tcp4":        true,
+		"tcp6":        true,
+		"tcp-client":  true,
+		"tcp4-client": true,
+		"tcp6-client": true,
+	}
+	inlineBlocks = map[string]bool{
+		"ca":           true,
+		"cert":         true,
+		"key":          true,
+		"extra-certs":  true,
+		"dh":           true,
+		"pkcs12":       true,
+		"secret":       true,
+		"tls-auth":     true,
+		"tls-crypt":    true,
+		"tls-crypt-v2": true,
+		"connection":   true,
+	}
+	pemBlocks = map[string]bool{
+		"ca":          true,
+		"cert":        true,
+		"key":         true,
+		"extra-certs": true,
+	}
+	exclusiveOptions = [][2]string{
+		{"tls-auth", "tls-crypt"},
+		{"tls-auth", "tls-crypt-v2"},
+		{"tls-crypt", "tls-crypt-v2"},
+		{"pkcs12", "cert"},
+		{"pkcs12", "key"},
+		{"client", "server"},
+	}
+)
+
+type ValidationIssue struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	errors.DropboxError
+	Issues []*ValidationIssue
+}
+
+func Validate(data string) (err error) {
+	issues := []*ValidationIssue{}
+	addIssue := func(line int, format string, args ...interface{}) {
+		issues = append(issues, &ValidationIssue{
+			Line:    line,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	options := map[string]int{}
+	remotes := 0
+	block := ""
+	blockLine := 0
+	blockData := ""
+
+	for i, line := range strings.Split(data, "\n") {
+		n := i + 1
+		line = strings.TrimSpace(line)
+
+		if block != "" {
+			if line == "</"+block+">" {
+				if pemBlocks[block] && (!strings.Contains(
+					blockData, "-----BEGIN") || !strings.Contains(
+					blockData, "-----END")) {
+
+					addIssue(blockLine, "Malformed <%s> block", block)
+				}
+
+				options[block] = blockLine
+				block = ""
+				blockData = ""
+			} else if strings.HasPrefix(line, "<") &&
+				!strings.HasPrefix(line, "</") &&
+				strings.HasSuffix(line, ">") &&
+				inlineBlocks[strings.Trim(line, "<>")] {
+
+				addIssue(blockLine, "Unterminated <%s> block", block)
+				block = strings.Trim(line, "<>")
+				blockLine = n
+				blockData = ""
+			} else if strings.HasPrefix(line, "</") {
+				addIssue(n, "Unexpected %s in <%s> block", line, block)
+			} else {
+				blockData += line + "\n"
+			}
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, ";") {
+
+			continue
+		}
+
+		if strings.HasPrefix(line, "</") {
+			addIssue(n, "Unexpected %s without opening tag", line)
+			continue
+		}
+
+		if strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">") {
+			name := strings.Trim(line, "<>")
+			if !inlineBlocks[name] {
+				addIssue(n, "Unknown inline block <%s>", name)
+				continue
+			}
+
+			block = name
+			blockLine = n
+			continue
+		}
+
+		fields := strings.Fields(line)
+		name := fields[0]
+		if _, ok := options[name]; !ok {
+			options[name] = n
+		}
+
+		switch name {
+		case "remote":
+			remotes += 1
+			if len(fields) < 2 {
+				addIssue(n, "Remote missing host")
+			} else if len(fields) >= 4 && !validProtos[fields[3]] {
+				addIssue(n, "Invalid remote proto '%s'", fields[3])
+			}
+			break
+		case "proto":
+			if len(fields) < 2 || !validProtos[fields[1]] {
+				addIssue(n, "Invalid proto '%s'",
+					strings.Join(fields[1:], " "))
+			}
+			break
+		case "dev":
+			if len(fields) < 2 || (!strings.HasPrefix(fields[1], "tun") &&
+				!strings.HasPrefix(fields[1], "tap")) {
+
+				addIssue(n, "Invalid dev '%s'",
+					strings.Join(fields[1:], " "))
+			}
+			break
+		}
+	}
+
+	if block != "" {
+		addIssue(blockLine, "Unterminated <%s> block", block)
+	}
+
+	if remotes == 0 {
+		addIssue(0, "Missing required remote directive")
+	}
+	if _, ok := options["dev"]; !ok {
+		addIssue(0, "Missing required dev directive")
+	}
+
+	for _, pair := range exclusiveOptions {
+		line1, ok1 := options[pair[0]]
+		line2, ok2 := options[pair[1]]
+		if ok1 && ok2 {
+			line := line1
+			if line2 > line {
+				line = line2
+			}
+			addIssue(line, "Options %s and %s are mutually exclusive",
+				pair[0], pair[1])
+		}
+	}
+
+	if len(issues) == 0 {
+		return
+	}
+
+	msgs := []string{}
+	for _, issue := range issues {
+		if issue.Line > 0 {
+			msgs = append(msgs, fmt.Sprintf(
+				"line %d: %s", issue.Line, issue.Message))
+		} else {
+			msgs = append(msgs, issue.Message)
+		}
+	}
+
+	err = &ValidationError{
+		DropboxError: errors.Newf("parser: Invalid configuration, %s",
+			strings.Join(msgs, "; ")),
+		Issues: issues,
+	}
+	return
+}
@@ -2,6 +2,7 @@ package platform
 
 import (
 	"os"
+	"syscall"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
@@ -66,3 +67,37 @@ func MkdirReadSecure(pth string) (err error) {
 
 	return
 }
+
+func LockFile(file *os.File, exclusive bool) (err error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "utils: Failed to lock file"),
+		}
+		return
+	}
+
+	return
+}
+
+func UnlockFile(file *os.File) (err error) {
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "utils: Failed to unlock file"),
+		}
+		return
+	}
+
+	return
+}
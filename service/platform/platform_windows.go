@@ -49,3 +49,45 @@ func MkdirReadSecure(pth string) (err error) {
 
 	return
 }
+
+func LockFile(file *os.File, exclusive bool) (err error) {
+	flags := uint32(0)
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	err = windows.LockFileEx(
+		windows.Handle(file.Fd()),
+		flags,
+		0,
+		1,
+		0,
+		&windows.Overlapped{},
+	)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "utils: Failed to lock file"),
+		}
+		return
+	}
+
+	return
+}
+
+func UnlockFile(file *os.File) (err error) {
+	err = windows.UnlockFileEx(
+		windows.Handle(file.Fd()),
+		0,
+		1,
+		0,
+		&windows.Overlapped{},
+	)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "utils: Failed to unlock file"),
+		}
+		return
+	}
+
+	return
+}
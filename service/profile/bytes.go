@@ -0,0 +1,344 @@
+package profile
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	bytesIntervalDefault = 1 * time.Second
+	bytesWindow          = 5
+)
+
+type BytesData struct {
+	Id      string  `json:"id"`
+	RxBytes uint64  `json:"rxBytes"`
+	TxBytes uint64  `json:"txBytes"`
+	RxRate  float64 `json:"rxRate"`
+	TxRate  float64 `json:"txRate"`
+}
+
+type byteSample struct {
+	total     uint64
+	timestamp time.Time
+}
+
+type byteCounter struct {
+	init    bool
+	last    uint64
+	total   uint64
+	samples []byteSample
+}
+
+func (c *byteCounter) Update(raw uint64, timestamp time.Time) {
+	if !c.init {
+		c.init = true
+		c.last = raw
+	}
+
+	delta := uint64(0)
+	if raw >= c.last {
+		delta = raw - c.last
+	} else if c.last <= math.MaxUint32 && c.last-raw > math.MaxUint32/2 {
+		delta = raw + (math.MaxUint32 + 1) - c.last
+	} else {
+		delta = raw
+	}
+	c.last = raw
+	c.total += delta
+
+	c.samples = append(c.samples, byteSample{
+		total:     c.total,
+		timestamp: timestamp,
+	})
+	if len(c.samples) > bytesWindow {
+		c.samples = c.samples[len(c.samples)-bytesWindow:]
+	}
+}
+
+func (c *byteCounter) Total() uint64 {
+	return c.total
+}
+
+func (c *byteCounter) Rate() float64 {
+	if len(c.samples) < 2 {
+		return 0
+	}
+
+	first := c.samples[0]
+	last := c.samples[len(c.samples)-1]
+
+	duration := last.timestamp.Sub(first.timestamp).Seconds()
+	if duration <= 0 {
+		return 0
+	}
+
+	return float64(last.total-first.total) / duration
+}
+
+func (p *Profile) bytesInterval() time.Duration {
+	if p.BytesInterval > 0 {
+		return time.Duration(p.BytesInterval) * time.Millisecond
+	}
+	return bytesIntervalDefault
+}
+
+func (p *Profile) readBytesWg(iface string) (rx, tx uint64, err error) {
+	output, err := utils.ExecCombinedOutputLogged(
+		[]string{
+			"No such device",
+			"access interface",
+		},
+		p.wgPath, "show", iface, "transfer",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+
+		peerRx, e := strconv.ParseUint(fields[1], 10, 64)
+		if e != nil {
+			continue
+		}
+		peerTx, e := strconv.ParseUint(fields[2], 10, 64)
+		if e != nil {
+			continue
+		}
+
+		rx += peerRx
+		tx += peerTx
+	}
+
+	return
+}
+
+func readBytesLinux(iface string) (rx, tx uint64, err error) {
+	pth := filepath.Join("/sys/class/net", iface, "statistics")
+
+	rxData, err := ioutil.ReadFile(filepath.Join(pth, "rx_bytes"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to read interface rx bytes"),
+		}
+		return
+	}
+
+	txData, err := ioutil.ReadFile(filepath.Join(pth, "tx_bytes"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to read interface tx bytes"),
+		}
+		return
+	}
+
+	rx, err = strconv.ParseUint(strings.TrimSpace(string(rxData)), 10, 64)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "profile: Failed to parse interface rx bytes"),
+		}
+		return
+	}
+
+	tx, err = strconv.ParseUint(strings.TrimSpace(string(txData)), 10, 64)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "profile: Failed to parse interface tx bytes"),
+		}
+		return
+	}
+
+	return
+}
+
+func parseBytesMac(output string) (rx, tx uint64, err error) {
+	rxIndex := -1
+	txIndex := -1
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+
+		if rxIndex == -1 {
+			for i, field := range fields {
+				if field == "Ibytes" {
+					rxIndex = len(fields) - i
+				} else if field == "Obytes" {
+					txIndex = len(fields) - i
+				}
+			}
+			continue
+		}
+
+		// Link rows have no address column, index from the right
+		if len(fields) < 3 || len(fields) < rxIndex ||
+			len(fields) < txIndex ||
+			!strings.HasPrefix(fields[2], "<Link#") {
+
+			continue
+		}
+
+		rx, _ = strconv.ParseUint(fields[len(fields)-rxIndex], 10, 64)
+		tx, _ = strconv.ParseUint(fields[len(fields)-txIndex], 10, 64)
+		return
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("profile: Failed to find interface bytes"),
+	}
+	return
+}
+
+func readBytesMac(iface string) (rx, tx uint64, err error) {
+	output, err := utils.ExecCombinedOutputLogged(
+		nil,
+		"netstat", "-ibn", "-I", iface,
+	)
+	if err != nil {
+		return
+	}
+
+	rx, tx, err = parseBytesMac(output)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func readBytesWin(iface string) (rx, tx uint64, err error) {
+	output, err := utils.ExecCombinedOutputLogged(
+		nil,
+		"netsh", "interface", "ipv4", "show", "subinterfaces",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 ||
+			strings.Join(fields[4:], " ") != iface {
+
+			continue
+		}
+
+		rx, _ = strconv.ParseUint(fields[2], 10, 64)
+		tx, _ = strconv.ParseUint(fields[3], 10, 64)
+		return
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("profile: Failed to find interface bytes"),
+	}
+	return
+}
+
+func (p *Profile) readBytes() (rx, tx uint64, err error) {
+	if p.Mode == Wg {
+		rx, tx, err = p.readBytesWg(p.wgTunIface())
+		return
+	}
+
+	iface := p.ovpnIface
+	if iface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Unknown tunnel interface"),
+		}
+		return
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		rx, tx, err = readBytesLinux(iface)
+		break
+	case "darwin":
+		rx, tx, err = readBytesMac(iface)
+		break
+	case "windows":
+		rx, tx, err = readBytesWin(iface)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+
+	return
+}
+
+func (p *Profile) watchBytes() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	rxCounter := &byteCounter{}
+	txCounter := &byteCounter{}
+	interval := p.bytesInterval()
+	failed := false
+
+	for {
+		if p.stop || p.stopping || !p.connected {
+			return
+		}
+
+		rx, tx, err := p.readBytes()
+		if err != nil {
+			if !failed {
+				failed = true
+				logrus.WithFields(logrus.Fields{
+					"profile_id": p.Id,
+					"error":      err,
+				}).Warn("profile: Failed to read tunnel byte counters")
+			}
+		} else {
+			now := time.Now()
+			rxCounter.Update(rx, now)
+			txCounter.Update(tx, now)
+			p.rxBytes = rxCounter.Total()
+			p.txBytes = txCounter.Total()
+
+			evt := event.Event{
+				Type:      "bytes_update",
+				ProfileId: p.Id,
+			}
+			evt.Init(&BytesData{
+				Id:      p.Id,
+				RxBytes: rxCounter.Total(),
+				TxBytes: txCounter.Total(),
+				RxRate:  rxCounter.Rate(),
+				TxRate:  txCounter.Rate(),
+			})
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (p *Profile) watchBytesBackground() {
+	if p.bytesWatch {
+		return
+	}
+	p.bytesWatch = true
+
+	go p.watchBytes()
+}
@@ -0,0 +1,35 @@
+package profile
+
+import (
+	"testing"
+)
+
+const netstatMac = `Name       Mtu   Network       Address            Ipkts Ierrs     Ibytes    Opkts Oerrs     Obytes  Coll
+utun3      1500  <Link#15>                          1200     0     456789     2300     0     567890     0
+utun3      1500  10.100.0.2/32 10.100.0.2           1200     -     456789     2300     -     567890     -
+`
+
+func TestParseBytesMac(t *testing.T) {
+	rx, tx, err := parseBytesMac(netstatMac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rx != 456789 || tx != 567890 {
+		t.Errorf("unexpected bytes rx=%d tx=%d", rx, tx)
+	}
+}
+
+func TestOvpnIfaceMac(t *testing.T) {
+	prfl := &Profile{}
+
+	prfl.parseOvpnDns("Opened utun device utun4")
+	if prfl.ovpnIface != "utun4" {
+		t.Errorf("unexpected iface %q", prfl.ovpnIface)
+	}
+
+	prfl.parseOvpnDns("TUN/TAP device tun0 opened")
+	if prfl.ovpnIface != "tun0" {
+		t.Errorf("unexpected iface %q", prfl.ovpnIface)
+	}
+}
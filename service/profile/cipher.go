@@ -0,0 +1,345 @@
+package profile
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	openvpnVersionTimeout = 10 * time.Second
+	defaultTlsVersionMin  = "1.2"
+)
+
+var (
+	defaultDataCiphers = []string{
+		"AES-256-GCM",
+		"AES-128-GCM",
+	}
+	tlsVersions = []string{
+		"1.0",
+		"1.1",
+		"1.2",
+		"1.3",
+	}
+	cipherReg        = regexp.MustCompile(`^[A-Za-z0-9\-]+$`)
+	tlsCipherReg     = regexp.MustCompile(`^[A-Za-z0-9\-_:+!@.]+$`)
+	dataChannelReg   = regexp.MustCompile(`(?i)Data Channel: cipher '([^']+)'`)
+	controlChanReg   = regexp.MustCompile(`Control Channel: TLSv([0-9.]+)`)
+	openvpnVerReg    = regexp.MustCompile(`OpenVPN ([0-9]+)\.([0-9]+)`)
+	openvpnVersion   string
+	openvpnVerErr    error
+	openvpnVerOnce   sync.Once
+	weakCipherFields = []string{
+		"CBC",
+		"BF",
+		"DES",
+		"NONE",
+	}
+)
+
+type CipherPolicy struct {
+	TlsVersionMin string   `json:"tls_version_min"`
+	DataCiphers   []string `json:"data_ciphers"`
+	TlsCipher     string   `json:"tls_cipher"`
+	Enforce       bool     `json:"enforce"`
+}
+
+type CipherData struct {
+	Id          string   `json:"id"`
+	Cipher      string   `json:"cipher,omitempty"`
+	TlsVersion  string   `json:"tls_version,omitempty"`
+	DataCiphers []string `json:"data_ciphers"`
+	Message     string   `json:"message"`
+}
+
+func getOpenvpnVersion() (output string, err error) {
+	openvpnVerOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(
+			context.Background(), openvpnVersionTimeout)
+		defer cancel()
+
+		out, e := command.Output(ctx, getOpenvpnPath(), "--version")
+		if e != nil && len(out) == 0 {
+			openvpnVerErr = &errortypes.ExecError{
+				errors.Wrap(e, "profile: Failed to exec openvpn version"),
+			}
+			return
+		}
+
+		openvpnVersion = string(out)
+	})
+
+	output = openvpnVersion
+	err = openvpnVerErr
+	return
+}
+
+func openvpnDataCiphersSupport() bool {
+	output, err := getOpenvpnVersion()
+	if err != nil {
+		return false
+	}
+
+	match := openvpnVerReg.FindStringSubmatch(output)
+	if match == nil {
+		return false
+	}
+
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+
+	return major > 2 || (major == 2 && minor >= 5)
+}
+
+func tlsVersionIndex(version string) int {
+	for i, ver := range tlsVersions {
+		if ver == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func filterCiphers(ciphers []string) (filtered []string) {
+	filtered = []string{}
+	existing := set.NewSet()
+
+	for _, cipher := range ciphers {
+		cipher = strings.ToUpper(strings.TrimSpace(cipher))
+		if cipher == "" || existing.Contains(cipher) {
+			continue
+		}
+
+		if !cipherReg.MatchString(cipher) {
+			logrus.WithFields(logrus.Fields{
+				"cipher": cipher,
+			}).Warn("profile: Ignoring invalid data cipher")
+			continue
+		}
+
+		existing.Add(cipher)
+		filtered = append(filtered, cipher)
+	}
+
+	return
+}
+
+func (p *Profile) cipherPolicy() (policy *CipherPolicy) {
+	policy = &CipherPolicy{
+		TlsVersionMin: defaultTlsVersionMin,
+		DataCiphers:   defaultDataCiphers,
+	}
+
+	if tlsVersionIndex(config.Config.TlsVersionMin) >= 0 {
+		policy.TlsVersionMin = config.Config.TlsVersionMin
+	}
+	if tlsVersionIndex(p.TlsVersionMin) > tlsVersionIndex(
+		policy.TlsVersionMin) {
+
+		policy.TlsVersionMin = p.TlsVersionMin
+	}
+
+	// Data ciphers are only enforced when explicitly configured, the
+	// default list is left to openvpn to allow servers without AEAD support
+	globalCiphers := filterCiphers(config.Config.DataCiphers)
+	if len(globalCiphers) > 0 {
+		policy.DataCiphers = globalCiphers
+		policy.Enforce = true
+	}
+
+	if len(p.DataCiphers) > 0 {
+		allowed := set.NewSet()
+		for _, cipher := range policy.DataCiphers {
+			allowed.Add(cipher)
+		}
+
+		ciphers := []string{}
+		for _, cipher := range filterCiphers(p.DataCiphers) {
+			if allowed.Contains(cipher) {
+				ciphers = append(ciphers, cipher)
+			}
+		}
+
+		if len(ciphers) > 0 {
+			policy.DataCiphers = ciphers
+			policy.Enforce = true
+		} else {
+			logrus.WithFields(logrus.Fields{
+				"profile_id":   p.Id,
+				"data_ciphers": p.DataCiphers,
+			}).Warn("profile: Profile data ciphers not permitted by " +
+				"policy, using policy ciphers")
+		}
+	}
+
+	tlsCipher := strings.TrimSpace(config.Config.TlsCipher)
+	prflTlsCipher := strings.TrimSpace(p.TlsCipher)
+	if tlsCipher == "" {
+		tlsCipher = prflTlsCipher
+	} else if prflTlsCipher != "" && prflTlsCipher != tlsCipher {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"tls_cipher": prflTlsCipher,
+		}).Warn("profile: Profile TLS cipher ignored, using policy " +
+			"TLS cipher")
+	}
+	if tlsCipher != "" && tlsCipherReg.MatchString(tlsCipher) {
+		policy.TlsCipher = tlsCipher
+	}
+
+	return
+}
+
+func (c *CipherPolicy) Args(dataCiphersSupport bool) (args []string) {
+	args = []string{
+		"--tls-version-min", c.TlsVersionMin,
+	}
+
+	if c.Enforce && len(c.DataCiphers) > 0 {
+		if dataCiphersSupport {
+			args = append(args, "--data-ciphers",
+				strings.Join(c.DataCiphers, ":"))
+		} else {
+			args = append(args, "--ncp-ciphers",
+				strings.Join(c.DataCiphers, ":"))
+		}
+	}
+
+	if c.TlsCipher != "" {
+		args = append(args, "--tls-cipher", c.TlsCipher)
+	}
+
+	return
+}
+
+func (c *CipherPolicy) Allowed(cipher string) bool {
+	if !c.Enforce {
+		return true
+	}
+
+	cipher = strings.ToUpper(cipher)
+	for _, allowed := range c.DataCiphers {
+		if allowed == cipher {
+			return true
+		}
+	}
+	return false
+}
+
+func weakCipher(cipher string) bool {
+	fields := strings.Split(strings.ToUpper(cipher), "-")
+	for _, field := range fields {
+		for _, weak := range weakCipherFields {
+			if field == weak {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Profile) cipherArgs() (args []string) {
+	p.cipherPol = p.cipherPolicy()
+	p.cipherChecked = ""
+	args = p.cipherPol.Args(openvpnDataCiphersSupport())
+	return
+}
+
+func (p *Profile) parseCipher(line string) {
+	policy := p.cipherPol
+	if policy == nil {
+		return
+	}
+
+	match := controlChanReg.FindStringSubmatch(line)
+	if match != nil {
+		version := strings.TrimRight(match[1], ",")
+		if tlsVersionIndex(version) >= 0 && tlsVersionIndex(version) <
+			tlsVersionIndex(policy.TlsVersionMin) {
+
+			logrus.WithFields(logrus.Fields{
+				"profile_id":      p.Id,
+				"tls_version":     version,
+				"tls_version_min": policy.TlsVersionMin,
+			}).Warn("profile: Negotiated TLS version below policy")
+
+			evt := event.Event{
+				Type:      "cipher_warning",
+				ProfileId: p.Id,
+			}
+			evt.Init(&CipherData{
+				Id:          p.Id,
+				TlsVersion:  version,
+				DataCiphers: policy.DataCiphers,
+				Message: "TLS version below minimum " +
+					policy.TlsVersionMin,
+			})
+		}
+		return
+	}
+
+	match = dataChannelReg.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+	cipher := match[1]
+
+	if p.cipherChecked == cipher {
+		return
+	}
+	p.cipherChecked = cipher
+
+	if !policy.Allowed(cipher) {
+		logrus.WithFields(logrus.Fields{
+			"profile_id":   p.Id,
+			"cipher":       cipher,
+			"data_ciphers": policy.DataCiphers,
+		}).Error("profile: Server cipher not permitted by policy, " +
+			"disconnecting")
+
+		evt := event.Event{
+			Type:      "cipher_error",
+			ProfileId: p.Id,
+		}
+		evt.Init(&CipherData{
+			Id:          p.Id,
+			Cipher:      cipher,
+			DataCiphers: policy.DataCiphers,
+			Message:     "Server cipher " + cipher + " not permitted",
+		})
+
+		p.StopBackground()
+		return
+	}
+
+	if weakCipher(cipher) {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"cipher":     cipher,
+		}).Warn("profile: Negotiated weak data cipher")
+
+		evt := event.Event{
+			Type:      "cipher_warning",
+			ProfileId: p.Id,
+		}
+		evt.Init(&CipherData{
+			Id:          p.Id,
+			Cipher:      cipher,
+			DataCiphers: policy.DataCiphers,
+			Message: "Server cipher " + cipher +
+				" is not an AEAD cipher",
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+)
+
+func setCipherConfig(t *testing.T, conf *config.ConfigData) {
+	orig := config.Config
+	config.Config = conf
+	t.Cleanup(func() {
+		config.Config = orig
+	})
+}
+
+func TestCipherArgsDefault(t *testing.T) {
+	setCipherConfig(t, &config.ConfigData{})
+
+	prfl := &Profile{}
+	policy := prfl.cipherPolicy()
+
+	args := strings.Join(policy.Args(true), " ")
+	if args != "--tls-version-min 1.2" {
+		t.Errorf("unexpected default args %q", args)
+	}
+	if !policy.Allowed("AES-256-CBC") {
+		t.Error("default policy should not enforce data ciphers")
+	}
+}
+
+func TestCipherArgsEnforced(t *testing.T) {
+	setCipherConfig(t, &config.ConfigData{
+		TlsVersionMin: "1.2",
+		DataCiphers:   []string{"aes-256-gcm", "CHACHA20-POLY1305"},
+		TlsCipher:     "TLS-ECDHE-RSA-WITH-AES-256-GCM-SHA384",
+	})
+
+	prfl := &Profile{
+		TlsVersionMin: "1.3",
+		DataCiphers:   []string{"CHACHA20-POLY1305", "AES-128-CBC"},
+		TlsCipher:     "DEFAULT",
+	}
+	policy := prfl.cipherPolicy()
+
+	args := strings.Join(policy.Args(true), " ")
+	expected := "--tls-version-min 1.3 " +
+		"--data-ciphers CHACHA20-POLY1305 " +
+		"--tls-cipher TLS-ECDHE-RSA-WITH-AES-256-GCM-SHA384"
+	if args != expected {
+		t.Errorf("unexpected args %q", args)
+	}
+
+	args = strings.Join(policy.Args(false), " ")
+	if !strings.Contains(args, "--ncp-ciphers CHACHA20-POLY1305") {
+		t.Errorf("expected ncp ciphers for old openvpn %q", args)
+	}
+
+	if policy.Allowed("AES-256-GCM") {
+		t.Error("profile should narrow allowed ciphers")
+	}
+	if !policy.Allowed("chacha20-poly1305") {
+		t.Error("profile cipher should be allowed")
+	}
+}
+
+func TestCipherTlsVersionLower(t *testing.T) {
+	setCipherConfig(t, &config.ConfigData{
+		TlsVersionMin: "1.3",
+	})
+
+	prfl := &Profile{
+		TlsVersionMin: "1.0",
+	}
+	policy := prfl.cipherPolicy()
+
+	if policy.TlsVersionMin != "1.3" {
+		t.Errorf("profile lowered tls version to %q", policy.TlsVersionMin)
+	}
+}
@@ -15,6 +15,14 @@ Address = {{.Address}}
 PrivateKey = {{.PrivateKey}}{{if .HasDns}}
 DNS = {{.DnsServers}}{{end}}
 
+[Peer]
+PublicKey = {{.PublicKey}}
+AllowedIPs = {{.AllowedIps}}
+Endpoint = {{.Endpoint}}
+`
+	wgSyncConfTempl = `[Interface]
+PrivateKey = {{.PrivateKey}}
+
 [Peer]
 PublicKey = {{.PublicKey}}
 AllowedIPs = {{.AllowedIps}}
@@ -23,8 +31,10 @@ Endpoint = {{.Endpoint}}
 )
 
 var (
-	wgIfaceMacReg = regexp.MustCompile("\\((utun[0-9]+)\\)")
-	WgConfTempl   = template.Must(template.New("wg_conf").Parse(wgConfTempl))
+	wgIfaceMacReg   = regexp.MustCompile("\\((utun[0-9]+)\\)")
+	WgConfTempl     = template.Must(template.New("wg_conf").Parse(wgConfTempl))
+	WgSyncConfTempl = template.Must(
+		template.New("wg_sync_conf").Parse(wgSyncConfTempl))
 )
 
 type WgConfData struct {
@@ -0,0 +1,118 @@
+package profile
+
+import (
+	"sort"
+
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/token"
+	"github.com/sirupsen/logrus"
+)
+
+type CredentialInfo struct {
+	ProfileId string `json:"profile_id"`
+	Name      string `json:"name"`
+	Password  bool   `json:"password"`
+	Otp       bool   `json:"otp"`
+	Token     bool   `json:"token"`
+}
+
+func SavedCredentials() (creds []CredentialInfo, err error) {
+	infos := map[string]*CredentialInfo{}
+
+	get := func(prflId string) *CredentialInfo {
+		info := infos[prflId]
+		if info == nil {
+			info = &CredentialInfo{
+				ProfileId: prflId,
+			}
+			infos[prflId] = info
+		}
+		return info
+	}
+
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return
+	}
+
+	names := map[string]string{}
+	for _, sprfl := range sprfls {
+		names[sprfl.Id] = sprfl.Name
+		if sprfl.Password != "" {
+			get(sprfl.Id).Password = true
+		}
+	}
+
+	for _, prflId := range OtpCacheIds() {
+		get(prflId).Otp = true
+	}
+
+	for _, prflId := range token.Profiles() {
+		get(prflId).Token = true
+	}
+
+	creds = []CredentialInfo{}
+	for prflId, info := range infos {
+		info.Name = names[prflId]
+		creds = append(creds, *info)
+	}
+
+	sort.Slice(creds, func(i, j int) bool {
+		return creds[i].ProfileId < creds[j].ProfileId
+	})
+
+	return
+}
+
+func clearCredentials(prflId string) {
+	OtpCacheClear(prflId)
+	token.Clear(prflId)
+	sprofile.ClearUserState(prflId)
+
+	prfl := GetProfile(prflId)
+	if prfl != nil {
+		prfl.Password = ""
+		if prfl.SystemProfile != nil {
+			prfl.SystemProfile.Password = ""
+		}
+	}
+}
+
+func ClearCredentials(prflId string) (err error) {
+	err = sprofile.ClearPassword(prflId)
+	if err != nil {
+		return
+	}
+
+	clearCredentials(prflId)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+	}).Info("profile: Cleared saved credentials")
+
+	return
+}
+
+func ClearAllCredentials() (err error) {
+	err = sprofile.ClearPassword("")
+	if err != nil {
+		return
+	}
+
+	for _, prflId := range OtpCacheIds() {
+		clearCredentials(prflId)
+	}
+	for _, prflId := range token.Profiles() {
+		clearCredentials(prflId)
+	}
+	for prflId := range GetProfiles() {
+		clearCredentials(prflId)
+	}
+	for prflId := range sprofile.GetUserStates() {
+		clearCredentials(prflId)
+	}
+
+	logrus.Info("profile: Cleared all saved credentials")
+
+	return
+}
@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"sort"
+)
+
+type Diagnostic struct {
+	Id               string   `json:"id"`
+	Mode             string   `json:"mode"`
+	Status           string   `json:"status"`
+	Timestamp        int64    `json:"timestamp"`
+	Iface            string   `json:"iface"`
+	ServerAddr       string   `json:"server_addr"`
+	ClientAddr       string   `json:"client_addr"`
+	GatewayAddr      string   `json:"gateway_addr"`
+	GatewayAddr6     string   `json:"gateway_addr6"`
+	FailureReason    string   `json:"failure_reason"`
+	ReconnectAttempt int      `json:"reconnect_attempt"`
+	Routes           []string `json:"routes"`
+	ExcludeRoutes    []string `json:"exclude_routes"`
+	DnsServers       []string `json:"dns_servers"`
+	ForceDns         bool     `json:"force_dns"`
+	DnsOverHttps     bool     `json:"dns_over_https"`
+	KillSwitch       bool     `json:"kill_switch"`
+	BlockIpv6        bool     `json:"block_ipv6"`
+	ProxyType        string   `json:"proxy_type"`
+}
+
+func (p *Profile) Diagnostic() (diag *Diagnostic) {
+	routes := []string{}
+	for _, route := range p.Routes {
+		routes = append(routes, route.Network)
+	}
+	for _, route := range p.Routes6 {
+		routes = append(routes, route.Network)
+	}
+
+	excludeRoutes := []string{}
+	for _, network := range p.excludeRoutes {
+		excludeRoutes = append(excludeRoutes, network.String())
+	}
+
+	dnsServers := append([]string{}, p.dnsServers()...)
+
+	diag = &Diagnostic{
+		Id:               p.Id,
+		Mode:             p.Mode,
+		Status:           p.Status,
+		Timestamp:        p.Timestamp,
+		Iface:            p.killSwitchIface(),
+		ServerAddr:       p.ServerAddr,
+		ClientAddr:       p.ClientAddr,
+		GatewayAddr:      p.GatewayAddr,
+		GatewayAddr6:     p.GatewayAddr6,
+		FailureReason:    p.FailureReason,
+		ReconnectAttempt: p.ReconnectAttempt,
+		Routes:           routes,
+		ExcludeRoutes:    excludeRoutes,
+		DnsServers:       dnsServers,
+		ForceDns:         p.ForceDns,
+		DnsOverHttps:     p.doh != nil,
+		KillSwitch:       p.KillSwitch,
+		BlockIpv6:        p.BlockIpv6,
+		ProxyType:        p.ProxyType,
+	}
+
+	return
+}
+
+func GetDiagnostics() (diags []*Diagnostic) {
+	diags = []*Diagnostic{}
+
+	for _, prfl := range GetProfiles() {
+		diags = append(diags, prfl.Diagnostic())
+	}
+
+	sort.Slice(diags, func(i, j int) bool {
+		return diags[i].Id < diags[j].Id
+	})
+
+	return
+}
@@ -0,0 +1,339 @@
+package profile
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dnsLeakHost    = "pritunl.com"
+	dnsLeakDelay   = 3 * time.Second
+	dnsLeakTimeout = 10 * time.Second
+)
+
+var (
+	ovpnIfaceReg = regexp.MustCompile(
+		`(?:(?:TUN/TAP|TAP-WIN32|TAP-Windows) device \[?([^\]]+?)\]? ` +
+			`opened|Opened utun device (utun[0-9]+))`)
+)
+
+type DnsLeakData struct {
+	Id         string   `json:"id"`
+	Resolver   string   `json:"resolver"`
+	DnsServers []string `json:"dns_servers"`
+	Forced     bool     `json:"forced"`
+}
+
+func (p *Profile) parseOvpnDns(line string) {
+	if strings.Contains(line, "PUSH_REPLY") {
+		dnsServers := []string{}
+
+		for _, option := range strings.Split(line, ",") {
+			fields := strings.Fields(strings.Trim(option, "'"))
+			if len(fields) == 3 && fields[0] == "dhcp-option" &&
+				(fields[1] == "DNS" || fields[1] == "DNS6") {
+
+				dnsServers = append(dnsServers, fields[2])
+			}
+		}
+
+		p.ovpnDnsServers = dnsServers
+	} else if match := ovpnIfaceReg.FindStringSubmatch(line); match != nil {
+		p.ovpnIface = match[1] + match[2]
+	}
+}
+
+func (p *Profile) dnsServers() []string {
+	if p.Mode == Wg {
+		if p.wgConf == nil {
+			return nil
+		}
+		return p.wgConf.DnsServers
+	}
+	return p.ovpnDnsServers
+}
+
+func (p *Profile) dnsIface() string {
+	if p.Mode == Wg {
+		return p.Iface
+	}
+	return p.ovpnIface
+}
+
+func getResolver(host string) (resolver string, err error) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), dnsLeakTimeout)
+	defer cancel()
+
+	output, err := command.Output(ctx, "nslookup", host)
+	if err != nil && len(output) == 0 {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "profile: Failed to exec nslookup"),
+		}
+		return
+	}
+	err = nil
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "Address:" {
+			resolver = strings.Split(fields[1], "#")[0]
+			break
+		}
+	}
+
+	if net.ParseIP(resolver) == nil {
+		resolver = ""
+		err = &errortypes.ParseError{
+			errors.New("profile: Failed to parse nslookup resolver"),
+		}
+		return
+	}
+
+	return
+}
+
+func parseUpstreamDns(output string) (dnsServers []string) {
+	dnsServers = []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		if strings.HasPrefix(fields[0], "nameserver[") {
+			fields = fields[1:]
+		} else if fields[0] != "Global:" && fields[0] != "Link" {
+			continue
+		}
+
+		for _, field := range fields {
+			addr := strings.Split(strings.Split(field, "%")[0], "#")[0]
+			ip := net.ParseIP(addr)
+			if ip == nil || ip.IsLoopback() {
+				continue
+			}
+			dnsServers = append(dnsServers, ip.String())
+		}
+	}
+
+	return
+}
+
+func getUpstreamDnsServers() (dnsServers []string, err error) {
+	dnsServers, err = getDnsServers()
+	if err != nil {
+		return
+	}
+
+	loopback := false
+	for _, dnsServer := range dnsServers {
+		if net.ParseIP(dnsServer).IsLoopback() {
+			loopback = true
+			break
+		}
+	}
+	if !loopback {
+		return
+	}
+
+	upstream := []string{}
+	switch runtime.GOOS {
+	case "linux":
+		data, e := ioutil.ReadFile("/run/systemd/resolve/resolv.conf")
+		if e == nil {
+			upstream = parseDnsServers(string(data), false)
+		}
+		if len(upstream) == 0 {
+			output, e := utils.ExecOutput("resolvectl", "dns")
+			if e == nil {
+				upstream = parseUpstreamDns(output)
+			}
+		}
+		break
+	case "darwin":
+		output, e := utils.ExecOutput("scutil", "--dns")
+		if e == nil {
+			upstream = parseUpstreamDns(output)
+		}
+		break
+	}
+
+	for _, dnsServer := range upstream {
+		if !net.ParseIP(dnsServer).IsLoopback() {
+			dnsServers = append(dnsServers, dnsServer)
+		}
+	}
+
+	return
+}
+
+func (p *Profile) forceDns(dnsServers []string) (err error) {
+	iface := p.dnsIface()
+
+	switch runtime.GOOS {
+	case "linux":
+		if iface == "" {
+			break
+		}
+		err = setDnsLinux(iface, dnsServers)
+		break
+	case "darwin":
+		err = utils.CopyScutilDns("/Network/Pritunl/DNS")
+		break
+	case "windows":
+		if iface == "" {
+			break
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"netsh", "interface", "ipv4", "set", "interface",
+			iface, "metric=1",
+		)
+		if err != nil {
+			return
+		}
+
+		err = setDnsWin(iface, dnsServers)
+		break
+	}
+	if err != nil {
+		return
+	}
+
+	utils.ClearDNSCache()
+
+	return
+}
+
+// Resolvers currently used by the system, loopback stubs such as
+// systemd-resolved and mDNSResponder are replaced with their upstream servers
+func (p *Profile) activeResolvers() (resolvers []string, err error) {
+	switch runtime.GOOS {
+	case "linux", "darwin":
+		resolvers, err = getUpstreamDnsServers()
+		break
+	default:
+		resolver, e := getResolver(dnsLeakHost)
+		if e != nil {
+			err = e
+			return
+		}
+		resolvers = []string{resolver}
+		break
+	}
+
+	return
+}
+
+func (p *Profile) checkDnsLeak() {
+	time.Sleep(dnsLeakDelay)
+
+	dnsServers := p.dnsServers()
+	if p.stop || !p.connected || len(dnsServers) == 0 {
+		return
+	}
+
+	resolvers, err := p.activeResolvers()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Warn("profile: Failed to check DNS resolver")
+		return
+	}
+
+	upstream := []string{}
+	for _, resolver := range resolvers {
+		resolverIp := net.ParseIP(resolver)
+		if resolverIp == nil {
+			continue
+		}
+
+		if resolverIp.IsLoopback() {
+			if p.doh != nil && resolver == dohListenAddr() {
+				return
+			}
+			continue
+		}
+
+		for _, dnsServer := range dnsServers {
+			if resolverIp.Equal(net.ParseIP(dnsServer)) {
+				return
+			}
+		}
+
+		upstream = append(upstream, resolver)
+	}
+
+	if len(upstream) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"resolvers":  resolvers,
+		}).Info("profile: Unable to find upstream DNS resolver")
+		return
+	}
+	resolver := upstream[0]
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id":  p.Id,
+		"resolver":    resolver,
+		"dns_servers": dnsServers,
+	}).Warn("profile: DNS leak detected")
+
+	forced := false
+	if p.ForceDns {
+		err = p.forceDns(dnsServers)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to force DNS servers")
+		} else {
+			forced = true
+		}
+	}
+
+	evt := event.Event{
+		Type:      "dns_leak",
+		ProfileId: p.Id,
+	}
+	evt.Init(&DnsLeakData{
+		Id:         p.Id,
+		Resolver:   resolver,
+		DnsServers: dnsServers,
+		Forced:     forced,
+	})
+}
+
+func (p *Profile) checkDnsLeakBackground() {
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		p.checkDnsLeak()
+	}()
+}
@@ -0,0 +1,46 @@
+package profile
+
+import (
+	"testing"
+)
+
+func TestParseUpstreamDnsResolvectl(t *testing.T) {
+	output := `Global: 127.0.2.53
+Link 2 (eth0): 192.168.1.1 fe80::1%eth0
+Link 5 (tun0): 10.100.0.1#dns.example.com
+Link 7 (docker0):
+`
+
+	servers := parseUpstreamDns(output)
+	expected := []string{"192.168.1.1", "fe80::1", "10.100.0.1"}
+
+	if len(servers) != len(expected) {
+		t.Fatalf("unexpected servers %v", servers)
+	}
+	for i := range expected {
+		if servers[i] != expected[i] {
+			t.Errorf("server %d expected %s got %s",
+				i, expected[i], servers[i])
+		}
+	}
+}
+
+func TestParseUpstreamDnsScutil(t *testing.T) {
+	output := `DNS configuration
+
+resolver #1
+  search domain[0] : example.com
+  nameserver[0] : 10.100.0.1
+  nameserver[1] : 192.168.1.1
+  if_index : 15 (utun3)
+  flags    : Request A records
+  reach    : 0x00000003 (Reachable,Transient Connection)
+`
+
+	servers := parseUpstreamDns(output)
+	if len(servers) != 2 || servers[0] != "10.100.0.1" ||
+		servers[1] != "192.168.1.1" {
+
+		t.Errorf("unexpected servers %v", servers)
+	}
+}
@@ -0,0 +1,342 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dohPort    = 53
+	dohTimeout = 5 * time.Second
+	dohMaxSize = 65535
+	dohType    = "application/dns-message"
+)
+
+type dohResolver struct {
+	profileId string
+	upstream  string
+	client    *http.Client
+	conn      *net.UDPConn
+}
+
+func dohListenAddr() string {
+	if runtime.GOOS == "linux" {
+		return "127.0.2.53"
+	}
+	return "127.0.0.1"
+}
+
+func newDohClient(upstream string) (client *http.Client, err error) {
+	u, err := url.Parse(upstream)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		err = &errortypes.ParseError{
+			errors.New("profile: Invalid DNS-over-HTTPS upstream"),
+		}
+		return
+	}
+
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	addr := host
+	if net.ParseIP(host) == nil {
+		addrs, e := net.LookupHost(host)
+		if e != nil || len(addrs) == 0 {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "profile: Failed to resolve DNS-over-HTTPS "+
+					"upstream"),
+			}
+			return
+		}
+		addr = addrs[0]
+	}
+	addr = net.JoinHostPort(addr, port)
+
+	dialer := &net.Dialer{
+		Timeout: dohTimeout,
+	}
+
+	client = &http.Client{
+		Timeout: dohTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network,
+				_ string) (net.Conn, error) {
+
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSClientConfig: &tls.Config{
+				ServerName: host,
+				MinVersion: tls.VersionTLS12,
+			},
+			MaxIdleConns:    4,
+			IdleConnTimeout: 60 * time.Second,
+		},
+	}
+
+	return
+}
+
+func (d *dohResolver) exchange(query []byte) (resp []byte, err error) {
+	req, err := http.NewRequest("POST", d.upstream, bytes.NewReader(query))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: Failed to create DNS-over-HTTPS "+
+				"request"),
+		}
+		return
+	}
+
+	req.Header.Set("Content-Type", dohType)
+	req.Header.Set("Accept", dohType)
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: DNS-over-HTTPS request failed"),
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("profile: DNS-over-HTTPS upstream returned %d",
+				res.StatusCode),
+		}
+		return
+	}
+
+	resp, err = ioutil.ReadAll(io.LimitReader(res.Body, dohMaxSize))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to read DNS-over-HTTPS "+
+				"response"),
+		}
+		return
+	}
+
+	return
+}
+
+func (d *dohResolver) handle(query []byte, addr *net.UDPAddr) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	resp, err := d.exchange(query)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": d.profileId,
+			"error":      err,
+		}).Debug("profile: DNS-over-HTTPS query failed")
+		return
+	}
+
+	_, _ = d.conn.WriteToUDP(resp, addr)
+}
+
+func (d *dohResolver) serve() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	buf := make([]byte, dohMaxSize)
+	for {
+		n, addr, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		query := make([]byte, n)
+		copy(query, buf[:n])
+
+		go d.handle(query, addr)
+	}
+}
+
+func (p *Profile) dohUpstream() string {
+	upstream := strings.TrimSpace(p.DnsOverHttpsUpstream)
+	if upstream != "" {
+		return upstream
+	}
+
+	dnsServers := p.dnsServers()
+	if len(dnsServers) == 0 {
+		return ""
+	}
+
+	return "https://" + net.JoinHostPort(dnsServers[0], "443") + "/dns-query"
+}
+
+func (p *Profile) setResolvers(dnsServers []string) (err error) {
+	iface := p.dnsIface()
+
+	switch runtime.GOOS {
+	case "linux":
+		if iface == "" {
+			break
+		}
+		err = setDnsLinux(iface, dnsServers)
+		break
+	case "darwin":
+		err = utils.SetScutilDnsServers("/Network/Pritunl/DNS", dnsServers)
+		if err != nil {
+			return
+		}
+		err = utils.CopyScutilDns("/Network/Pritunl/DNS")
+		break
+	case "windows":
+		if iface == "" {
+			break
+		}
+		err = setDnsWin(iface, dnsServers)
+		break
+	}
+	if err != nil {
+		return
+	}
+
+	utils.ClearDNSCache()
+
+	return
+}
+
+func (p *Profile) enableDoh() (err error) {
+	upstream := p.dohUpstream()
+	if upstream == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: No DNS-over-HTTPS upstream available"),
+		}
+		return
+	}
+
+	client, err := newDohClient(upstream)
+	if err != nil {
+		return
+	}
+
+	addr := &net.UDPAddr{
+		IP:   net.ParseIP(dohListenAddr()),
+		Port: dohPort,
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: Failed to bind DNS-over-HTTPS "+
+				"resolver"),
+		}
+		return
+	}
+
+	resolver := &dohResolver{
+		profileId: p.Id,
+		upstream:  upstream,
+		client:    client,
+		conn:      conn,
+	}
+	p.doh = resolver
+
+	go resolver.serve()
+
+	err = p.setResolvers([]string{addr.IP.String()})
+	if err != nil {
+		p.stopDoh()
+
+		e := p.setResolvers(p.dnsServers())
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      e,
+			}).Error("profile: Failed to restore DNS servers")
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"upstream":   upstream,
+		"listen":     addr.String(),
+	}).Info("profile: DNS-over-HTTPS resolver started")
+
+	return
+}
+
+func (p *Profile) enableDohBackground() {
+	if !p.DnsOverHttps {
+		return
+	}
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		if p.stop || p.doh != nil {
+			return
+		}
+
+		err := p.enableDoh()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Warn("profile: DNS-over-HTTPS setup failed, " +
+				"using plain DNS")
+		}
+	}()
+}
+
+func (p *Profile) stopDoh() {
+	resolver := p.doh
+	if resolver == nil {
+		return
+	}
+	p.doh = nil
+
+	_ = resolver.conn.Close()
+	resolver.client.CloseIdleConnections()
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+	}).Info("profile: DNS-over-HTTPS resolver stopped")
+}
@@ -0,0 +1,287 @@
+package profile
+
+import (
+	"net"
+	"runtime"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var setExcludeRoute = (*Profile).routeExclude
+
+func (p *Profile) excludeNetworks() (networks []*net.IPNet) {
+	networks = []*net.IPNet{}
+
+	for _, route := range p.ExcludeRoutes {
+		route = strings.TrimSpace(route)
+		if route == "" {
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(route)
+		if err != nil || network.IP.To4() == nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      route,
+			}).Warn("profile: Ignoring invalid exclude route")
+			continue
+		}
+
+		networks = append(networks, network)
+	}
+
+	return
+}
+
+func (p *Profile) excludeRoutesOvpn() (routes []string) {
+	routes = []string{}
+
+	for _, network := range p.excludeNetworks() {
+		routes = append(routes, network.IP.String()+" "+
+			net.IP(network.Mask).String())
+	}
+
+	return
+}
+
+func ifaceByAddr(addr string) (iface string) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+
+	for _, ifc := range ifaces {
+		addrs, err := ifc.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, ifcAddr := range addrs {
+			ipNet, ok := ifcAddr.(*net.IPNet)
+			if ok && ipNet.IP.Equal(ip) {
+				iface = ifc.Name
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func parseDefaultRoute(output string) (gateway, iface string) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+
+		switch runtime.GOOS {
+		case "linux":
+			gw := ""
+			dev := ""
+			for i := 0; i+1 < len(fields); i++ {
+				if fields[i] == "via" {
+					gw = fields[i+1]
+				} else if fields[i] == "dev" {
+					dev = fields[i+1]
+				}
+			}
+			if gw != "" && gateway == "" {
+				gateway = gw
+				iface = dev
+			}
+			break
+		case "darwin":
+			if len(fields) == 2 && fields[0] == "gateway:" {
+				gateway = fields[1]
+			} else if len(fields) == 2 && fields[0] == "interface:" {
+				iface = fields[1]
+			}
+			break
+		case "windows":
+			if gateway == "" && len(fields) >= 4 && fields[0] == "0.0.0.0" &&
+				fields[1] == "0.0.0.0" && net.ParseIP(fields[2]) != nil {
+
+				gateway = fields[2]
+				iface = fields[3]
+			}
+			break
+		}
+	}
+
+	return
+}
+
+// Default gateway and the physical interface it is reached through, on
+// Windows the interface is resolved from the address in the route table
+func getDefaultRoute() (gateway, iface string, err error) {
+	output := ""
+
+	switch runtime.GOOS {
+	case "linux":
+		output, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "-4", "route", "show", "default", "table", "main",
+		)
+		break
+	case "darwin":
+		output, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"route", "-n", "get", "-inet", "default",
+		)
+		break
+	case "windows":
+		output, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"route", "print", "-4", "0.0.0.0",
+		)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+
+	gateway, iface = parseDefaultRoute(output)
+	if runtime.GOOS == "windows" {
+		iface = ifaceByAddr(iface)
+	}
+
+	if net.ParseIP(gateway) == nil {
+		gateway = ""
+		iface = ""
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Failed to find default gateway"),
+		}
+		return
+	}
+
+	return
+}
+
+func getDefaultGateway() (gateway string, err error) {
+	gateway, _, err = getDefaultRoute()
+	return
+}
+
+func (p *Profile) routeExclude(add bool, network *net.IPNet,
+	gateway string) (err error) {
+
+	switch runtime.GOOS {
+	case "linux":
+		action := "del"
+		ignores := []string{"No such process"}
+		if add {
+			action = "add"
+			ignores = []string{"File exists"}
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			ignores,
+			"ip", "-4", "route", action, network.String(), "via", gateway,
+		)
+		break
+	case "darwin":
+		action := "delete"
+		ignores := []string{"not in table"}
+		if add {
+			action = "add"
+			ignores = []string{"File exists"}
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			ignores,
+			"route", "-q", "-n", action, "-inet", network.String(), gateway,
+		)
+		break
+	case "windows":
+		action := "delete"
+		ignores := []string{"Element not found"}
+		if add {
+			action = "add"
+			ignores = []string{"already exists"}
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			ignores,
+			"route", action, network.IP.String(),
+			"mask", net.IP(network.Mask).String(), gateway,
+		)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) loadExcludeGateway() {
+	p.excludeGateway = ""
+
+	if len(p.excludeNetworks()) == 0 {
+		return
+	}
+
+	gateway, err := getDefaultGateway()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to get gateway for exclude routes")
+		return
+	}
+
+	p.excludeGateway = gateway
+}
+
+func (p *Profile) addExcludeRoutes() {
+	if p.excludeGateway == "" {
+		return
+	}
+
+	for _, network := range p.excludeNetworks() {
+		err := setExcludeRoute(p, true, network, p.excludeGateway)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      network.String(),
+				"error":      err,
+			}).Error("profile: Failed to add exclude route")
+			continue
+		}
+
+		p.excludeRoutes = append(p.excludeRoutes, network)
+		Registry.AddRoute(p.Id, network.String()+" via "+p.excludeGateway)
+	}
+}
+
+func (p *Profile) clearExcludeRoutes() {
+	for _, network := range p.excludeRoutes {
+		if Registry.RemoveRoute(p.Id,
+			network.String()+" via "+p.excludeGateway) {
+
+			continue
+		}
+
+		err := setExcludeRoute(p, false, network, p.excludeGateway)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      network.String(),
+				"error":      err,
+			}).Error("profile: Failed to remove exclude route")
+		}
+	}
+
+	p.excludeRoutes = nil
+}
@@ -0,0 +1,198 @@
+package profile
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+)
+
+const exportMaxSize = 4 * 1024 * 1024
+
+var exportNameReg = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+func exportName(sprfl *sprofile.Sprofile) string {
+	parts := []string{}
+	for _, part := range []string{
+		sprfl.Organization,
+		sprfl.User,
+		sprfl.Server,
+	} {
+		part = exportNameReg.ReplaceAllString(part, "_")
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return sprfl.Id + ".ovpn"
+	}
+
+	return strings.Join(parts, "_") + ".ovpn"
+}
+
+// Export a system profile as a tar archive compatible with the profile
+// import in the client and cli. Stored passwords and sync credentials are
+// not included.
+func Export(prflId string) (data []byte, err error) {
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return
+	}
+
+	var sprfl *sprofile.Sprofile
+	for _, sprf := range sprfls {
+		if sprf.Id == prflId {
+			sprfl = sprf
+			break
+		}
+	}
+
+	if sprfl == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Profile not found"),
+		}
+		return
+	}
+
+	name := exportName(sprfl)
+	ovpnData := sprfl.OvpnData
+
+	sprfl.Id = ""
+	sprfl.Password = ""
+	sprfl.PasswordData = ""
+	sprfl.LastMode = ""
+	sprfl.OvpnData = ""
+	sprfl.SyncSecret = ""
+	sprfl.SyncToken = ""
+	sprfl.SyncHash = ""
+	sprfl.ImportUrl = ""
+	sprfl.ImportName = ""
+	sprfl.Stale = false
+	sprfl.CertPath = ""
+	sprfl.KeyPath = ""
+
+	confData, err := json.MarshalIndent(sprfl, "", "  ")
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "profile: Failed to marshal profile data"),
+		}
+		return
+	}
+
+	prflData := &bytes.Buffer{}
+	for _, line := range strings.Split(string(confData), "\n") {
+		prflData.WriteString("#" + line + "\n")
+	}
+	prflData.WriteString(strings.TrimLeft(ovpnData, "\n"))
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(prflData.Len()),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "profile: Failed to write export header"),
+		}
+		return
+	}
+
+	_, err = tw.Write(prflData.Bytes())
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "profile: Failed to write export data"),
+		}
+		return
+	}
+
+	err = tw.Close()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "profile: Failed to close export archive"),
+		}
+		return
+	}
+
+	data = buf.Bytes()
+
+	return
+}
+
+func readExport(data []byte) (prflData []byte, err error) {
+	tr := tar.NewReader(bytes.NewReader(data))
+
+	_, err = tr.Next()
+	if err != nil {
+		// Not an archive, handle as a single profile file
+		prflData = data
+		err = nil
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, io.LimitReader(tr, exportMaxSize))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to read export data"),
+		}
+		return
+	}
+
+	_, err = tr.Next()
+	if err != io.EOF {
+		err = &errortypes.ParseError{
+			errors.New("profile: Export archive must contain one profile"),
+		}
+		return
+	}
+	err = nil
+
+	prflData = buf.Bytes()
+
+	return
+}
+
+// Import a profile archive created by Export. The profile is always given a
+// new ID so an existing profile is never overwritten.
+func Import(data []byte) (sprfl *sprofile.Sprofile, err error) {
+	prflData, err := readExport(data)
+	if err != nil {
+		return
+	}
+
+	sprfl, err = sprofile.ParseImport(prflData)
+	if err != nil {
+		return
+	}
+
+	sprfl.SyncSecret = ""
+	sprfl.SyncToken = ""
+	sprfl.SyncHash = ""
+	sprfl.ImportUrl = ""
+	sprfl.ImportName = ""
+	sprfl.Stale = false
+
+	sprfl.Id, err = sprofile.NewId()
+	if err != nil {
+		return
+	}
+
+	err = sprfl.Commit()
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	FailureUnreachable = "server_unreachable"
+	FailureTls         = "tls_handshake_failed"
+	FailureAuth        = "auth_rejected"
+	FailureUnknown     = "unknown"
+)
+
+var failurePatterns = []struct {
+	reason   string
+	patterns []string
+}{
+	{
+		reason: FailureAuth,
+		patterns: []string{
+			"AUTH_FAILED",
+			"auth-failure",
+		},
+	},
+	{
+		reason: FailureUnreachable,
+		patterns: []string{
+			"TLS Error: TLS key negotiation failed",
+			"RESOLVE: Cannot resolve host address",
+			"Network is unreachable",
+			"No route to host",
+			"Connection refused",
+			"Connection timed out",
+			"Server poll timeout",
+			"TCP: connect to",
+		},
+	},
+	{
+		reason: FailureTls,
+		patterns: []string{
+			"TLS Error: TLS handshake failed",
+			"TLS_ERROR",
+			"VERIFY ERROR",
+			"OpenSSL: error",
+			"TLS error",
+		},
+	},
+}
+
+func failurePriority(reason string) int {
+	for i, item := range failurePatterns {
+		if item.reason == reason {
+			return len(failurePatterns) - i
+		}
+	}
+	return 0
+}
+
+func classifyFailureLine(line string) string {
+	for _, item := range failurePatterns {
+		for _, pattern := range item.patterns {
+			if strings.Contains(line, pattern) {
+				return item.reason
+			}
+		}
+	}
+	return ""
+}
+
+func (p *Profile) parseFailure(line string) {
+	reason := classifyFailureLine(line)
+	if failurePriority(reason) > failurePriority(p.failureReason) {
+		p.failureReason = reason
+	}
+}
+
+func (p *Profile) connectTimeout() time.Duration {
+	if p.ConnectTimeout > 0 {
+		return time.Duration(p.ConnectTimeout) * time.Second
+	}
+	return connTimeout
+}
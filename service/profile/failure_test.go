@@ -0,0 +1,87 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const failureUnreachableLog = `2024-03-01 10:00:00 OpenVPN 2.6.8 x86_64-pc-linux-gnu
+2024-03-01 10:00:00 TCP/UDP: Preserving recently used remote address: [AF_INET]203.0.113.10:1194
+2024-03-01 10:00:00 UDP link local: (not bound)
+2024-03-01 10:00:00 UDP link remote: [AF_INET]203.0.113.10:1194
+2024-03-01 10:01:00 TLS Error: TLS key negotiation failed to occur within 60 seconds (check your network connectivity)
+2024-03-01 10:01:00 TLS Error: TLS handshake failed
+2024-03-01 10:01:00 RESOLVE: Cannot resolve host address: vpn.invalid:1194 (Name or service not known)
+`
+
+const failureTlsLog = `2024-03-01 10:00:00 OpenVPN 2.6.8 x86_64-pc-linux-gnu
+2024-03-01 10:00:00 TCP/UDP: Preserving recently used remote address: [AF_INET]203.0.113.10:1194
+2024-03-01 10:00:01 VERIFY ERROR: depth=0, error=certificate signature failure: CN=server
+2024-03-01 10:00:01 OpenSSL: error:0A000086:SSL routines::certificate verify failed
+2024-03-01 10:00:01 TLS_ERROR: BIO read tls_read_plaintext error
+2024-03-01 10:00:01 TLS Error: TLS object -> incoming plaintext read error
+`
+
+const failureAuthLog = `2024-03-01 10:00:00 OpenVPN 2.6.8 x86_64-pc-linux-gnu
+2024-03-01 10:00:01 TLS: Initial packet from [AF_INET]203.0.113.10:1194
+2024-03-01 10:00:01 Connection timed out
+2024-03-01 10:00:02 TLS Error: TLS handshake failed
+2024-03-01 10:00:03 AUTH: Received control message: AUTH_FAILED
+2024-03-01 10:00:03 SIGTERM[soft,auth-failure] received, process exiting
+`
+
+func classifyLog(log string) string {
+	prfl := &Profile{}
+	for _, line := range strings.Split(log, "\n") {
+		prfl.parseFailure(line)
+	}
+	return prfl.failureReason
+}
+
+func TestParseFailure(t *testing.T) {
+	reason := classifyLog(failureUnreachableLog)
+	if reason != FailureUnreachable {
+		t.Errorf("expected %s got %s", FailureUnreachable, reason)
+	}
+
+	reason = classifyFailureLine("2024-03-01 10:01:00 TLS Error: TLS key " +
+		"negotiation failed to occur within 60 seconds")
+	if reason != FailureUnreachable {
+		t.Errorf("expected %s got %s", FailureUnreachable, reason)
+	}
+
+	reason = classifyLog(failureUnreachableLog[:strings.Index(
+		failureUnreachableLog, "2024-03-01 10:01:00")] +
+		"2024-03-01 10:01:00 Server poll timeout, restarting\n")
+	if reason != FailureUnreachable {
+		t.Errorf("expected %s got %s", FailureUnreachable, reason)
+	}
+
+	reason = classifyLog(failureTlsLog)
+	if reason != FailureTls {
+		t.Errorf("expected %s got %s", FailureTls, reason)
+	}
+
+	reason = classifyLog(failureAuthLog)
+	if reason != FailureAuth {
+		t.Errorf("expected %s got %s", FailureAuth, reason)
+	}
+
+	reason = classifyLog("2024-03-01 10:00:00 OpenVPN 2.6.8\n")
+	if reason != "" {
+		t.Errorf("expected no reason got %s", reason)
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	prfl := &Profile{}
+	if prfl.connectTimeout() != 30*time.Second {
+		t.Errorf("unexpected default timeout %s", prfl.connectTimeout())
+	}
+
+	prfl.ConnectTimeout = 15
+	if prfl.connectTimeout() != 15*time.Second {
+		t.Errorf("unexpected timeout %s", prfl.connectTimeout())
+	}
+}
@@ -0,0 +1,309 @@
+package profile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	readNetwork      = currentNetwork
+	repairGateway    = restoreDefaultGateway
+	repairDnsServers = restoreSnapshotDns
+)
+
+type NetSnapshot struct {
+	Gateway    string    `json:"gateway"`
+	Iface      string    `json:"iface"`
+	DnsServers []string  `json:"dns_servers"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+func parseDnsServers(output string, scutil bool) (dnsServers []string) {
+	dnsServers = []string{}
+	inArray := false
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if scutil {
+			if strings.HasPrefix(fields[0], "ServerAddresses") {
+				inArray = true
+				continue
+			}
+			if !inArray {
+				continue
+			}
+			if fields[0] == "}" {
+				inArray = false
+				continue
+			}
+		} else if runtime.GOOS == "linux" && fields[0] != "nameserver" {
+			continue
+		}
+
+		addr := fields[len(fields)-1]
+		if net.ParseIP(addr) != nil {
+			dnsServers = append(dnsServers, addr)
+		}
+	}
+
+	sort.Strings(dnsServers)
+
+	return
+}
+
+func getDnsServers() (dnsServers []string, err error) {
+	switch runtime.GOOS {
+	case "linux":
+		data, e := ioutil.ReadFile("/etc/resolv.conf")
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read resolv.conf"),
+			}
+			return
+		}
+		dnsServers = parseDnsServers(string(data), false)
+		break
+	case "darwin":
+		output, e := utils.GetScutilKey("State", "/Network/Global/DNS")
+		if e != nil {
+			err = e
+			return
+		}
+		dnsServers = parseDnsServers(output, true)
+		break
+	case "windows":
+		output, e := utils.ExecOutput(
+			"netsh", "interface", "ipv4", "show", "dnsservers")
+		if e != nil {
+			err = e
+			return
+		}
+		dnsServers = parseDnsServers(output, false)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+
+	return
+}
+
+func restoreDefaultGateway(gateway, current string) (err error) {
+	switch runtime.GOOS {
+	case "linux":
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "-4", "route", "replace", "default", "via", gateway,
+		)
+		break
+	case "darwin":
+		if current != "" {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"route", "-q", "-n", "change", "-inet", "default", gateway,
+			)
+		} else {
+			_, err = utils.ExecCombinedOutputLogged(
+				[]string{"File exists"},
+				"route", "-q", "-n", "add", "-inet", "default", gateway,
+			)
+		}
+		break
+	case "windows":
+		if current != "" {
+			_, err = utils.ExecCombinedOutputLogged(
+				[]string{"Element not found"},
+				"route", "delete", "0.0.0.0", "mask", "0.0.0.0", current,
+			)
+			if err != nil {
+				return
+			}
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"already exists"},
+			"route", "add", "0.0.0.0", "mask", "0.0.0.0", gateway,
+		)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) restoreDns() (err error) {
+	iface := p.dnsIface()
+
+	switch runtime.GOOS {
+	case "linux":
+		if iface == "" {
+			break
+		}
+		err = setDnsLinux(iface, nil)
+		break
+	case "darwin":
+		err = utils.RestoreScutilDns()
+		break
+	case "windows":
+		if iface == "" {
+			break
+		}
+		err = setDnsWin(iface, nil)
+		break
+	}
+	if err != nil {
+		return
+	}
+
+	utils.ClearDNSCache()
+
+	return
+}
+
+// The tunnel interface is gone after disconnect, on Linux the snapshot DNS
+// servers are applied to the physical interface that held the default route
+func restoreSnapshotDns(p *Profile, snapshot *NetSnapshot) (err error) {
+	if runtime.GOOS != "linux" {
+		err = p.restoreDns()
+		return
+	}
+
+	tunIface := p.dnsIface()
+	if tunIface != "" {
+		_ = setDnsLinux(tunIface, nil)
+	}
+
+	if snapshot.Iface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Snapshot missing physical interface"),
+		}
+		return
+	}
+
+	_, err = net.InterfaceByName(snapshot.Iface)
+	if err != nil {
+		err = &errortypes.NotFoundError{
+			errors.Wrapf(err, "profile: Snapshot interface '%s' not found",
+				snapshot.Iface),
+		}
+		return
+	}
+
+	current, err := getDnsServers()
+	if err == nil && wgStrEqual(current, snapshot.DnsServers) {
+		utils.ClearDNSCache()
+		return
+	}
+	err = nil
+
+	input := ""
+	for _, dnsServer := range snapshot.DnsServers {
+		input += fmt.Sprintf("nameserver %s\n", dnsServer)
+	}
+
+	err = utils.ExecInput(
+		"",
+		input,
+		"resolvconf", "-a", snapshot.Iface, "-m", "0",
+	)
+	if err != nil {
+		return
+	}
+
+	utils.ClearDNSCache()
+
+	return
+}
+
+func currentNetwork() (snapshot *NetSnapshot, err error) {
+	snapshot = &NetSnapshot{
+		Timestamp: time.Now(),
+	}
+
+	gateway, iface, e := getDefaultRoute()
+	if e == nil {
+		snapshot.Gateway = gateway
+		snapshot.Iface = iface
+	}
+
+	dnsServers, err := getDnsServers()
+	if err != nil {
+		return
+	}
+	snapshot.DnsServers = dnsServers
+
+	return
+}
+
+func (p *Profile) snapshotNetwork() {
+	snapshot, err := readNetwork()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Warn("profile: Failed to read DNS servers for snapshot")
+	}
+
+	Registry.SetSnapshot(p.Id, snapshot)
+}
+
+func (p *Profile) verifyNetwork() {
+	snapshot, latest := Registry.Snapshot(p.Id)
+	if snapshot == nil || !latest {
+		return
+	}
+
+	current, err := readNetwork()
+
+	if snapshot.Gateway != "" && current.Gateway != snapshot.Gateway {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"expected":   snapshot.Gateway,
+			"current":    current.Gateway,
+		}).Warn("profile: Default route not restored, repairing")
+
+		err := repairGateway(snapshot.Gateway, current.Gateway)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to restore default route")
+		}
+	}
+
+	if snapshot.DnsServers != nil && err == nil &&
+		!wgStrEqual(current.DnsServers, snapshot.DnsServers) {
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"expected":   snapshot.DnsServers,
+			"current":    current.DnsServers,
+			"iface":      snapshot.Iface,
+		}).Warn("profile: DNS servers not restored, repairing")
+
+		err = repairDnsServers(p, snapshot)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to restore DNS servers")
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package profile
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+type fakeNetwork struct {
+	gateway    string
+	dnsServers []string
+	gwRepairs  int
+	dnsRepairs int
+}
+
+func setFakeNetwork(t *testing.T, netw *fakeNetwork) {
+	origRead := readNetwork
+	origGateway := repairGateway
+	origDns := repairDnsServers
+	origRegistry := Registry
+
+	readNetwork = func() (snapshot *NetSnapshot, err error) {
+		snapshot = &NetSnapshot{
+			Gateway:    netw.gateway,
+			DnsServers: append([]string{}, netw.dnsServers...),
+			Timestamp:  time.Now(),
+		}
+		return
+	}
+	repairGateway = func(gateway, current string) (err error) {
+		netw.gateway = gateway
+		netw.gwRepairs += 1
+		return
+	}
+	repairDnsServers = func(p *Profile, snapshot *NetSnapshot) (err error) {
+		netw.dnsServers = append([]string{}, snapshot.DnsServers...)
+		netw.dnsRepairs += 1
+		return
+	}
+	Registry = &ConnectionRegistry{
+		conns: map[string]*Connection{},
+	}
+
+	t.Cleanup(func() {
+		readNetwork = origRead
+		repairGateway = origGateway
+		repairDnsServers = origDns
+		Registry = origRegistry
+	})
+}
+
+func TestVerifyNetworkRepairsGateway(t *testing.T) {
+	netw := &fakeNetwork{
+		gateway:    "192.168.1.1",
+		dnsServers: []string{"192.168.1.53"},
+	}
+	setFakeNetwork(t, netw)
+
+	prfl := &Profile{
+		Id: "prfl0",
+	}
+	Registry.Register(prfl.Id, Ovpn)
+	prfl.snapshotNetwork()
+
+	// Teardown left the default route on the tunnel
+	netw.gateway = "10.8.0.1"
+
+	prfl.verifyNetwork()
+
+	if netw.gateway != "192.168.1.1" {
+		t.Errorf("default route not restored, got %s", netw.gateway)
+	}
+	if netw.gwRepairs != 1 {
+		t.Errorf("expected one gateway repair, got %d", netw.gwRepairs)
+	}
+	if netw.dnsRepairs != 0 {
+		t.Errorf("unexpected DNS repair")
+	}
+}
+
+func TestVerifyNetworkRepairsDns(t *testing.T) {
+	netw := &fakeNetwork{
+		gateway:    "192.168.1.1",
+		dnsServers: []string{"192.168.1.53"},
+	}
+	setFakeNetwork(t, netw)
+
+	prfl := &Profile{
+		Id: "prfl0",
+	}
+	Registry.Register(prfl.Id, Ovpn)
+	prfl.snapshotNetwork()
+
+	netw.dnsServers = []string{"10.8.0.53"}
+
+	prfl.verifyNetwork()
+
+	if !wgStrEqual(netw.dnsServers, []string{"192.168.1.53"}) {
+		t.Errorf("DNS servers not restored, got %v", netw.dnsServers)
+	}
+	if netw.gwRepairs != 0 {
+		t.Errorf("unexpected gateway repair")
+	}
+}
+
+func TestVerifyNetworkMultiTunnel(t *testing.T) {
+	netw := &fakeNetwork{
+		gateway:    "192.168.1.1",
+		dnsServers: []string{"192.168.1.53"},
+	}
+	setFakeNetwork(t, netw)
+
+	first := &Profile{
+		Id: "prfl0",
+	}
+	Registry.Register(first.Id, Ovpn)
+	first.snapshotNetwork()
+
+	netw.gateway = "10.8.0.1"
+	time.Sleep(time.Millisecond)
+
+	second := &Profile{
+		Id: "prfl1",
+	}
+	Registry.Register(second.Id, Wg)
+	second.snapshotNetwork()
+
+	// Older connection is torn down while a newer one is active, the
+	// routes belong to the newer connection and must not be touched
+	first.verifyNetwork()
+	Registry.Unregister(first.Id)
+
+	if netw.gwRepairs != 0 {
+		t.Errorf("gateway repaired while another tunnel is active")
+	}
+
+	// Last teardown inherits the original pre-connect snapshot
+	second.verifyNetwork()
+	Registry.Unregister(second.Id)
+
+	if netw.gateway != "192.168.1.1" {
+		t.Errorf("default route not restored, got %s", netw.gateway)
+	}
+}
+
+func TestParseDefaultRouteLinux(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux route output")
+	}
+
+	gateway, iface := parseDefaultRoute(
+		"default via 192.168.1.1 dev eth0 proto dhcp metric 100\n" +
+			"default via 10.8.0.1 dev tun0 metric 200\n")
+	if gateway != "192.168.1.1" || iface != "eth0" {
+		t.Errorf("unexpected default route %s %s", gateway, iface)
+	}
+}
@@ -0,0 +1,116 @@
+package profile
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	hookTimeout = 30 * time.Second
+)
+
+const (
+	HookPreConnect     = "pre_connect"
+	HookPostConnect    = "post_connect"
+	HookPreDisconnect  = "pre_disconnect"
+	HookPostDisconnect = "post_disconnect"
+)
+
+func (p *Profile) hookCmd(hook string) string {
+	switch hook {
+	case HookPreConnect:
+		return p.PreConnectCmd
+	case HookPostConnect:
+		return p.PostConnectCmd
+	case HookPreDisconnect:
+		return p.PreDisconnectCmd
+	case HookPostDisconnect:
+		return p.PostDisconnectCmd
+	}
+
+	return ""
+}
+
+func (p *Profile) runHook(hook string) {
+	cmdStr := strings.TrimSpace(p.hookCmd(hook))
+	if cmdStr == "" {
+		return
+	}
+
+	if !config.Config.AllowUserScripts {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"hook":       hook,
+		}).Warn("profile: Ignoring script hook, user scripts disabled")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	name := "/bin/sh"
+	args := []string{"-c", cmdStr}
+	if runtime.GOOS == "windows" {
+		name = "cmd.exe"
+		args = []string{"/C", cmdStr}
+	}
+
+	cmd := command.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(),
+		"PRITUNL_HOOK="+hook,
+		"PRITUNL_PROFILE_ID="+p.Id,
+		"PRITUNL_INTERFACE="+p.killSwitchIface(),
+		"PRITUNL_CLIENT_ADDR="+p.ClientAddr,
+		"PRITUNL_SERVER_ADDR="+p.ServerAddr,
+	)
+
+	output := &strings.Builder{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := command.Run(ctx, cmd)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"hook":       hook,
+			"output":     output.String(),
+			"error":      err,
+		}).Error("profile: Script hook failed")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"hook":       hook,
+		"output":     output.String(),
+	}).Info("profile: Script hook complete")
+}
+
+func (p *Profile) runHookBackground(hook string) {
+	if p.hookCmd(hook) == "" {
+		return
+	}
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		p.runHook(hook)
+	}()
+}
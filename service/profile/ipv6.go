@@ -0,0 +1,104 @@
+package profile
+
+import (
+	"runtime"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var ipv6BlockNetworks = []string{
+	"::/1",
+	"8000::/1",
+}
+
+func routeIpv6Block(add bool, network string) (err error) {
+	switch runtime.GOOS {
+	case "linux":
+		action := "del"
+		ignores := []string{"No such process"}
+		if add {
+			action = "add"
+			ignores = []string{"File exists"}
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			ignores,
+			"ip", "-6", "route", action, "unreachable", network,
+		)
+		break
+	case "darwin":
+		if add {
+			_, err = utils.ExecCombinedOutputLogged(
+				[]string{"File exists"},
+				"route", "-q", "-n", "add", "-inet6",
+				network, "::1", "-reject",
+			)
+		} else {
+			_, err = utils.ExecCombinedOutputLogged(
+				[]string{"not in table"},
+				"route", "-q", "-n", "delete", "-inet6", network,
+			)
+		}
+		break
+	case "windows":
+		action := "delete"
+		ignores := []string{"Element not found"}
+		if add {
+			action = "add"
+			ignores = []string{"already exists"}
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			ignores,
+			"netsh", "interface", "ipv6", action, "route",
+			network, "Loopback Pseudo-Interface 1", "store=active",
+		)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) addIpv6Block() {
+	if !p.BlockIpv6 || p.ipv6Blocked {
+		return
+	}
+
+	for _, network := range ipv6BlockNetworks {
+		err := routeIpv6Block(true, network)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      network,
+				"error":      err,
+			}).Error("profile: Failed to add IPv6 block route")
+		}
+	}
+
+	p.ipv6Blocked = true
+}
+
+func (p *Profile) clearIpv6Block() {
+	if !p.ipv6Blocked {
+		return
+	}
+
+	for _, network := range ipv6BlockNetworks {
+		err := routeIpv6Block(false, network)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      network,
+				"error":      err,
+			}).Error("profile: Failed to remove IPv6 block route")
+		}
+	}
+
+	p.ipv6Blocked = false
+}
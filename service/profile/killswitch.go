@@ -0,0 +1,103 @@
+package profile
+
+import (
+	"net/url"
+	"runtime"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/sirupsen/logrus"
+)
+
+func (p *Profile) killSwitchRemotes() (remotes []string) {
+	remotesSet := set.NewSet()
+	remotes = []string{}
+
+	for _, line := range strings.Split(p.Data, "\n") {
+		if !strings.HasPrefix(line, "remote ") {
+			continue
+		}
+
+		lineSpl := strings.Fields(line)
+		if len(lineSpl) < 2 {
+			continue
+		}
+
+		remote := lineSpl[1]
+		if !remotesSet.Contains(remote) {
+			remotesSet.Add(remote)
+			remotes = append(remotes, remote)
+		}
+	}
+
+	for _, syncAddr := range p.SyncHosts {
+		syncUrl, err := url.Parse(syncAddr)
+		if err != nil {
+			continue
+		}
+
+		remote := syncUrl.Hostname()
+		if remote != "" && !remotesSet.Contains(remote) {
+			remotesSet.Add(remote)
+			remotes = append(remotes, remote)
+		}
+	}
+
+	return
+}
+
+func (p *Profile) killSwitchIface() string {
+	if p.Mode == Wg {
+		if runtime.GOOS == "darwin" {
+			return p.Tuniface
+		}
+		return p.Iface
+	}
+	return p.ovpnIface
+}
+
+func (p *Profile) enableKillSwitch() (err error) {
+	if !p.KillSwitch {
+		return
+	}
+
+	resolvers, e := getUpstreamDnsServers()
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      e,
+		}).Warn("profile: Failed to get kill switch resolvers")
+	}
+
+	err = killswitch.Enable(p.Id, p.killSwitchRemotes(), resolvers)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) allowKillSwitch() {
+	if !p.KillSwitch {
+		return
+	}
+
+	err := killswitch.AllowInterface(p.Id, p.killSwitchIface())
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to allow kill switch interface")
+	}
+}
+
+func (p *Profile) disableKillSwitch() {
+	err := killswitch.Disable(p.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to disable kill switch")
+	}
+}
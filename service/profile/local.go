@@ -0,0 +1,235 @@
+package profile
+
+import (
+	"net"
+	"runtime/debug"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/sirupsen/logrus"
+)
+
+var localIfacePrefixes = []string{
+	"tun",
+	"tap",
+	"utun",
+	"wg",
+	"ppp",
+}
+
+func localNetworks() (networks []*net.IPNet) {
+	networks = []*net.IPNet{}
+
+	skipIfaces := set.NewSet()
+	tunnelAddrs := []net.IP{}
+	for _, conn := range Registry.All() {
+		if conn.Iface != "" {
+			skipIfaces.Add(conn.Iface)
+		}
+	}
+	for _, prfl := range GetProfiles() {
+		ip := net.ParseIP(strings.Split(prfl.ClientAddr, "/")[0])
+		if ip != nil {
+			tunnelAddrs = append(tunnelAddrs, ip)
+		}
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Failed to list network interfaces")
+		return
+	}
+
+	existing := set.NewSet()
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 ||
+			iface.Flags&net.FlagLoopback != 0 ||
+			iface.Flags&net.FlagPointToPoint != 0 ||
+			skipIfaces.Contains(iface.Name) {
+
+			continue
+		}
+
+		tunnel := false
+		for _, prefix := range localIfacePrefixes {
+			if strings.HasPrefix(iface.Name, prefix) {
+				tunnel = true
+				break
+			}
+		}
+		if tunnel {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+	Addrs:
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			ip := ipNet.IP.To4()
+			if ip == nil || !ip.IsPrivate() {
+				continue
+			}
+
+			ones, bits := ipNet.Mask.Size()
+			if bits != 32 || ones == 0 || ones == 32 {
+				continue
+			}
+
+			network := &net.IPNet{
+				IP:   ip.Mask(ipNet.Mask),
+				Mask: ipNet.Mask,
+			}
+
+			for _, tunnelAddr := range tunnelAddrs {
+				if network.Contains(tunnelAddr) {
+					continue Addrs
+				}
+			}
+
+			if existing.Contains(network.String()) {
+				continue
+			}
+			existing.Add(network.String())
+
+			networks = append(networks, network)
+		}
+	}
+
+	return
+}
+
+func (p *Profile) localRouteKey(network *net.IPNet) string {
+	return network.String() + " via " + p.localGateway
+}
+
+func (p *Profile) loadLocalGateway() {
+	p.localGateway = ""
+
+	if !p.AllowLocalNetwork {
+		return
+	}
+
+	gateway, err := getDefaultGateway()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to get gateway for local network")
+		return
+	}
+
+	p.localGateway = gateway
+}
+
+func (p *Profile) removeLocalRoute(network *net.IPNet) {
+	if Registry.RemoveRoute(p.Id, p.localRouteKey(network)) {
+		return
+	}
+
+	err := p.routeExclude(false, network, p.localGateway)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"route":      network.String(),
+			"error":      err,
+		}).Error("profile: Failed to remove local network route")
+	}
+}
+
+func (p *Profile) addLocalRoutes() {
+	p.localLock.Lock()
+	defer p.localLock.Unlock()
+
+	if p.localGateway == "" || p.stop {
+		return
+	}
+
+	networks := localNetworks()
+	current := set.NewSet()
+	for _, network := range networks {
+		current.Add(network.String())
+	}
+
+	routes := []*net.IPNet{}
+	added := set.NewSet()
+	for _, network := range p.localRoutes {
+		if !current.Contains(network.String()) {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      network.String(),
+			}).Info("profile: Removing stale local network route")
+
+			p.removeLocalRoute(network)
+			continue
+		}
+
+		routes = append(routes, network)
+		added.Add(network.String())
+	}
+
+	for _, network := range networks {
+		if added.Contains(network.String()) {
+			continue
+		}
+
+		err := p.routeExclude(true, network, p.localGateway)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      network.String(),
+				"error":      err,
+			}).Error("profile: Failed to add local network route")
+			continue
+		}
+
+		routes = append(routes, network)
+		Registry.AddRoute(p.Id, p.localRouteKey(network))
+	}
+
+	p.localRoutes = routes
+}
+
+func (p *Profile) clearLocalRoutes() {
+	p.localLock.Lock()
+	defer p.localLock.Unlock()
+
+	for _, network := range p.localRoutes {
+		p.removeLocalRoute(network)
+	}
+
+	p.localRoutes = nil
+}
+
+func RefreshLocalRoutes() {
+	for _, prfl := range GetProfiles() {
+		if !prfl.AllowLocalNetwork || !prfl.connected {
+			continue
+		}
+
+		go func(prfl *Profile) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"stack": string(debug.Stack()),
+						"panic": panc,
+					}).Error("profile: Panic")
+					panic(panc)
+				}
+			}()
+
+			prfl.addLocalRoutes()
+		}(prfl)
+	}
+}
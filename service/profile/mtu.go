@@ -0,0 +1,216 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mtuProbeMin     = 1200
+	mtuProbeMax     = 1472
+	mtuProbeTimeout = 2 * time.Second
+	mtuIpv4Header   = 28
+	mtuIpv6Header   = 48
+	mtuWgOverhead   = 32
+	mtuOvpnOverhead = 41
+	mtuMssOverhead  = 40
+)
+
+var (
+	mtuCache = struct {
+		sync.Mutex
+		m map[string]int
+	}{
+		m: map[string]int{},
+	}
+)
+
+func pingDf(host string, size int) bool {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), mtuProbeTimeout)
+	defer cancel()
+
+	sizeStr := strconv.Itoa(size)
+	args := []string{}
+
+	switch runtime.GOOS {
+	case "linux":
+		args = []string{"-c", "1", "-W", "1", "-M", "do", "-s", sizeStr, host}
+		break
+	case "darwin":
+		args = []string{"-c", "1", "-W", "1000", "-D", "-s", sizeStr, host}
+		break
+	case "windows":
+		args = []string{"-n", "1", "-w", "1000", "-f", "-l", sizeStr, host}
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+
+	_, err := command.Output(ctx, "ping", args...)
+	return err == nil
+}
+
+func probePathMtu(host string) (mtu int) {
+	header := mtuIpv4Header
+	ip := net.ParseIP(host)
+	if ip != nil && ip.To4() == nil {
+		header = mtuIpv6Header
+	}
+
+	if !pingDf(host, mtuProbeMin) {
+		return
+	}
+
+	low := mtuProbeMin
+	high := mtuProbeMax
+	for low < high {
+		mid := (low + high + 1) / 2
+		if pingDf(host, mid) {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	mtu = low + header
+
+	return
+}
+
+func (p *Profile) tunnelMtu(pathMtu int) int {
+	header := mtuIpv4Header
+	ip := net.ParseIP(p.ServerAddr)
+	if ip != nil && ip.To4() == nil {
+		header = mtuIpv6Header
+	}
+
+	if p.Mode == Wg {
+		return pathMtu - header - mtuWgOverhead
+	}
+	return pathMtu - header - mtuOvpnOverhead
+}
+
+func (p *Profile) mtuOvpn() (output string) {
+	if !p.AutoMtu {
+		return
+	}
+
+	mtuCache.Lock()
+	mtu := mtuCache.m[p.Id]
+	mtuCache.Unlock()
+
+	if mtu == 0 {
+		return
+	}
+
+	output = fmt.Sprintf("tun-mtu %d\n", mtu)
+	output += fmt.Sprintf("mssfix %d\n", mtu-mtuMssOverhead)
+
+	return
+}
+
+func (p *Profile) setIfaceMtu(iface string, mtu int) (err error) {
+	mtuStr := strconv.Itoa(mtu)
+
+	switch runtime.GOOS {
+	case "linux":
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link", "set", "dev", iface, "mtu", mtuStr,
+		)
+		break
+	case "darwin":
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ifconfig", iface, "mtu", mtuStr,
+		)
+		break
+	case "windows":
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"netsh", "interface", "ipv4", "set", "subinterface",
+			iface, "mtu="+mtuStr, "store=active",
+		)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) probeMtu() {
+	host := p.ServerAddr
+	if host == "" {
+		return
+	}
+
+	pathMtu := probePathMtu(host)
+	if pathMtu == 0 {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"host":       host,
+		}).Warn("profile: MTU probe failed, using default MTU")
+		return
+	}
+
+	mtu := p.tunnelMtu(pathMtu)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"path_mtu":   pathMtu,
+		"mtu":        mtu,
+	}).Info("profile: MTU probe complete")
+
+	mtuCache.Lock()
+	mtuCache.m[p.Id] = mtu
+	mtuCache.Unlock()
+
+	iface := p.killSwitchIface()
+	if iface == "" || p.stop {
+		return
+	}
+
+	err := p.setIfaceMtu(iface, mtu)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to set interface MTU")
+	}
+}
+
+func (p *Profile) probeMtuBackground() {
+	if !p.AutoMtu {
+		return
+	}
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		p.probeMtu()
+	}()
+}
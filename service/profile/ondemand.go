@@ -0,0 +1,361 @@
+package profile
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	onDemandDefaultInterval = 60 * time.Second
+	onDemandMinInterval     = 10 * time.Second
+	onDemandResolveTtl      = 5 * time.Minute
+	onDemandResolveTimeout  = 3 * time.Second
+)
+
+type onDemandResolve struct {
+	ips       []net.IP
+	timestamp time.Time
+}
+
+var onDemand = struct {
+	sync.Mutex
+	triggered map[string]time.Time
+	disabled  set.Set
+	resolved  map[string]*onDemandResolve
+}{
+	triggered: map[string]time.Time{},
+	disabled:  set.NewSet(),
+	resolved:  map[string]*onDemandResolve{},
+}
+
+func onDemandInterval() time.Duration {
+	interval := time.Duration(config.Config.OnDemandInterval) * time.Second
+	if interval == 0 {
+		return onDemandDefaultInterval
+	}
+	if interval < onDemandMinInterval {
+		return onDemandMinInterval
+	}
+	return interval
+}
+
+func DisableOnDemand(prflId string) {
+	onDemand.Lock()
+	if !onDemand.disabled.Contains(prflId) {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+		}).Info("profile: On-demand connect disabled until next connect")
+	}
+	onDemand.disabled.Add(prflId)
+	onDemand.Unlock()
+}
+
+func EnableOnDemand(prflId string) {
+	onDemand.Lock()
+	onDemand.disabled.Remove(prflId)
+	onDemand.Unlock()
+}
+
+func ResetOnDemand() {
+	onDemand.Lock()
+	onDemand.disabled = set.NewSet()
+	onDemand.resolved = map[string]*onDemandResolve{}
+	onDemand.Unlock()
+}
+
+func onDemandDomains(sPrfl *sprofile.Sprofile) (domains []string) {
+	domains = []string{}
+
+	for _, domain := range sPrfl.OnDemandDomains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" || !domainReg.MatchString(domain) {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+
+	return
+}
+
+func onDemandNetworks(sPrfl *sprofile.Sprofile) (networks []*net.IPNet) {
+	networks = []*net.IPNet{}
+
+	for _, cidr := range sPrfl.OnDemandCidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				continue
+			}
+			cidr = ip.String() + "/32"
+			if ip.To4() == nil {
+				cidr = ip.String() + "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": sPrfl.Id,
+				"cidr":       cidr,
+			}).Warn("profile: Ignoring invalid on-demand network")
+			continue
+		}
+
+		networks = append(networks, network)
+	}
+
+	return
+}
+
+func onDemandResolveDomain(domain string) (ips []net.IP) {
+	onDemand.Lock()
+	cached := onDemand.resolved[domain]
+	onDemand.Unlock()
+
+	if cached != nil &&
+		utils.SinceAbs(cached.timestamp) < onDemandResolveTtl {
+
+		return cached.ips
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), onDemandResolveTimeout)
+	defer cancel()
+
+	ips = []net.IP{}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, domain)
+	if err == nil {
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	onDemand.Lock()
+	onDemand.resolved[domain] = &onDemandResolve{
+		ips:       ips,
+		timestamp: time.Now(),
+	}
+	onDemand.Unlock()
+
+	return
+}
+
+func parseConnAddr(addr string, dotPort bool) net.IP {
+	if dotPort {
+		i := strings.LastIndex(addr, ".")
+		if i < 0 {
+			return nil
+		}
+		return net.ParseIP(addr[:i])
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(strings.Split(host, "%")[0])
+}
+
+func pendingDestinations() (ips []net.IP) {
+	ips = []net.IP{}
+
+	switch runtime.GOOS {
+	case "linux":
+		output, err := utils.ExecOutput("ss", "-Htn", "state", "syn-sent")
+		if err != nil {
+			return
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 4 {
+				continue
+			}
+
+			ip := parseConnAddr(fields[len(fields)-1], false)
+			if ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		break
+	case "darwin", "windows":
+		args := []string{"-an", "-p", "tcp"}
+		if runtime.GOOS == "windows" {
+			args = []string{"-an", "-p", "TCP"}
+		}
+
+		output, err := utils.ExecOutput("netstat", args...)
+		if err != nil {
+			return
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[len(fields)-1] != "SYN_SENT" {
+				continue
+			}
+
+			ip := parseConnAddr(fields[len(fields)-2],
+				runtime.GOOS == "darwin")
+			if ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+
+	return
+}
+
+func cachedDnsNames() (names []string) {
+	names = []string{}
+
+	if runtime.GOOS != "windows" {
+		return
+	}
+
+	output, err := utils.ExecOutput("ipconfig", "/displaydns")
+	if err != nil {
+		return
+	}
+
+	existing := set.NewSet()
+	for _, line := range strings.Split(output, "\n") {
+		lineSpl := strings.SplitN(line, ":", 2)
+		if len(lineSpl) != 2 ||
+			!strings.HasPrefix(strings.TrimSpace(lineSpl[0]), "Record Name") {
+
+			continue
+		}
+
+		name := strings.Trim(strings.ToLower(
+			strings.TrimSpace(lineSpl[1])), ".")
+		if name == "" || existing.Contains(name) {
+			continue
+		}
+		existing.Add(name)
+		names = append(names, name)
+	}
+
+	return
+}
+
+func onDemandMatch(sPrfl *sprofile.Sprofile, dests []net.IP,
+	names []string) (match string) {
+
+	domains := onDemandDomains(sPrfl)
+
+	for _, name := range names {
+		for _, domain := range domains {
+			if name == domain || strings.HasSuffix(name, "."+domain) {
+				match = name
+				return
+			}
+		}
+	}
+
+	if len(dests) == 0 {
+		return
+	}
+
+	networks := onDemandNetworks(sPrfl)
+	for _, dest := range dests {
+		for _, network := range networks {
+			if network.Contains(dest) {
+				match = dest.String()
+				return
+			}
+		}
+	}
+
+	for _, domain := range domains {
+		for _, ip := range onDemandResolveDomain(domain) {
+			for _, dest := range dests {
+				if ip.Equal(dest) {
+					match = domain
+					return
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func CheckOnDemand() {
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return
+	}
+
+	prfls := GetProfiles()
+	interval := onDemandInterval()
+
+	candidates := []*sprofile.Sprofile{}
+	onDemand.Lock()
+	for _, sPrfl := range sprfls {
+		if sPrfl.State || sPrfl.Disabled || prfls[sPrfl.Id] != nil ||
+			(len(sPrfl.OnDemandDomains) == 0 &&
+				len(sPrfl.OnDemandCidrs) == 0) ||
+			onDemand.disabled.Contains(sPrfl.Id) ||
+			utils.SinceAbs(onDemand.triggered[sPrfl.Id]) < interval {
+
+			continue
+		}
+
+		if sPrfl.Interactive() {
+			continue
+		}
+
+		candidates = append(candidates, sPrfl)
+	}
+	onDemand.Unlock()
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	dests := pendingDestinations()
+	names := cachedDnsNames()
+
+	for _, sPrfl := range candidates {
+		match := onDemandMatch(sPrfl, dests, names)
+		if match == "" {
+			continue
+		}
+
+		onDemand.Lock()
+		onDemand.triggered[sPrfl.Id] = time.Now()
+		onDemand.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": sPrfl.Id,
+			"match":      match,
+		}).Info("profile: Connecting on demand")
+
+		err = sprofile.Activate(sPrfl.Id, sPrfl.LastMode, sPrfl.Password)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": sPrfl.Id,
+				"error":      err,
+			}).Error("profile: Failed to activate on-demand profile")
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package profile
+
+import (
+	"sync"
+	"time"
+)
+
+type otpEntry struct {
+	password string
+	expires  time.Time
+}
+
+var (
+	otpCache = struct {
+		sync.Mutex
+		m map[string]*otpEntry
+	}{
+		m: map[string]*otpEntry{},
+	}
+)
+
+func OtpCacheGet(prflId string) (password string) {
+	otpCache.Lock()
+	defer otpCache.Unlock()
+
+	entry := otpCache.m[prflId]
+	if entry == nil {
+		return
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(otpCache.m, prflId)
+		return
+	}
+
+	password = entry.password
+
+	return
+}
+
+func OtpCacheSet(prflId, password string, ttl time.Duration) {
+	if prflId == "" || password == "" || ttl <= 0 {
+		return
+	}
+
+	otpCache.Lock()
+	otpCache.m[prflId] = &otpEntry{
+		password: password,
+		expires:  time.Now().Add(ttl),
+	}
+	otpCache.Unlock()
+}
+
+func OtpCacheClear(prflId string) {
+	otpCache.Lock()
+	delete(otpCache.m, prflId)
+	otpCache.Unlock()
+}
+
+func OtpCacheIds() (prflIds []string) {
+	otpCache.Lock()
+	defer otpCache.Unlock()
+
+	prflIds = []string{}
+	for prflId, entry := range otpCache.m {
+		if time.Now().After(entry.expires) {
+			delete(otpCache.m, prflId)
+			continue
+		}
+		prflIds = append(prflIds, prflId)
+	}
+
+	return
+}
+
+func (p *Profile) loadOtpCache() {
+	if p.OtpCacheTtl <= 0 || p.Password != "" {
+		return
+	}
+
+	p.Password = OtpCacheGet(p.Id)
+}
+
+func (p *Profile) storeOtpCache() {
+	if p.OtpCacheTtl <= 0 {
+		return
+	}
+
+	OtpCacheSet(p.Id, p.Password,
+		time.Duration(p.OtpCacheTtl)*time.Second)
+}
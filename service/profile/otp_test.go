@@ -0,0 +1,112 @@
+package profile
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOtpCacheExpiry(t *testing.T) {
+	t.Cleanup(func() {
+		OtpCacheClear("otp0")
+	})
+
+	OtpCacheSet("otp0", "123456", 50*time.Millisecond)
+
+	if pass := OtpCacheGet("otp0"); pass != "123456" {
+		t.Errorf("expected cached password, got %q", pass)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if pass := OtpCacheGet("otp0"); pass != "" {
+		t.Errorf("expected expired password, got %q", pass)
+	}
+
+	for _, prflId := range OtpCacheIds() {
+		if prflId == "otp0" {
+			t.Error("expired entry still listed")
+		}
+	}
+}
+
+func TestOtpCacheDisabled(t *testing.T) {
+	OtpCacheSet("otp1", "123456", 0)
+	if pass := OtpCacheGet("otp1"); pass != "" {
+		t.Errorf("cache without ttl stored password %q", pass)
+	}
+
+	prfl := &Profile{
+		Id:       "otp1",
+		Password: "123456",
+	}
+	prfl.storeOtpCache()
+	if pass := OtpCacheGet("otp1"); pass != "" {
+		t.Errorf("profile without ttl stored password %q", pass)
+	}
+}
+
+func TestOtpCacheReconnect(t *testing.T) {
+	t.Cleanup(func() {
+		OtpCacheClear("otp2")
+	})
+
+	prfl := &Profile{
+		Id:          "otp2",
+		Password:    "654321",
+		OtpCacheTtl: 60,
+	}
+	prfl.storeOtpCache()
+
+	reconn := &Profile{
+		Id:          "otp2",
+		OtpCacheTtl: 60,
+	}
+	reconn.loadOtpCache()
+	if reconn.Password != "654321" {
+		t.Errorf("reconnect did not reuse password, got %q",
+			reconn.Password)
+	}
+
+	// Explicit disconnect clears the cache
+	Disconnect("otp2")
+
+	reconn = &Profile{
+		Id:          "otp2",
+		OtpCacheTtl: 60,
+	}
+	reconn.loadOtpCache()
+	if reconn.Password != "" {
+		t.Errorf("password reused after disconnect %q", reconn.Password)
+	}
+}
+
+func TestOtpCacheDisconnectReconnecting(t *testing.T) {
+	prfl := &Profile{
+		Id:              "otp3",
+		Password:        "111111",
+		OtpCacheTtl:     60,
+		stopping:        true,
+		reconnectCancel: make(chan bool),
+	}
+	prfl.storeOtpCache()
+
+	Profiles.Lock()
+	Profiles.m[prfl.Id] = prfl
+	Profiles.Unlock()
+	t.Cleanup(func() {
+		Profiles.Lock()
+		delete(Profiles.m, prfl.Id)
+		Profiles.Unlock()
+		OtpCacheClear(prfl.Id)
+	})
+
+	// Disconnect while waiting to reconnect stops the profile
+	Disconnect(prfl.Id)
+
+	if !prfl.reconnectCanceled {
+		t.Error("pending reconnect not canceled")
+	}
+	if pass := OtpCacheGet(prfl.Id); pass != "" {
+		t.Errorf("password cached after disconnect %q", pass)
+	}
+}
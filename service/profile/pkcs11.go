@@ -0,0 +1,217 @@
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/pritunl/pritunl-client-electron/service/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	pkcs11Timeout     = 120 * time.Second
+	pkcs11PinPrompt   = ">PASSWORD:Need '"
+	pkcs11PinFailed   = ">PASSWORD:Verification Failed"
+	pkcs11FeatureFlag = "[PKCS11]"
+)
+
+var (
+	pkcs11Support     bool
+	pkcs11SupportErr  error
+	pkcs11SupportOnce sync.Once
+)
+
+func (p *Profile) pkcs11Enabled() bool {
+	return strings.TrimSpace(p.Pkcs11Provider) != ""
+}
+
+func (p *Profile) pkcs11Args() (args []string) {
+	if !p.pkcs11Enabled() {
+		return
+	}
+
+	args = []string{
+		"--pkcs11-providers", p.Pkcs11Provider,
+	}
+	if p.Pkcs11Id != "" {
+		args = append(args, "--pkcs11-id", p.Pkcs11Id)
+	}
+
+	return
+}
+
+func checkPkcs11Support() (err error) {
+	pkcs11SupportOnce.Do(func() {
+		output, e := getOpenvpnVersion()
+		if e != nil {
+			pkcs11SupportErr = e
+			return
+		}
+
+		pkcs11Support = strings.Contains(output, pkcs11FeatureFlag)
+	})
+
+	if pkcs11SupportErr != nil {
+		err = pkcs11SupportErr
+		return
+	}
+
+	if !pkcs11Support {
+		err = &errortypes.ExecError{
+			errors.New("profile: Bundled OpenVPN was not built with " +
+				"PKCS#11 support"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Profile) validatePkcs11() (err error) {
+	exists, err := utils.Exists(p.Pkcs11Provider)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.Newf("profile: PKCS#11 provider library '%s' not found",
+				p.Pkcs11Provider),
+		}
+		return
+	}
+
+	err = checkPkcs11Support()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func pkcs11Escape(val string) string {
+	val = strings.ReplaceAll(val, "\\", "\\\\")
+	val = strings.ReplaceAll(val, "\"", "\\\"")
+	return "\"" + val + "\""
+}
+
+func (p *Profile) pkcs11AuthError(reason string) {
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+	}).Error(reason)
+
+	evt := event.Event{
+		Type:      "auth_error",
+		ProfileId: p.Id,
+	}
+	evt.Init(p)
+	p.sendWebhook(webhook.AuthFailure, "failure", "")
+
+	p.StopBackground()
+}
+
+func (p *Profile) pkcs11Pin() (err error) {
+	p.managementLock.Lock()
+	defer p.managementLock.Unlock()
+
+	var conn net.Conn
+	var reader *bufio.Reader
+	start := time.Now()
+
+	for {
+		if p.stop {
+			return
+		}
+
+		conn, reader, err = p.openManagement()
+		if err == nil {
+			break
+		}
+
+		if time.Since(start) > pkcs11Timeout {
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	defer conn.Close()
+
+	for {
+		if p.stop || p.connected || time.Since(start) > pkcs11Timeout {
+			return
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+		line, e := reader.ReadString('\n')
+		if e != nil {
+			if ne, ok := e.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return
+		}
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, pkcs11PinFailed) {
+			p.pkcs11AuthError("profile: PKCS#11 token PIN rejected")
+			return
+		}
+
+		if !strings.HasPrefix(line, pkcs11PinPrompt) {
+			continue
+		}
+
+		name := strings.TrimPrefix(line, pkcs11PinPrompt)
+		name = strings.SplitN(name, "'", 2)[0]
+
+		if p.Pkcs11Pin == "" {
+			p.pkcs11AuthError("profile: PKCS#11 token PIN required")
+			return
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(3 * time.Second))
+		_, err = conn.Write([]byte(fmt.Sprintf("password %s %s\n",
+			pkcs11Escape(name), pkcs11Escape(p.Pkcs11Pin))))
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "profile: Failed to write token PIN"),
+			}
+			return
+		}
+	}
+}
+
+func (p *Profile) pkcs11PinBackground() {
+	if !p.pkcs11Enabled() {
+		return
+	}
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		err := p.pkcs11Pin()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to provide PKCS#11 token PIN")
+		}
+	}()
+}
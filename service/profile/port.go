@@ -1,8 +1,19 @@
 package profile
 
 import (
+	"bufio"
 	"sort"
+	"strings"
 	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	managementPassPrompt  = "ENTER PASSWORD:"
+	managementPassSuccess = "SUCCESS: password is correct"
+	managementPassFailed  = "ERROR: bad password"
 )
 
 var (
@@ -45,6 +56,33 @@ func ManagementPortRelease(port int) {
 	return
 }
 
+func readManagement(reader *bufio.Reader, matches ...string) (
+	match string, err error) {
+
+	buf := ""
+	for {
+		b, e := reader.ReadByte()
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read management socket"),
+			}
+			return
+		}
+		buf += string(b)
+
+		for _, m := range matches {
+			if strings.Contains(buf, m) {
+				match = m
+				return
+			}
+		}
+
+		if b == '\n' {
+			buf = ""
+		}
+	}
+}
+
 func init() {
 	for i := 1; i < 100; i++ {
 		ports = append(ports, 9700+i)
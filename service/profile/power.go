@@ -0,0 +1,112 @@
+package profile
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	suspended     = []*Profile{}
+	suspendedLock = sync.Mutex{}
+)
+
+func Suspend() {
+	waiter := sync.WaitGroup{}
+
+	for _, prfl := range GetProfiles() {
+		if !prfl.DisconnectOnSleep {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prfl.Id,
+		}).Info("profile: Disconnecting for system sleep")
+
+		if prfl.Reconnect {
+			suspendedLock.Lock()
+			suspended = append(suspended, prfl.Copy())
+			suspendedLock.Unlock()
+		}
+
+		waiter.Add(1)
+		go func(prfl *Profile) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"stack": string(debug.Stack()),
+						"panic": panc,
+					}).Error("profile: Panic")
+					panic(panc)
+				}
+			}()
+			defer waiter.Done()
+
+			prfl.Stop()
+		}(prfl)
+	}
+
+	waiter.Wait()
+}
+
+func Resume(restart bool) {
+	suspendedLock.Lock()
+	prfls := suspended
+	suspended = []*Profile{}
+	suspendedLock.Unlock()
+
+	if restart {
+		RestartConnected(ReconnectResume)
+	}
+
+	for _, prfl := range prfls {
+		go func(prfl *Profile) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"stack": string(debug.Stack()),
+						"panic": panc,
+					}).Error("profile: Panic")
+					panic(panc)
+				}
+			}()
+
+			prfl.resume()
+		}(prfl)
+	}
+}
+
+func (p *Profile) resume() {
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"reason":     ReconnectResume,
+	}).Info("profile: Reconnecting")
+
+	p.Status = "reconnecting"
+	p.update()
+
+	evt := event.Event{
+		Type:      "reconnecting",
+		ProfileId: p.Id,
+	}
+	evt.Init(&ReconnectData{
+		Id:          p.Id,
+		MaxAttempts: p.ReconnectMaxAttempts,
+		Reason:      ReconnectResume,
+	})
+	p.sendWebhook(webhook.Reconnect, "pending", ReconnectResume)
+
+	err := p.Start(false, false)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to reconnect after resume")
+		return
+	}
+}
@@ -47,12 +47,13 @@ import (
 	"github.com/pritunl/pritunl-client-electron/service/token"
 	"github.com/pritunl/pritunl-client-electron/service/tuntap"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/pritunl/pritunl-client-electron/service/webhook"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/nacl/box"
 )
 
 const (
-	connTimeout  = 60 * time.Second
+	connTimeout  = 30 * time.Second
 	resetWait    = 3000 * time.Millisecond
 	netResetWait = 4000 * time.Millisecond
 )
@@ -179,8 +180,9 @@ type OvpnData struct {
 }
 
 type WgPingData struct {
-	Status    bool `json:"status"`
-	Timestamp int  `json:"timestamp"`
+	Status        bool    `json:"status"`
+	Timestamp     int     `json:"timestamp"`
+	Configuration *WgConf `json:"configuration"`
 }
 
 type OutputData struct {
@@ -188,6 +190,11 @@ type OutputData struct {
 	Output string `json:"output"`
 }
 
+type ConfigErrorData struct {
+	Id    string `json:"id"`
+	Error string `json:"error"`
+}
+
 type Profile struct {
 	state           bool         `json:"-"`
 	stopping        bool         `json:"-"`
@@ -200,61 +207,123 @@ type Profile struct {
 	startWaitClosed bool         `json:"-"`
 	parsedPrfl      *parser.Ovpn `json:"-"`
 
-	wgQuickLock        sync.Mutex         `json:"-"`
-	startTime          time.Time          `json:"-"`
-	authFailed         bool               `json:"-"`
-	remPaths           []string           `json:"-"`
-	bashPath           string             `json:"-"`
-	wgPath             string             `json:"-"`
-	wgQuickPath        string             `json:"-"`
-	wgConfPth          string             `json:"-"`
-	wgHandshake        int                `json:"-"`
-	wgServerPublicKey  string             `json:"-"`
-	openReqCancel      context.CancelFunc `json:"-"`
-	cmd                *exec.Cmd          `json:"-"`
-	tap                string             `json:"-"`
-	lastAuthErr        time.Time          `json:"-"`
-	token              *token.Token       `json:"-"`
-	managementPass     string             `json:"-"`
-	managementPort     int                `json:"-"`
-	Id                 string             `json:"id"`
-	Mode               string             `json:"mode"`
-	OrgId              string             `json:"-"`
-	UserId             string             `json:"-"`
-	ServerId           string             `json:"-"`
-	SyncHosts          []string           `json:"-"`
-	SyncToken          string             `json:"-"`
-	SyncSecret         string             `json:"-"`
-	PrivateKeyWg       string             `json:"-"`
-	PublicKeyWg        string             `json:"-"`
-	PrivateKey         string             `json:"-"`
-	DeviceId           string             `json:"-"`
-	DeviceName         string             `json:"-"`
-	Data               string             `json:"-"`
-	Username           string             `json:"-"`
-	Password           string             `json:"-"`
-	DynamicFirewall    bool               `json:"-"`
-	DisableGateway     bool               `json:"-"`
-	SsoAuth            bool               `json:"-"`
-	ServerPublicKey    string             `json:"-"`
-	ServerBoxPublicKey string             `json:"-"`
-	TokenTtl           int                `json:"-"`
-	Iface              string             `json:"iface"`
-	Tuniface           string             `json:"tun_iface"`
-	Routes             []*Route           `json:"routes'"`
-	Routes6            []*Route           `json:"routes6'"`
-	Reconnect          bool               `json:"reconnect"`
-	Status             string             `json:"status"`
-	Timestamp          int64              `json:"timestamp"`
-	GatewayAddr        string             `json:"gateway_addr"`
-	GatewayAddr6       string             `json:"gateway_addr6"`
-	ServerAddr         string             `json:"server_addr"`
-	ClientAddr         string             `json:"client_addr"`
-	MacAddr            string             `json:"mac_addr"`
-	MacAddrs           []string           `json:"mac_addrs"`
-	WebPort            int                `json:"web_port"`
-	WebNoSsl           bool               `json:"web_no_ssl"`
-	SystemProfile      *sprofile.Sprofile `json:"-"`
+	wgQuickLock          sync.Mutex         `json:"-"`
+	startTime            time.Time          `json:"-"`
+	authFailed           bool               `json:"-"`
+	remPaths             []string           `json:"-"`
+	bashPath             string             `json:"-"`
+	wgPath               string             `json:"-"`
+	wgQuickPath          string             `json:"-"`
+	wgConfPth            string             `json:"-"`
+	wgHandshake          int                `json:"-"`
+	wgServerPublicKey    string             `json:"-"`
+	wgConf               *WgConf            `json:"-"`
+	reconnectCancel      chan bool          `json:"-"`
+	reconnectCanceled    bool               `json:"-"`
+	reconnectReason      string             `json:"-"`
+	ovpnIface            string             `json:"-"`
+	ovpnDnsServers       []string           `json:"-"`
+	bytesWatch           bool               `json:"-"`
+	rxBytes              uint64             `json:"-"`
+	txBytes              uint64             `json:"-"`
+	ipv6Blocked          bool               `json:"-"`
+	failureReason        string             `json:"-"`
+	excludeGateway       string             `json:"-"`
+	doh                  *dohResolver       `json:"-"`
+	wgPorts              []int              `json:"-"`
+	wgPortIndex          int                `json:"-"`
+	localGateway         string             `json:"-"`
+	localRoutes          []*net.IPNet       `json:"-"`
+	localLock            sync.Mutex         `json:"-"`
+	searchDomainsSet     bool               `json:"-"`
+	searchDomainsIface   string             `json:"-"`
+	searchDomainsOrig    []string           `json:"-"`
+	searchDomainsLock    sync.Mutex         `json:"-"`
+	cipherPol            *CipherPolicy      `json:"-"`
+	cipherChecked        string             `json:"-"`
+	customGateway        string             `json:"-"`
+	customRoutesAdded    []*CustomRoute     `json:"-"`
+	excludeRoutes        []*net.IPNet       `json:"-"`
+	openReqCancel        context.CancelFunc `json:"-"`
+	cmd                  *exec.Cmd          `json:"-"`
+	tap                  string             `json:"-"`
+	lastAuthErr          time.Time          `json:"-"`
+	token                *token.Token       `json:"-"`
+	managementPass       string             `json:"-"`
+	managementPort       int                `json:"-"`
+	Id                   string             `json:"id"`
+	Mode                 string             `json:"mode"`
+	OrgId                string             `json:"-"`
+	UserId               string             `json:"-"`
+	ServerId             string             `json:"-"`
+	SyncHosts            []string           `json:"-"`
+	SyncToken            string             `json:"-"`
+	SyncSecret           string             `json:"-"`
+	PrivateKeyWg         string             `json:"-"`
+	PublicKeyWg          string             `json:"-"`
+	PrivateKey           string             `json:"-"`
+	DeviceId             string             `json:"-"`
+	DeviceName           string             `json:"-"`
+	Data                 string             `json:"-"`
+	Username             string             `json:"-"`
+	Password             string             `json:"-"`
+	DynamicFirewall      bool               `json:"-"`
+	DisableGateway       bool               `json:"-"`
+	ExcludeRoutes        []string           `json:"-"`
+	CustomRoutes         []*CustomRoute     `json:"-"`
+	PreConnectCmd        string             `json:"-"`
+	PostConnectCmd       string             `json:"-"`
+	PreDisconnectCmd     string             `json:"-"`
+	PostDisconnectCmd    string             `json:"-"`
+	SsoAuth              bool               `json:"-"`
+	ServerPublicKey      string             `json:"-"`
+	ServerBoxPublicKey   string             `json:"-"`
+	TokenTtl             int                `json:"-"`
+	OtpCacheTtl          int                `json:"-"`
+	ReconnectMaxAttempts int                `json:"-"`
+	ForceDns             bool               `json:"-"`
+	KillSwitch           bool               `json:"-"`
+	BytesInterval        int                `json:"-"`
+	BlockIpv6            bool               `json:"-"`
+	ConnectTimeout       int                `json:"-"`
+	ProxyType            string             `json:"-"`
+	ProxyHost            string             `json:"-"`
+	ProxyPort            int                `json:"-"`
+	ProxyUser            string             `json:"-"`
+	ProxyPass            string             `json:"-"`
+	AutoMtu              bool               `json:"-"`
+	DnsOverHttps         bool               `json:"-"`
+	DnsOverHttpsUpstream string             `json:"-"`
+	DisconnectOnSleep    bool               `json:"-"`
+	Pkcs11Provider       string             `json:"-"`
+	Pkcs11Id             string             `json:"-"`
+	Pkcs11Pin            string             `json:"-"`
+	WgPorts              []int              `json:"-"`
+	RemotePorts          []int              `json:"-"`
+	AllowLocalNetwork    bool               `json:"-"`
+	SearchDomains        []string           `json:"-"`
+	SplitDns             bool               `json:"-"`
+	TlsVersionMin        string             `json:"-"`
+	DataCiphers          []string           `json:"-"`
+	TlsCipher            string             `json:"-"`
+	Iface                string             `json:"iface"`
+	Tuniface             string             `json:"tun_iface"`
+	Routes               []*Route           `json:"routes'"`
+	Routes6              []*Route           `json:"routes6'"`
+	Reconnect            bool               `json:"reconnect"`
+	FailureReason        string             `json:"failure_reason"`
+	ReconnectAttempt     int                `json:"reconnect_attempt"`
+	Status               string             `json:"status"`
+	Timestamp            int64              `json:"timestamp"`
+	GatewayAddr          string             `json:"gateway_addr"`
+	GatewayAddr6         string             `json:"gateway_addr6"`
+	ServerAddr           string             `json:"server_addr"`
+	ClientAddr           string             `json:"client_addr"`
+	MacAddr              string             `json:"mac_addr"`
+	MacAddrs             []string           `json:"mac_addrs"`
+	WebPort              int                `json:"web_port"`
+	WebNoSsl             bool               `json:"web_no_ssl"`
+	SystemProfile        *sprofile.Sprofile `json:"-"`
 }
 
 type AuthData struct {
@@ -276,9 +345,35 @@ func (p *Profile) write(fixedRemote, fixedRemote6 string) (
 
 	p.parsedPrfl = parser.Import(
 		p.Data, fixedRemote, fixedRemote6, p.DisableGateway)
+	p.parsedPrfl.ExcludeRoutes = p.excludeRoutesOvpn()
+	p.parsedPrfl.BlockIpv6 = p.BlockIpv6
+	p.parsedPrfl.RemotePorts = p.RemotePorts
+
+	if p.SystemProfile != nil && p.SystemProfile.CredentialSource() ==
+		sprofile.CredentialsMachine {
+
+		p.parsedPrfl.Cert, p.parsedPrfl.Key, err =
+			p.SystemProfile.LoadMachineCert()
+		if err != nil {
+			return
+		}
+	}
+
 	data := p.parsedPrfl.Export()
 
-	if runtime.GOOS == "windows" {
+	proxy, err := p.proxyOvpn()
+	if err != nil {
+		return
+	}
+	data += proxy
+
+	metric := Registry.Metric(p.Id)
+	if metric > 0 {
+		data += fmt.Sprintf("route-metric %d\n", metric)
+	}
+	data += p.mtuOvpn()
+
+	if runtime.GOOS == "windows" || p.pkcs11Enabled() {
 		p.managementPort = ManagementPortAcquire()
 
 		managementPassPath, e := p.writeManagementPass()
@@ -293,6 +388,10 @@ func (p *Profile) write(fixedRemote, fixedRemote6 string) (
 			p.managementPort,
 			strings.ReplaceAll(managementPassPath, "\\", "\\\\"),
 		)
+
+		if p.pkcs11Enabled() {
+			data += "management-query-passwords\n"
+		}
 	}
 
 	_ = os.Remove(pth)
@@ -689,7 +788,7 @@ func (p *Profile) writeConfWgLinux() (pth string, err error) {
 	return
 }
 
-func (p *Profile) writeConfWgQuick(data *WgConf) (pth string, err error) {
+func (p *Profile) wgConfData(data *WgConf) (templData WgConfData) {
 	allowedIps := []string{}
 	if data.Routes != nil {
 		for _, route := range data.Routes {
@@ -707,7 +806,7 @@ func (p *Profile) writeConfWgQuick(data *WgConf) (pth string, err error) {
 		addr += "," + data.Address6
 	}
 
-	templData := WgConfData{
+	templData = WgConfData{
 		Address:    addr,
 		PrivateKey: p.PrivateKeyWg,
 		PublicKey:  data.PublicKey,
@@ -720,6 +819,12 @@ func (p *Profile) writeConfWgQuick(data *WgConf) (pth string, err error) {
 		templData.DnsServers = strings.Join(data.DnsServers, ",")
 	}
 
+	return
+}
+
+func (p *Profile) writeConfWgQuick(data *WgConf) (pth string, err error) {
+	templData := p.wgConfData(data)
+
 	output := &bytes.Buffer{}
 	err = WgConfTempl.Execute(output, templData)
 	if err != nil {
@@ -787,10 +892,10 @@ func (p *Profile) writeWgConf(data *WgConf) (pth string, err error) {
 
 func (p *Profile) update() {
 	evt := event.Event{
-		Type: "update",
-		Data: p,
+		Type:      "update",
+		ProfileId: p.Id,
 	}
-	evt.Init()
+	evt.Init(p)
 
 	status := GetStatus()
 
@@ -798,12 +903,12 @@ func (p *Profile) update() {
 		evt := event.Event{
 			Type: "connected",
 		}
-		evt.Init()
+		evt.Init(nil)
 	} else {
 		evt := event.Event{
 			Type: "disconnected",
 		}
-		evt.Init()
+		evt.Init(nil)
 	}
 }
 
@@ -824,13 +929,13 @@ func (p *Profile) pushOutput(output string) {
 	// TODO classic client
 	if p.SystemProfile == nil {
 		evt := &event.Event{
-			Type: "output",
-			Data: &OutputData{
-				Id:     p.Id,
-				Output: output,
-			},
+			Type:      "output",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(&OutputData{
+			Id:     p.Id,
+			Output: output,
+		})
 	}
 
 	err := log.ProfilePushLog(p.Id, output+"/n")
@@ -846,6 +951,9 @@ func (p *Profile) pushOutput(output string) {
 
 func (p *Profile) parseLine(line string) {
 	p.pushOutput(line)
+	p.parseOvpnDns(line)
+	p.parseFailure(line)
+	p.parseCipher(line)
 
 	if strings.Contains(line, "Initialization Sequence Completed") {
 		if p.stop {
@@ -857,6 +965,18 @@ func (p *Profile) parseLine(line string) {
 		p.Status = "connected"
 		p.Timestamp = time.Now().Unix() - 5
 		p.update()
+		Registry.Update(p)
+		p.addCustomRoutes()
+		p.addLocalRoutes()
+		p.storeOtpCache()
+		p.enableDohBackground()
+		p.applySearchDomainsBackground()
+		p.checkDnsLeakBackground()
+		p.allowKillSwitch()
+		p.watchBytesBackground()
+		p.probeMtuBackground()
+		p.runHookBackground(HookPostConnect)
+		p.sendWebhook(webhook.Connect, "success", "")
 
 		tokn := p.token
 		if tokn != nil {
@@ -879,18 +999,18 @@ func (p *Profile) parseLine(line string) {
 		}()
 	} else if strings.Contains(line, "Inactivity timeout (--inactive)") {
 		evt := event.Event{
-			Type: "inactive",
-			Data: p,
+			Type:      "inactive",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
 	} else if strings.Contains(line, "Inactivity timeout") ||
 		strings.Contains(line, "Connection reset") {
 
 		evt := event.Event{
-			Type: "timeout_error",
-			Data: p,
+			Type:      "timeout_error",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
 	} else if strings.Contains(
 		line, "Can't assign requested address (code=49)") {
 
@@ -917,6 +1037,7 @@ func (p *Profile) parseLine(line string) {
 
 		p.stop = true
 		p.authFailed = true
+		p.FailureReason = FailureAuth
 
 		tokn := p.token
 		if tokn != nil {
@@ -932,10 +1053,12 @@ func (p *Profile) parseLine(line string) {
 			}
 
 			evt := event.Event{
-				Type: "auth_error",
-				Data: p,
+				Type:      "auth_error",
+				ProfileId: p.Id,
 			}
-			evt.Init()
+			evt.Init(p)
+			p.sendWebhook(webhook.AuthFailure, "failure", "")
+			OtpCacheClear(p.Id)
 
 			if p.SystemProfile != nil {
 				logrus.WithFields(logrus.Fields{
@@ -944,12 +1067,14 @@ func (p *Profile) parseLine(line string) {
 					"profile due to authentication errors")
 
 				p.SystemProfile.State = false
+				DisableOnDemand(p.SystemProfile.Id)
 				sprofile.Deactivate(p.SystemProfile.Id)
 				sprofile.SetAuthErrorCount(
 					p.SystemProfile.Id,
 					0,
 				)
 			} else {
+				sprofile.ClearUserState(p.Id)
 				time.Sleep(3 * time.Second)
 			}
 		}
@@ -1094,6 +1219,13 @@ func (p *Profile) clearWgWin() {
 }
 
 func (p *Profile) clearWg() {
+	p.stopDoh()
+	p.clearSearchDomains()
+	p.clearLocalRoutes()
+	p.clearCustomRoutes()
+	p.clearExcludeRoutes()
+	p.clearIpv6Block()
+
 	switch runtime.GOOS {
 	case "linux":
 		p.clearWgLinux()
@@ -1110,6 +1242,10 @@ func (p *Profile) clearWg() {
 }
 
 func (p *Profile) clearOvpn() {
+	p.stopDoh()
+	p.clearSearchDomains()
+	p.clearLocalRoutes()
+
 	if p.cmd != nil && p.cmd.Process != nil {
 		_ = p.cmd.Process.Kill()
 		_ = p.cmd.Process.Kill()
@@ -1121,25 +1257,59 @@ func (p *Profile) clearOvpn() {
 
 func (p *Profile) Copy() (prfl *Profile) {
 	prfl = &Profile{
-		Id:                 p.Id,
-		Mode:               p.Mode,
-		OrgId:              p.OrgId,
-		UserId:             p.UserId,
-		ServerId:           p.ServerId,
-		SyncHosts:          p.SyncHosts,
-		SyncToken:          p.SyncToken,
-		SyncSecret:         p.SyncSecret,
-		Data:               p.Data,
-		Username:           p.Username,
-		Password:           p.Password,
-		DynamicFirewall:    p.DynamicFirewall,
-		DisableGateway:     p.DisableGateway,
-		SsoAuth:            p.SsoAuth,
-		ServerPublicKey:    p.ServerPublicKey,
-		ServerBoxPublicKey: p.ServerBoxPublicKey,
-		Reconnect:          p.Reconnect,
-		SystemProfile:      p.SystemProfile,
-		connected:          p.connected,
+		Id:                   p.Id,
+		Mode:                 p.Mode,
+		OrgId:                p.OrgId,
+		UserId:               p.UserId,
+		ServerId:             p.ServerId,
+		SyncHosts:            p.SyncHosts,
+		SyncToken:            p.SyncToken,
+		SyncSecret:           p.SyncSecret,
+		Data:                 p.Data,
+		Username:             p.Username,
+		Password:             p.Password,
+		DynamicFirewall:      p.DynamicFirewall,
+		DisableGateway:       p.DisableGateway,
+		ExcludeRoutes:        p.ExcludeRoutes,
+		CustomRoutes:         p.CustomRoutes,
+		PreConnectCmd:        p.PreConnectCmd,
+		PostConnectCmd:       p.PostConnectCmd,
+		PreDisconnectCmd:     p.PreDisconnectCmd,
+		PostDisconnectCmd:    p.PostDisconnectCmd,
+		SsoAuth:              p.SsoAuth,
+		ServerPublicKey:      p.ServerPublicKey,
+		ServerBoxPublicKey:   p.ServerBoxPublicKey,
+		OtpCacheTtl:          p.OtpCacheTtl,
+		Reconnect:            p.Reconnect,
+		ReconnectAttempt:     p.ReconnectAttempt,
+		ReconnectMaxAttempts: p.ReconnectMaxAttempts,
+		ForceDns:             p.ForceDns,
+		KillSwitch:           p.KillSwitch,
+		BytesInterval:        p.BytesInterval,
+		BlockIpv6:            p.BlockIpv6,
+		ConnectTimeout:       p.ConnectTimeout,
+		ProxyType:            p.ProxyType,
+		ProxyHost:            p.ProxyHost,
+		ProxyPort:            p.ProxyPort,
+		ProxyUser:            p.ProxyUser,
+		ProxyPass:            p.ProxyPass,
+		AutoMtu:              p.AutoMtu,
+		DnsOverHttps:         p.DnsOverHttps,
+		DnsOverHttpsUpstream: p.DnsOverHttpsUpstream,
+		DisconnectOnSleep:    p.DisconnectOnSleep,
+		Pkcs11Provider:       p.Pkcs11Provider,
+		Pkcs11Id:             p.Pkcs11Id,
+		Pkcs11Pin:            p.Pkcs11Pin,
+		WgPorts:              p.WgPorts,
+		RemotePorts:          p.RemotePorts,
+		AllowLocalNetwork:    p.AllowLocalNetwork,
+		SearchDomains:        p.SearchDomains,
+		SplitDns:             p.SplitDns,
+		TlsVersionMin:        p.TlsVersionMin,
+		DataCiphers:          p.DataCiphers,
+		TlsCipher:            p.TlsCipher,
+		SystemProfile:        p.SystemProfile,
+		connected:            p.connected,
 	}
 	prfl.Init()
 
@@ -1168,6 +1338,8 @@ func (p *Profile) Start(timeout bool, delay bool) (err error) {
 	p.startTime = start
 	p.remPaths = []string{}
 
+	p.loadOtpCache()
+
 	p.Status = "connecting"
 	stateLock.Lock()
 	p.state = true
@@ -1188,6 +1360,7 @@ func (p *Profile) Start(timeout bool, delay bool) (err error) {
 		"disable_gateway":  p.DisableGateway,
 		"sso_auth":         p.SsoAuth,
 		"reconnect":        p.Reconnect,
+		"kill_switch":      p.KillSwitch,
 	}).Info("profile: Connecting")
 
 	if runtime.GOOS == "darwin" && n == 0 {
@@ -1199,6 +1372,15 @@ func (p *Profile) Start(timeout bool, delay bool) (err error) {
 	Profiles.m[p.Id] = p
 	Profiles.Unlock()
 
+	conn := Registry.Register(p.Id, p.Mode)
+	if conn.Slot > 0 {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"slot":       conn.Slot,
+			"metric":     conn.Metric,
+		}).Info("profile: Connecting alongside active connections")
+	}
+
 	if prfl != nil {
 		prfl.Stop()
 	}
@@ -1217,6 +1399,19 @@ func (p *Profile) Start(timeout bool, delay bool) (err error) {
 		}
 	}
 
+	p.snapshotNetwork()
+	p.loadCustomGateway()
+	p.loadLocalGateway()
+	p.runHook(HookPreConnect)
+
+	err = p.enableKillSwitch()
+	if err != nil {
+		p.stopSafe()
+		return
+	}
+
+	p.addIpv6Block()
+
 	if delay {
 		time.Sleep(3 * time.Second)
 		if p.stop {
@@ -1244,6 +1439,27 @@ func (p *Profile) Start(timeout bool, delay bool) (err error) {
 func (p *Profile) startOvpn(timeout bool) (err error) {
 	var data *OvpnData
 
+	err = ValidateConfig([]byte(p.Data))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Invalid profile configuration")
+		return
+	}
+
+	if p.pkcs11Enabled() {
+		err = p.validatePkcs11()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"provider":   p.Pkcs11Provider,
+				"error":      err,
+			}).Error("profile: Invalid PKCS#11 configuration")
+			return
+		}
+	}
+
 	if p.DynamicFirewall || p.SsoAuth {
 		data, err = p.openOvpn()
 		if err != nil {
@@ -1261,10 +1477,12 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 			}).Error("profile: Failed to authenticate ovpn")
 
 			evt := event.Event{
-				Type: "auth_error",
-				Data: p,
+				Type:      "auth_error",
+				ProfileId: p.Id,
 			}
-			evt.Init()
+			evt.Init(p)
+			p.sendWebhook(webhook.AuthFailure, "failure", "")
+			OtpCacheClear(p.Id)
 
 			p.stopSafe()
 			return
@@ -1339,15 +1557,27 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 	}
 
 	if runtime.GOOS == "windows" {
-		p.tap = tuntap.Acquire()
-
-		if p.tap != "" {
-			args = append(args, "--dev-node", p.tap)
-		} else {
+		p.tap, err = tuntap.Acquire()
+		if err != nil {
 			logrus.WithFields(logrus.Fields{
-				"tap_size": tuntap.Size(),
+				"profile_id": p.Id,
+				"tap_size":   tuntap.Size(),
+				"error":      err,
 			}).Error("profile: Failed to acquire tap")
+
+			evt := event.Event{
+				Type:      "configuration_error",
+				ProfileId: p.Id,
+			}
+			evt.Init(&ConfigErrorData{
+				Id:    p.Id,
+				Error: err.Error(),
+			})
+
+			return
 		}
+
+		args = append(args, "--dev-node", p.tap)
 	}
 
 	if p.stop {
@@ -1427,6 +1657,9 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 		args = append(args, "--auth-user-pass", authPath)
 	}
 
+	args = append(args, p.cipherArgs()...)
+	args = append(args, p.pkcs11Args()...)
+
 	if p.stop {
 		p.stopSafe()
 		return
@@ -1578,6 +1811,8 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 		return
 	}
 
+	p.pkcs11PinBackground()
+
 	running := true
 	go func() {
 		defer func() {
@@ -1626,7 +1861,7 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 		}
 	}()
 
-	if timeout {
+	if timeout || p.ConnectTimeout > 0 {
 		go func() {
 			defer func() {
 				panc := recover()
@@ -1639,8 +1874,20 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 				}
 			}()
 
-			time.Sleep(connTimeout)
-			if p.Status != "connected" && running {
+			time.Sleep(p.connectTimeout())
+			if p.Status != "connected" && running && !p.stop {
+				p.FailureReason = p.failureReason
+				if p.FailureReason == "" {
+					p.FailureReason = FailureUnknown
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"profile_id": p.Id,
+					"reason":     p.FailureReason,
+				}).Error("profile: Connection timed out")
+
+				p.stop = true
+
 				if runtime.GOOS == "windows" {
 					_ = cmd.Process.Kill()
 				} else {
@@ -1679,10 +1926,10 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 				}
 
 				evt := event.Event{
-					Type: "timeout_error",
-					Data: p,
+					Type:      "timeout_error",
+					ProfileId: p.Id,
 				}
-				evt.Init()
+				evt.Init(p)
 			}
 		}()
 	}
@@ -1807,10 +2054,10 @@ func (p *Profile) openOvpn() (data *OvpnData, err error) {
 		err = nil
 
 		evt := event.Event{
-			Type: "connection_error",
-			Data: p,
+			Type:      "connection_error",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
 
 		time.Sleep(3 * time.Second)
 
@@ -2096,10 +2343,10 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 	if res.StatusCode == 428 && ssoToken != "" {
 		if time.Since(ssoStart) > 120*time.Second {
 			evt := event.Event{
-				Type: "timeout_error",
-				Data: p,
+				Type:      "timeout_error",
+				ProfileId: p.Id,
 			}
-			evt.Init()
+			evt.Init(p)
 
 			err = &errortypes.RequestError{
 				errors.Wrap(err, "profile: Single sign-on timeout"),
@@ -2118,10 +2365,10 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 
 	if res.StatusCode == 429 {
 		evt := event.Event{
-			Type: "offline_error",
-			Data: p,
+			Type:      "offline_error",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
 
 		err = &errortypes.RequestError{
 			errors.Wrap(err, "profile: Server is offline"),
@@ -2148,13 +2395,13 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 
 	if ovpnResp.SsoUrl != "" && ovpnResp.SsoToken != "" && ssoToken == "" {
 		evt := event.Event{
-			Type: "sso_auth",
-			Data: &SsoEventData{
-				Id:  p.Id,
-				Url: ovpnResp.SsoUrl,
-			},
+			Type:      "sso_auth",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(&SsoEventData{
+			Id:  p.Id,
+			Url: ovpnResp.SsoUrl,
+		})
 
 		p.Status = "authenticating"
 		p.update()
@@ -2503,10 +2750,10 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 	if res.StatusCode == 428 && ssoToken != "" {
 		if time.Since(ssoStart) > 60*time.Second {
 			evt := event.Event{
-				Type: "timeout_error",
-				Data: p,
+				Type:      "timeout_error",
+				ProfileId: p.Id,
 			}
-			evt.Init()
+			evt.Init(p)
 
 			err = &errortypes.RequestError{
 				errors.Wrap(err, "profile: Single sign-on timeout"),
@@ -2525,10 +2772,10 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 
 	if res.StatusCode == 429 {
 		evt := event.Event{
-			Type: "offline_error",
-			Data: p,
+			Type:      "offline_error",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
 
 		err = &errortypes.RequestError{
 			errors.Wrap(err, "profile: Server is offline"),
@@ -2555,13 +2802,13 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 
 	if wgResp.SsoUrl != "" && wgResp.SsoToken != "" && ssoToken == "" {
 		evt := event.Event{
-			Type: "sso_auth",
-			Data: &SsoEventData{
-				Id:  p.Id,
-				Url: wgResp.SsoUrl,
-			},
+			Type:      "sso_auth",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(&SsoEventData{
+			Id:  p.Id,
+			Url: wgResp.SsoUrl,
+		})
 
 		p.Status = "authenticating"
 		p.update()
@@ -2982,7 +3229,7 @@ func (p *Profile) confWgLinux(data *WgConf) (err error) {
 		}
 	}
 
-	_, err = utils.ExecCombinedOutputLogged(nil,
+	_, err = utils.ExecCmdCombinedOutputLogged(nil, command.New(
 		p.wgPath,
 		"set", p.Iface,
 		"private-key", p.wgConfPth,
@@ -2990,7 +3237,7 @@ func (p *Profile) confWgLinux(data *WgConf) (err error) {
 		"persistent-keepalive", "10",
 		"allowed-ips", strings.Join(allowedIps, ","),
 		"endpoint", fmt.Sprintf("%s:%d", data.Hostname, data.Port),
-	)
+	).Sensitive(3))
 	if err != nil {
 		return
 	}
@@ -3082,11 +3329,10 @@ func (p *Profile) confWgLinux(data *WgConf) (err error) {
 	return
 }
 
-func (p *Profile) sendManagementCommand(cmd string) (err error) {
-	p.managementLock.Lock()
-	defer p.managementLock.Unlock()
+func (p *Profile) openManagement() (conn net.Conn,
+	reader *bufio.Reader, err error) {
 
-	conn, err := net.DialTimeout(
+	conn, err = net.DialTimeout(
 		"tcp",
 		fmt.Sprintf("127.0.0.1:%d", p.managementPort),
 		3*time.Second,
@@ -3097,35 +3343,70 @@ func (p *Profile) sendManagementCommand(cmd string) (err error) {
 		}
 		return
 	}
-	defer conn.Close()
-
-	go func() {
-		for {
-			buf := make([]byte, 10000)
-			n, e := conn.Read(buf)
-			if e != nil || n == 0 {
-				break
-			}
-		}
-	}()
 
 	err = conn.SetDeadline(time.Now().Add(3 * time.Second))
 	if err != nil {
+		conn.Close()
 		err = &errortypes.ReadError{
 			errors.Wrap(err, "profile: Failed set deadline"),
 		}
 		return
 	}
 
+	reader = bufio.NewReader(conn)
+
+	_, err = readManagement(reader, managementPassPrompt)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
 	_, err = conn.Write([]byte(fmt.Sprintf("%s\n", p.managementPass)))
 	if err != nil {
+		conn.Close()
 		err = &errortypes.ReadError{
 			errors.Wrap(err, "profile: Failed to write socket password"),
 		}
 		return
 	}
 
-	time.Sleep(500 * time.Millisecond)
+	match, err := readManagement(reader,
+		managementPassSuccess, managementPassFailed)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	if match != managementPassSuccess {
+		conn.Close()
+		err = &errortypes.ReadError{
+			errors.New("profile: Management password rejected"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Profile) sendManagementCommand(cmd string) (err error) {
+	p.managementLock.Lock()
+	defer p.managementLock.Unlock()
+
+	conn, reader, err := p.openManagement()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	go func() {
+		for {
+			buf := make([]byte, 10000)
+			n, e := reader.Read(buf)
+			if e != nil || n == 0 {
+				break
+			}
+		}
+	}()
 
 	_, err = conn.Write([]byte(fmt.Sprintf("%s\n", cmd)))
 	if err != nil {
@@ -3266,7 +3547,45 @@ func (p *Profile) confWgWin() (err error) {
 	return
 }
 
+func (p *Profile) filterWgConf(data *WgConf) {
+	if p.DisableGateway {
+		routes := []*Route{}
+		for _, route := range data.Routes {
+			if route.Network == "0.0.0.0/0" {
+				continue
+			}
+			routes = append(routes, route)
+		}
+		data.Routes = routes
+
+		routes6 := []*Route{}
+		for _, route := range data.Routes6 {
+			if route.Network == "::/0" {
+				continue
+			}
+			routes6 = append(routes6, route)
+		}
+		data.Routes6 = routes6
+	}
+
+	if p.BlockIpv6 {
+		data.Address6 = ""
+		data.Gateway6 = ""
+		data.Routes6 = []*Route{}
+
+		dnsServers := []string{}
+		for _, dnsServer := range data.DnsServers {
+			if strings.Contains(dnsServer, ":") {
+				continue
+			}
+			dnsServers = append(dnsServers, dnsServer)
+		}
+		data.DnsServers = dnsServers
+	}
+}
+
 func (p *Profile) confWg(data *WgConf) (err error) {
+	p.wgConf = data
 	p.ClientAddr = data.Address
 	p.ServerAddr = data.Hostname
 	p.GatewayAddr = data.Gateway
@@ -3303,7 +3622,7 @@ func (p *Profile) updateWgHandshake() (err error) {
 		iface = p.Iface
 	}
 
-	output, err := utils.ExecCombinedOutputLogged(
+	output, err := wgExec(
 		[]string{
 			"No such device",
 			"access interface",
@@ -3352,57 +3671,51 @@ func (p *Profile) watchWg() {
 
 	time.Sleep(1 * time.Second)
 
-	for i := 0; i < 30; i++ {
-		if p.stop {
-			p.stopSafe()
-			return
-		}
-
-		if i%10 == 0 {
-			go p.pingWg()
-		}
-
-		err := p.updateWgHandshake()
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("profile: Check handshake status failed")
-			p.stopSafe()
-			return
-		}
-
-		if p.stop {
-			p.stopSafe()
-			return
-		}
-
-		if p.wgHandshake != 0 {
-			p.connected = true
-			p.Status = "connected"
-			p.Timestamp = time.Now().Unix() - 5
-			p.update()
-			break
-		}
+	err := p.waitWgConnect()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Check handshake status failed")
+		p.stopSafe()
+		return
+	}
 
-		time.Sleep(500 * time.Millisecond)
+	if p.stop {
+		p.stopSafe()
+		return
 	}
 
 	if p.wgHandshake == 0 {
-		if p.stop {
-			p.stopSafe()
-			return
-		}
+		p.FailureReason = FailureUnreachable
 
 		evt := event.Event{
-			Type: "handshake_timeout",
-			Data: p,
+			Type:      "handshake_timeout",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
 
 		p.restartSafe()
 		return
 	}
 
+	p.storeWgPort()
+	p.connected = true
+	p.Status = "connected"
+	p.Timestamp = time.Now().Unix() - 5
+	p.update()
+	Registry.Update(p)
+	p.addCustomRoutes()
+	p.addLocalRoutes()
+	p.storeOtpCache()
+	p.enableDohBackground()
+	p.applySearchDomainsBackground()
+	p.checkDnsLeakBackground()
+	p.allowKillSwitch()
+	p.watchBytesBackground()
+	p.probeMtuBackground()
+	p.runHookBackground(HookPostConnect)
+	p.sendWebhook(webhook.Connect, "success", "")
+
 	for {
 		for i := 0; i < 10; i++ {
 			if p.stop {
@@ -3443,10 +3756,28 @@ func (p *Profile) watchWg() {
 			p.restartSafe()
 			return
 		}
+
+		if data.Configuration != nil {
+			err = p.ReloadWg(data.Configuration)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": p.Id,
+					"error":      err,
+				}).Error("profile: Failed to reload wg configuration")
+
+				p.restartSafe()
+				return
+			}
+		}
 	}
 }
 
 func (p *Profile) startWg(timeout bool) (err error) {
+	err = p.validateProxy()
+	if err != nil {
+		return
+	}
+
 	err = p.generateWgKey()
 	if err != nil {
 		return
@@ -3571,10 +3902,10 @@ func (p *Profile) startWg(timeout bool) (err error) {
 	}
 	if err != nil {
 		evt := event.Event{
-			Type: "connection_error",
-			Data: p,
+			Type:      "connection_error",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
 
 		logrus.WithFields(logrus.Fields{
 			"error": err,
@@ -3614,10 +3945,12 @@ func (p *Profile) startWg(timeout bool) (err error) {
 		}).Error("profile: Failed to authenticate wg")
 
 		evt := event.Event{
-			Type: "auth_error",
-			Data: p,
+			Type:      "auth_error",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(p)
+		p.sendWebhook(webhook.AuthFailure, "failure", "")
+		OtpCacheClear(p.Id)
 
 		if p.SystemProfile != nil {
 			logrus.WithFields(logrus.Fields{
@@ -3626,11 +3959,14 @@ func (p *Profile) startWg(timeout bool) (err error) {
 				"profile due to authentication errors")
 
 			p.SystemProfile.State = false
+			DisableOnDemand(p.SystemProfile.Id)
 			sprofile.Deactivate(p.SystemProfile.Id)
 			sprofile.SetAuthErrorCount(
 				p.SystemProfile.Id,
 				0,
 			)
+		} else {
+			sprofile.ClearUserState(p.Id)
 		}
 
 		time.Sleep(3 * time.Second)
@@ -3658,25 +3994,9 @@ func (p *Profile) startWg(timeout bool) (err error) {
 	}
 	p.Iface = iface
 
-	if p.DisableGateway {
-		routes := []*Route{}
-		for _, route := range data.Configuration.Routes {
-			if route.Network == "0.0.0.0/0" {
-				continue
-			}
-			routes = append(routes, route)
-		}
-		data.Configuration.Routes = routes
-
-		routes6 := []*Route{}
-		for _, route := range data.Configuration.Routes6 {
-			if route.Network == "::/0" {
-				continue
-			}
-			routes6 = append(routes6, route)
-		}
-		data.Configuration.Routes6 = routes6
-	}
+	p.filterWgConf(data.Configuration)
+	p.loadWgPorts(data.Configuration)
+	p.loadExcludeGateway()
 
 	wgConfPth, err := p.writeWgConf(data.Configuration)
 	if err != nil {
@@ -3688,10 +4008,13 @@ func (p *Profile) startWg(timeout bool) (err error) {
 	err = p.confWg(data.Configuration)
 	if err != nil {
 		evt := event.Event{
-			Type: "configuration_error",
-			Data: p,
+			Type:      "configuration_error",
+			ProfileId: p.Id,
 		}
-		evt.Init()
+		evt.Init(&ConfigErrorData{
+			Id:    p.Id,
+			Error: err.Error(),
+		})
 
 		logrus.WithFields(logrus.Fields{
 			"error": err,
@@ -3701,6 +4024,8 @@ func (p *Profile) startWg(timeout bool) (err error) {
 		return
 	}
 
+	p.addExcludeRoutes()
+
 	tokn := p.token
 	if tokn != nil {
 		tokn.Valid = true
@@ -3873,27 +4198,62 @@ func (p *Profile) Restart() {
 		return
 	}
 	p.stopping = true
+	p.reconnectCancel = make(chan bool)
+	attempt := p.nextReconnectAttempt()
+	reason := p.reconnectReason
+	p.reconnectReason = ""
 	prflCopy := p.Copy()
 	stateLock.Unlock()
 
-	logrus.WithFields(logrus.Fields{
-		"profile_id": p.Id,
-	}).Info("profile: Reconnecting")
+	canceled := false
+	delay := reconnectDelay(attempt)
+	if reason == ReconnectNetworkChange || reason == ReconnectResume {
+		delay = 0
+	}
 
-	p.Status = "reconnecting"
-	p.update()
+	if p.ReconnectMaxAttempts > 0 && attempt > p.ReconnectMaxAttempts {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"attempts":   p.ReconnectMaxAttempts,
+		}).Error("profile: Reconnect attempts exceeded")
+
+		canceled = true
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"attempt":    attempt,
+			"delay":      delay.String(),
+			"reason":     reason,
+		}).Info("profile: Reconnecting")
+
+		p.Status = "reconnecting"
+		p.update()
+
+		evt := event.Event{
+			Type:      "reconnecting",
+			ProfileId: p.Id,
+		}
+		evt.Init(&ReconnectData{
+			Id:          p.Id,
+			Attempt:     attempt,
+			MaxAttempts: p.ReconnectMaxAttempts,
+			Delay:       delay.Milliseconds(),
+			Reason:      reason,
+		})
+		p.sendWebhook(webhook.Reconnect, "pending", reason)
+	}
 
 	cancel := p.openReqCancel
 	if cancel != nil {
 		cancel()
 	}
 
-	diff := utils.SinceAbs(p.startTime)
-	if diff < 6*time.Second {
-		delay := 5 * time.Second
-		time.Sleep(delay)
+	if !canceled {
+		canceled = p.waitReconnect(delay)
 	}
 
+	p.runHook(HookPreDisconnect)
+
 	if p.Mode == Wg {
 		err = p.stopWg()
 	} else {
@@ -3916,6 +4276,9 @@ func (p *Profile) Restart() {
 
 	p.clearWg()
 	p.clearOvpn()
+	p.verifyNetwork()
+	p.recordSession()
+	p.runHookBackground(HookPostDisconnect)
 
 	for _, path := range p.remPaths {
 		os.Remove(path)
@@ -3925,6 +4288,7 @@ func (p *Profile) Restart() {
 	prfl := Profiles.m[p.Id]
 	if prfl == p {
 		delete(Profiles.m, p.Id)
+		Registry.Unregister(p.Id)
 	}
 	if runtime.GOOS == "darwin" && len(Profiles.m) == 0 {
 		err := utils.ClearScutilKeys()
@@ -3948,6 +4312,16 @@ func (p *Profile) Restart() {
 	p.waiters = []chan bool{}
 	stateLock.Unlock()
 
+	if canceled {
+		p.disableKillSwitch()
+		p.Status = "disconnected"
+		p.Timestamp = 0
+		p.ClientAddr = ""
+		p.ServerAddr = ""
+		p.update()
+		return
+	}
+
 	err = prflCopy.Start(false, false)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -3989,6 +4363,7 @@ func (p *Profile) Stop() {
 
 	stateLock.Lock()
 	if p.stopping {
+		p.cancelReconnect()
 		stateLock.Unlock()
 		p.Wait()
 		return
@@ -4014,6 +4389,8 @@ func (p *Profile) Stop() {
 		time.Sleep(1 * time.Second)
 	}
 
+	p.runHook(HookPreDisconnect)
+
 	if p.Mode == Wg {
 		err = p.stopWg()
 	} else {
@@ -4036,6 +4413,11 @@ func (p *Profile) Stop() {
 
 	p.clearWg()
 	p.clearOvpn()
+	p.verifyNetwork()
+	p.disableKillSwitch()
+	p.recordSession()
+	p.runHookBackground(HookPostDisconnect)
+	p.sendWebhook(webhook.Disconnect, "success", "")
 
 	p.Status = "disconnected"
 	p.Timestamp = 0
@@ -4051,6 +4433,7 @@ func (p *Profile) Stop() {
 	prfl := Profiles.m[p.Id]
 	if prfl == p {
 		delete(Profiles.m, p.Id)
+		Registry.Unregister(p.Id)
 	}
 	if runtime.GOOS == "darwin" && len(Profiles.m) == 0 {
 		err := utils.ClearScutilKeys()
@@ -0,0 +1,115 @@
+package profile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	ProxyHttp   = "http"
+	ProxySocks5 = "socks5"
+)
+
+func (p *Profile) validateProxy() (err error) {
+	if p.ProxyType == "" {
+		return
+	}
+
+	if p.Mode == Wg {
+		err = &errortypes.ParseError{
+			errors.New("profile: Proxy not supported with WireGuard"),
+		}
+		return
+	}
+
+	if p.ProxyType != ProxyHttp && p.ProxyType != ProxySocks5 {
+		err = &errortypes.ParseError{
+			errors.Newf("profile: Unknown proxy type '%s'", p.ProxyType),
+		}
+		return
+	}
+
+	if p.ProxyHost == "" || p.ProxyPort < 1 || p.ProxyPort > 65535 {
+		err = &errortypes.ParseError{
+			errors.New("profile: Invalid proxy host or port"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Profile) writeProxyAuth() (pth string, err error) {
+	rootDir, err := utils.GetTempDir()
+	if err != nil {
+		return
+	}
+
+	if runtime.GOOS == "windows" {
+		pth = filepath.Join(rootDir, p.Id+"-proxy.txt")
+	} else {
+		pth = filepath.Join(rootDir, p.Id+"-proxy")
+	}
+
+	_ = os.Remove(pth)
+	err = ioutil.WriteFile(
+		pth,
+		[]byte(fmt.Sprintf("%s\n%s\n", p.ProxyUser, p.ProxyPass)),
+		os.FileMode(0600),
+	)
+	if err != nil {
+		err = &WriteError{
+			errors.Wrap(err, "profile: Failed to write proxy auth"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Profile) proxyOvpn() (output string, err error) {
+	if p.ProxyType == "" {
+		return
+	}
+
+	err = p.validateProxy()
+	if err != nil {
+		return
+	}
+
+	authPath := ""
+	if p.ProxyUser != "" {
+		authPath, err = p.writeProxyAuth()
+		if err != nil {
+			return
+		}
+		p.remPaths = append(p.remPaths, authPath)
+		authPath = strings.ReplaceAll(authPath, "\\", "\\\\")
+	}
+
+	switch p.ProxyType {
+	case ProxyHttp:
+		output = fmt.Sprintf("http-proxy %s %d", p.ProxyHost, p.ProxyPort)
+		if authPath != "" {
+			output += fmt.Sprintf(" %s basic", authPath)
+		}
+		break
+	case ProxySocks5:
+		output = fmt.Sprintf("socks-proxy %s %d", p.ProxyHost, p.ProxyPort)
+		if authPath != "" {
+			output += fmt.Sprintf(" %s", authPath)
+		}
+		break
+	}
+	output += "\n"
+
+	return
+}
@@ -0,0 +1,113 @@
+package profile
+
+import (
+	mathrand "math/rand"
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	reconnectBaseDelay  = 1 * time.Second
+	reconnectMaxDelay   = 60 * time.Second
+	reconnectStableTime = 30 * time.Second
+)
+
+const (
+	ReconnectNetworkChange = "network_change"
+	ReconnectResume        = "resume"
+)
+
+type ReconnectData struct {
+	Id          string `json:"id"`
+	Attempt     int    `json:"attempt"`
+	MaxAttempts int    `json:"max_attempts"`
+	Delay       int64  `json:"delay"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+func reconnectDelay(attempt int) (delay time.Duration) {
+	delay = reconnectMaxDelay
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	if attempt <= 7 {
+		delay = reconnectBaseDelay << uint(attempt-1)
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+
+	delay = delay/2 + time.Duration(mathrand.Int63n(int64(delay/2)+1))
+
+	return
+}
+
+func (p *Profile) nextReconnectAttempt() int {
+	if p.connected && p.Timestamp != 0 &&
+		time.Since(time.Unix(p.Timestamp, 0)) > reconnectStableTime {
+
+		p.ReconnectAttempt = 0
+	}
+
+	p.ReconnectAttempt += 1
+
+	return p.ReconnectAttempt
+}
+
+func (p *Profile) cancelReconnect() {
+	if p.reconnectCancel != nil && !p.reconnectCanceled {
+		p.reconnectCanceled = true
+		close(p.reconnectCancel)
+	}
+}
+
+func (p *Profile) waitReconnect(delay time.Duration) (canceled bool) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-p.reconnectCancel:
+		canceled = true
+	}
+
+	return
+}
+
+func (p *Profile) RestartReason(reason string) {
+	stateLock.Lock()
+	if p.stop || p.stopping || !p.connected {
+		stateLock.Unlock()
+		return
+	}
+	p.reconnectReason = reason
+	stateLock.Unlock()
+
+	p.Restart()
+}
+
+func RestartConnected(reason string) {
+	for _, prfl := range GetProfiles() {
+		if !prfl.Reconnect {
+			continue
+		}
+
+		go func(prfl *Profile) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"stack": string(debug.Stack()),
+						"panic": panc,
+					}).Error("profile: Panic")
+					panic(panc)
+				}
+			}()
+
+			prfl.RestartReason(reason)
+		}(prfl)
+	}
+}
@@ -0,0 +1,256 @@
+package profile
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	registryMetricBase = 10
+	registryMetricStep = 10
+)
+
+type Connection struct {
+	ProfileId      string       `json:"profile_id"`
+	Mode           string       `json:"mode"`
+	Iface          string       `json:"iface"`
+	Slot           int          `json:"slot"`
+	Metric         int          `json:"metric"`
+	ManagementPort int          `json:"management_port"`
+	Routes         []string     `json:"routes"`
+	DnsServers     []string     `json:"dns_servers"`
+	Snapshot       *NetSnapshot `json:"snapshot"`
+}
+
+func (c *Connection) Copy() (conn *Connection) {
+	conn = &Connection{
+		ProfileId:      c.ProfileId,
+		Mode:           c.Mode,
+		Iface:          c.Iface,
+		Slot:           c.Slot,
+		Metric:         c.Metric,
+		ManagementPort: c.ManagementPort,
+		Routes:         append([]string{}, c.Routes...),
+		DnsServers:     append([]string{}, c.DnsServers...),
+		Snapshot:       c.Snapshot,
+	}
+
+	return
+}
+
+type ConnectionRegistry struct {
+	lock  sync.Mutex
+	conns map[string]*Connection
+}
+
+var Registry = &ConnectionRegistry{
+	conns: map[string]*Connection{},
+}
+
+func (r *ConnectionRegistry) Register(prflId, mode string) (
+	conn *Connection) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn = r.conns[prflId]
+	if conn != nil {
+		conn = conn.Copy()
+		return
+	}
+
+	slots := map[int]bool{}
+	for _, c := range r.conns {
+		slots[c.Slot] = true
+	}
+
+	slot := 0
+	for slots[slot] {
+		slot += 1
+	}
+
+	metric := 0
+	if slot > 0 {
+		metric = registryMetricBase + slot*registryMetricStep
+	}
+
+	conn = &Connection{
+		ProfileId:  prflId,
+		Mode:       mode,
+		Slot:       slot,
+		Metric:     metric,
+		Routes:     []string{},
+		DnsServers: []string{},
+	}
+	r.conns[prflId] = conn
+
+	conn = conn.Copy()
+
+	return
+}
+
+func (r *ConnectionRegistry) Update(p *Profile) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn := r.conns[p.Id]
+	if conn == nil {
+		return
+	}
+
+	conn.Mode = p.Mode
+	conn.Iface = p.killSwitchIface()
+	conn.ManagementPort = p.managementPort
+	conn.DnsServers = append([]string{}, p.dnsServers()...)
+}
+
+func (r *ConnectionRegistry) Unregister(prflId string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn := r.conns[prflId]
+	delete(r.conns, prflId)
+
+	if conn == nil || conn.Snapshot == nil {
+		return
+	}
+
+	for _, c := range r.conns {
+		if c.Snapshot != nil &&
+			c.Snapshot.Timestamp.After(conn.Snapshot.Timestamp) {
+
+			c.Snapshot = conn.Snapshot
+		}
+	}
+}
+
+func (r *ConnectionRegistry) SetSnapshot(prflId string,
+	snapshot *NetSnapshot) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn := r.conns[prflId]
+	if conn == nil {
+		return
+	}
+
+	conn.Snapshot = snapshot
+}
+
+func (r *ConnectionRegistry) Snapshot(prflId string) (
+	snapshot *NetSnapshot, latest bool) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn := r.conns[prflId]
+	if conn == nil || conn.Snapshot == nil {
+		return
+	}
+
+	snapshot = conn.Snapshot
+	latest = true
+
+	for id, c := range r.conns {
+		if id != prflId && c.Snapshot != nil &&
+			c.Snapshot.Timestamp.After(snapshot.Timestamp) {
+
+			latest = false
+			break
+		}
+	}
+
+	return
+}
+
+func (r *ConnectionRegistry) Get(prflId string) (conn *Connection) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn = r.conns[prflId]
+	if conn != nil {
+		conn = conn.Copy()
+	}
+
+	return
+}
+
+func (r *ConnectionRegistry) All() (conns []*Connection) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conns = []*Connection{}
+	for _, conn := range r.conns {
+		conns = append(conns, conn.Copy())
+	}
+
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].Slot < conns[j].Slot
+	})
+
+	return
+}
+
+func (r *ConnectionRegistry) Metric(prflId string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn := r.conns[prflId]
+	if conn == nil {
+		return 0
+	}
+
+	return conn.Metric
+}
+
+func (r *ConnectionRegistry) AddRoute(prflId, route string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn := r.conns[prflId]
+	if conn == nil {
+		return
+	}
+
+	for _, rte := range conn.Routes {
+		if rte == route {
+			return
+		}
+	}
+
+	conn.Routes = append(conn.Routes, route)
+}
+
+func (r *ConnectionRegistry) RemoveRoute(prflId, route string) (
+	shared bool) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	conn := r.conns[prflId]
+	if conn != nil {
+		routes := []string{}
+		for _, rte := range conn.Routes {
+			if rte != route {
+				routes = append(routes, rte)
+			}
+		}
+		conn.Routes = routes
+	}
+
+	for id, c := range r.conns {
+		if id == prflId {
+			continue
+		}
+
+		for _, rte := range c.Routes {
+			if rte == route {
+				shared = true
+				return
+			}
+		}
+	}
+
+	return
+}
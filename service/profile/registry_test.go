@@ -0,0 +1,167 @@
+package profile
+
+import (
+	"net"
+	"testing"
+)
+
+type fakeRoutes struct {
+	table   map[string]bool
+	removed []string
+}
+
+func setFakeRoutes(t *testing.T) (rtes *fakeRoutes) {
+	origCustom := setCustomRoute
+	origExclude := setExcludeRoute
+	origRegistry := Registry
+
+	rtes = &fakeRoutes{
+		table: map[string]bool{},
+	}
+
+	setCustomRoute = func(p *Profile, add bool,
+		route *CustomRoute) (err error) {
+
+		key := p.customRouteKey(route)
+		if add {
+			rtes.table[key] = true
+		} else {
+			delete(rtes.table, key)
+			rtes.removed = append(rtes.removed, key)
+		}
+		return
+	}
+	setExcludeRoute = func(p *Profile, add bool, network *net.IPNet,
+		gateway string) (err error) {
+
+		key := network.String() + " via " + gateway
+		if add {
+			rtes.table[key] = true
+		} else {
+			delete(rtes.table, key)
+			rtes.removed = append(rtes.removed, key)
+		}
+		return
+	}
+	Registry = &ConnectionRegistry{
+		conns: map[string]*Connection{},
+	}
+
+	t.Cleanup(func() {
+		setCustomRoute = origCustom
+		setExcludeRoute = origExclude
+		Registry = origRegistry
+	})
+
+	return
+}
+
+func connectTest(id, iface string, custom []string,
+	exclude []string) (prfl *Profile) {
+
+	prfl = &Profile{
+		Id:             id,
+		Mode:           Ovpn,
+		ovpnIface:      iface,
+		customGateway:  "192.168.1.1",
+		excludeGateway: "192.168.1.1",
+		ExcludeRoutes:  exclude,
+	}
+	for _, network := range custom {
+		prfl.CustomRoutes = append(prfl.CustomRoutes, &CustomRoute{
+			Network: network,
+		})
+	}
+
+	Registry.Register(prfl.Id, prfl.Mode)
+	prfl.addExcludeRoutes()
+	prfl.addCustomRoutes()
+
+	return
+}
+
+func TestRegistryMetrics(t *testing.T) {
+	setFakeRoutes(t)
+
+	connA := Registry.Register("prfla", Ovpn)
+	connB := Registry.Register("prflb", Wg)
+
+	if connA.Slot != 0 || connA.Metric != 0 {
+		t.Errorf("unexpected first connection slot %d metric %d",
+			connA.Slot, connA.Metric)
+	}
+	if connB.Slot != 1 || connB.Metric != 20 {
+		t.Errorf("unexpected second connection slot %d metric %d",
+			connB.Slot, connB.Metric)
+	}
+
+	again := Registry.Register("prflb", Wg)
+	if again.Slot != connB.Slot {
+		t.Errorf("reregister changed slot to %d", again.Slot)
+	}
+
+	Registry.Unregister("prfla")
+	connC := Registry.Register("prflc", Ovpn)
+	if connC.Slot != 0 {
+		t.Errorf("released slot not reused, got %d", connC.Slot)
+	}
+
+	conns := Registry.All()
+	if len(conns) != 2 || conns[0].ProfileId != "prflc" ||
+		conns[1].ProfileId != "prflb" {
+
+		t.Errorf("unexpected connections %v", conns)
+	}
+}
+
+func TestRegistryOverlappingRouteCleanup(t *testing.T) {
+	rtes := setFakeRoutes(t)
+
+	prflA := connectTest("prfla", "tun0",
+		[]string{"172.16.0.0/16", "10.50.0.0/16"},
+		[]string{"203.0.113.0/24"})
+	prflB := connectTest("prflb", "tun1",
+		[]string{"10.50.0.0/16", "10.60.0.0/16"},
+		[]string{"203.0.113.0/24", "198.51.100.0/24"})
+
+	expected := []string{
+		"172.16.0.0/16 via 192.168.1.1",
+		"10.50.0.0/16 via 192.168.1.1",
+		"10.60.0.0/16 via 192.168.1.1",
+		"203.0.113.0/24 via 192.168.1.1",
+		"198.51.100.0/24 via 192.168.1.1",
+	}
+	for _, key := range expected {
+		if !rtes.table[key] {
+			t.Fatalf("route %s not added", key)
+		}
+	}
+
+	if prflB.customRoutes()[0].Metric != Registry.Metric(prflB.Id) {
+		t.Errorf("second tunnel routes missing registry metric")
+	}
+
+	prflA.clearCustomRoutes()
+	prflA.clearExcludeRoutes()
+	Registry.Unregister(prflA.Id)
+
+	if rtes.table["172.16.0.0/16 via 192.168.1.1"] {
+		t.Error("tunnel A route not removed")
+	}
+	for _, key := range expected[1:] {
+		if !rtes.table[key] {
+			t.Errorf("tunnel B route %s removed by tunnel A", key)
+		}
+	}
+	if len(rtes.removed) != 1 {
+		t.Errorf("unexpected removed routes %v", rtes.removed)
+	}
+
+	prflB.clearCustomRoutes()
+	prflB.clearExcludeRoutes()
+	Registry.Unregister(prflB.Id)
+
+	if len(rtes.table) != 0 {
+		t.Errorf("routes left after teardown %v", rtes.table)
+	}
+}
@@ -0,0 +1,259 @@
+package profile
+
+import (
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var setCustomRoute = (*Profile).routeCustom
+
+type CustomRoute struct {
+	Network   string `json:"network"`
+	Metric    int    `json:"metric"`
+	ViaTunnel bool   `json:"via_tunnel"`
+}
+
+func (p *Profile) customRoutes() (routes []*CustomRoute) {
+	routes = []*CustomRoute{}
+
+	existing := set.NewSet()
+	for _, route := range p.Routes {
+		_, network, err := net.ParseCIDR(route.Network)
+		if err == nil {
+			existing.Add(network.String())
+		}
+	}
+	for _, route := range p.Routes6 {
+		_, network, err := net.ParseCIDR(route.Network)
+		if err == nil {
+			existing.Add(network.String())
+		}
+	}
+	for _, network := range p.excludeNetworks() {
+		existing.Add(network.String())
+	}
+
+	for _, route := range p.CustomRoutes {
+		if route == nil {
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(strings.TrimSpace(route.Network))
+		if err != nil || (!route.ViaTunnel && network.IP.To4() == nil) {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      route.Network,
+			}).Warn("profile: Ignoring invalid custom route")
+			continue
+		}
+
+		if existing.Contains(network.String()) {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      network.String(),
+			}).Info("profile: Ignoring duplicate custom route")
+			continue
+		}
+		existing.Add(network.String())
+
+		metric := route.Metric
+		if metric == 0 {
+			metric = Registry.Metric(p.Id)
+		}
+
+		routes = append(routes, &CustomRoute{
+			Network:   network.String(),
+			Metric:    metric,
+			ViaTunnel: route.ViaTunnel,
+		})
+	}
+
+	return
+}
+
+func (p *Profile) customRouteKey(route *CustomRoute) string {
+	if route.ViaTunnel {
+		return route.Network + " dev " + p.killSwitchIface()
+	}
+	return route.Network + " via " + p.customGateway
+}
+
+func (p *Profile) loadCustomGateway() {
+	p.customGateway = ""
+
+	required := false
+	for _, route := range p.CustomRoutes {
+		if route != nil && !route.ViaTunnel {
+			required = true
+			break
+		}
+	}
+
+	if !required {
+		return
+	}
+
+	gateway, err := getDefaultGateway()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to get gateway for custom routes")
+		return
+	}
+
+	p.customGateway = gateway
+}
+
+func (p *Profile) routeCustom(add bool, route *CustomRoute) (err error) {
+	_, network, err := net.ParseCIDR(route.Network)
+	if err != nil {
+		return
+	}
+	ipv6 := network.IP.To4() == nil
+	iface := p.killSwitchIface()
+	metric := strconv.Itoa(route.Metric)
+
+	switch runtime.GOOS {
+	case "linux":
+		action := "del"
+		ignores := []string{"No such process"}
+		if add {
+			action = "add"
+			ignores = []string{"File exists"}
+		}
+
+		args := []string{"route", action, network.String()}
+		if route.ViaTunnel {
+			args = append(args, "dev", iface)
+		} else {
+			args = append(args, "via", p.customGateway)
+		}
+		if route.Metric > 0 {
+			args = append(args, "metric", metric)
+		}
+		if ipv6 {
+			args = append([]string{"-6"}, args...)
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(ignores, "ip", args...)
+		break
+	case "darwin":
+		action := "delete"
+		ignores := []string{"not in table"}
+		if add {
+			action = "add"
+			ignores = []string{"File exists"}
+		}
+
+		family := "-inet"
+		if ipv6 {
+			family = "-inet6"
+		}
+
+		args := []string{"-q", "-n", action, family, network.String()}
+		if route.ViaTunnel {
+			args = append(args, "-interface", iface)
+		} else {
+			args = append(args, p.customGateway)
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(ignores, "route", args...)
+		break
+	case "windows":
+		action := "delete"
+		ignores := []string{"Element not found"}
+		if add {
+			action = "add"
+			ignores = []string{"already exists"}
+		}
+
+		if route.ViaTunnel {
+			family := "ipv4"
+			if ipv6 {
+				family = "ipv6"
+			}
+
+			args := []string{"interface", family, action, "route",
+				network.String(), iface, "store=active"}
+			if add && route.Metric > 0 {
+				args = append(args, "metric="+metric)
+			}
+
+			_, err = utils.ExecCombinedOutputLogged(ignores, "netsh", args...)
+		} else {
+			args := []string{action, network.IP.String(),
+				"mask", net.IP(network.Mask).String(), p.customGateway}
+			if add && route.Metric > 0 {
+				args = append(args, "metric", metric)
+			}
+
+			_, err = utils.ExecCombinedOutputLogged(ignores, "route", args...)
+		}
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) addCustomRoutes() {
+	if len(p.CustomRoutes) == 0 || len(p.customRoutesAdded) > 0 {
+		return
+	}
+
+	for _, route := range p.customRoutes() {
+		if route.ViaTunnel && p.killSwitchIface() == "" {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      route.Network,
+			}).Error("profile: Unknown tunnel interface for custom route")
+			continue
+		}
+		if !route.ViaTunnel && p.customGateway == "" {
+			continue
+		}
+
+		err := setCustomRoute(p, true, route)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      route.Network,
+				"error":      err,
+			}).Error("profile: Failed to add custom route")
+			continue
+		}
+
+		p.customRoutesAdded = append(p.customRoutesAdded, route)
+		Registry.AddRoute(p.Id, p.customRouteKey(route))
+	}
+}
+
+func (p *Profile) clearCustomRoutes() {
+	for _, route := range p.customRoutesAdded {
+		if Registry.RemoveRoute(p.Id, p.customRouteKey(route)) {
+			continue
+		}
+
+		err := setCustomRoute(p, false, route)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"route":      route.Network,
+				"error":      err,
+			}).Error("profile: Failed to remove custom route")
+		}
+	}
+
+	p.customRoutesAdded = nil
+}
@@ -14,7 +14,7 @@ for optionname in ${!foreign_option_*} ; do
   if [ "$part1" == "dhcp-option" ] ; then
     part2=$(echo "$option" | cut -d " " -f 2)
     part3=$(echo "$option" | cut -d " " -f 3)
-    if [ "$part2" == "DNS" ] ; then
+    if [[ "$part2" == "DNS" || "$part2" == "DNS6" ]] ; then
       DNS_SERVERS="$DNS_SERVERS $part3"
     fi
     if [[ "$part2" == "DOMAIN" || "$part2" == "DOMAIN-SEARCH" ]] ; then
@@ -174,7 +174,7 @@ up)
     if [ "$part1" == "dhcp-option" ] ; then
       part2=$(echo "$option" | cut -d " " -f 2)
       part3=$(echo "$option" | cut -d " " -f 3)
-      if [ "$part2" == "DNS" ] ; then
+      if [[ "$part2" == "DNS" || "$part2" == "DNS6" ]] ; then
         IF_DNS_NAMESERVERS="$IF_DNS_NAMESERVERS $part3"
       fi
       if [[ "$part2" == "DOMAIN" || "$part2" == "DOMAIN-SEARCH" ]] ; then
@@ -0,0 +1,432 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	darwinResolverDir = "/etc/resolver"
+	searchExec        = utils.ExecCombinedOutputLogged
+	searchLookPath    = exec.LookPath
+	searchFlushDns    = utils.ClearDNSCache
+	domainReg         = regexp.MustCompile(
+		`^[a-z0-9]([a-z0-9\-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9\-]*[a-z0-9])?)*$`)
+)
+
+func searchPowershell(script string) (output string, err error) {
+	output, err = searchExec(
+		nil,
+		"powershell.exe",
+		"-NoProfile",
+		"-NonInteractive",
+		"-Command",
+		script,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func searchPsList(vals []string) string {
+	quoted := []string{}
+	for _, val := range vals {
+		quoted = append(quoted, "'"+strings.ReplaceAll(val, "'", "''")+"'")
+	}
+	return strings.Join(quoted, ",")
+}
+
+func hasResolvectl() bool {
+	_, err := searchLookPath("resolvectl")
+	return err == nil
+}
+
+func (p *Profile) searchDomains() (domains []string) {
+	domains = []string{}
+	existing := set.NewSet()
+
+	for _, domain := range p.SearchDomains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" || existing.Contains(domain) {
+			continue
+		}
+
+		if !domainReg.MatchString(domain) {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"domain":     domain,
+			}).Warn("profile: Ignoring invalid search domain")
+			continue
+		}
+
+		existing.Add(domain)
+		domains = append(domains, domain)
+	}
+
+	return
+}
+
+func (p *Profile) searchComment() string {
+	return "pritunl-" + p.Id
+}
+
+func (p *Profile) applySearchDomainsLinux(iface string, domains,
+	dnsServers []string) (err error) {
+
+	if hasResolvectl() {
+		args := []string{"domain", iface}
+		for _, domain := range domains {
+			if p.SplitDns {
+				domain = "~" + domain
+			}
+			args = append(args, domain)
+		}
+
+		if p.SplitDns && len(dnsServers) > 0 {
+			_, err = searchExec(
+				nil,
+				"resolvectl",
+				append([]string{"dns", iface}, dnsServers...)...,
+			)
+			if err != nil {
+				return
+			}
+		}
+
+		_, err = searchExec(nil, "resolvectl", args...)
+		if err != nil {
+			return
+		}
+
+		if p.SplitDns {
+			_, _ = searchExec(
+				nil,
+				"resolvectl", "default-route", iface, "false",
+			)
+		}
+
+		return
+	}
+
+	if p.SplitDns {
+		err = &errortypes.UnknownError{
+			errors.New("profile: Split DNS requires systemd-resolved"),
+		}
+		return
+	}
+
+	prefix := ""
+	exists, _ := utils.Exists("/etc/resolvconf/interface-order")
+	if exists {
+		prefix = "tun."
+	}
+
+	input := ""
+	for _, dnsServer := range dnsServers {
+		input += "nameserver " + dnsServer + "\n"
+	}
+	input += "search " + strings.Join(domains, " ") + "\n"
+
+	err = utils.ExecInput(
+		"",
+		input,
+		"resolvconf", "-a", prefix+iface, "-m", "0", "-x",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) applySearchDomainsDarwin(domains,
+	dnsServers []string) (err error) {
+
+	if !p.SplitDns {
+		err = utils.SetScutilSearchDomains("/Network/Pritunl/DNS", domains)
+		if err != nil {
+			return
+		}
+
+		err = utils.CopyScutilDns("/Network/Pritunl/DNS")
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	err = os.MkdirAll(darwinResolverDir, 0755)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "profile: Failed to create resolver directory"),
+		}
+		return
+	}
+
+	data := "# " + p.searchComment() + "\n"
+	for _, dnsServer := range dnsServers {
+		data += "nameserver " + dnsServer + "\n"
+	}
+
+	for _, domain := range domains {
+		err = utils.CreateWrite(filepath.Join(darwinResolverDir, domain),
+			data, 0644)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (p *Profile) applySearchDomainsWin(domains,
+	dnsServers []string) (err error) {
+
+	if p.SplitDns {
+		namespaces := []string{}
+		for _, domain := range domains {
+			namespaces = append(namespaces, "."+domain)
+		}
+
+		_, err = searchPowershell("Add-DnsClientNrptRule -Namespace " +
+			searchPsList(namespaces) + " -NameServers " +
+			searchPsList(dnsServers) + " -Comment " +
+			searchPsList([]string{p.searchComment()}))
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	output, err := searchPowershell(
+		"(Get-DnsClientGlobalSetting).SuffixSearchList -join ','")
+	if err != nil {
+		return
+	}
+
+	orig := []string{}
+	for _, domain := range strings.Split(strings.TrimSpace(output), ",") {
+		domain = strings.TrimSpace(domain)
+		if domain != "" {
+			orig = append(orig, domain)
+		}
+	}
+
+	suffixes := append([]string{}, domains...)
+	existing := set.NewSet()
+	for _, domain := range domains {
+		existing.Add(domain)
+	}
+	for _, domain := range orig {
+		if !existing.Contains(strings.ToLower(domain)) {
+			suffixes = append(suffixes, domain)
+		}
+	}
+
+	_, err = searchPowershell("Set-DnsClientGlobalSetting " +
+		"-SuffixSearchList @(" + searchPsList(suffixes) + ")")
+	if err != nil {
+		return
+	}
+
+	p.searchDomainsOrig = orig
+
+	return
+}
+
+func (p *Profile) applySearchDomains() (err error) {
+	domains := p.searchDomains()
+	if len(domains) == 0 {
+		return
+	}
+
+	iface := p.dnsIface()
+	dnsServers := p.dnsServers()
+
+	if p.SplitDns && len(dnsServers) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: No DNS servers available for split DNS"),
+		}
+		return
+	}
+
+	p.searchDomainsLock.Lock()
+	defer p.searchDomainsLock.Unlock()
+
+	if p.stop {
+		return
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if iface == "" {
+			return
+		}
+		err = p.applySearchDomainsLinux(iface, domains, dnsServers)
+		break
+	case "darwin":
+		err = p.applySearchDomainsDarwin(domains, dnsServers)
+		break
+	case "windows":
+		err = p.applySearchDomainsWin(domains, dnsServers)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+	p.searchDomainsIface = iface
+	p.searchDomainsSet = true
+
+	searchFlushDns()
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"domains":    domains,
+		"split_dns":  p.SplitDns,
+	}).Info("profile: Applied DNS search domains")
+
+	return
+}
+
+func (p *Profile) applySearchDomainsBackground() {
+	if len(p.SearchDomains) == 0 {
+		return
+	}
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		err := p.applySearchDomains()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to apply DNS search domains")
+		}
+	}()
+}
+
+func (p *Profile) clearSearchDomainsDarwin() (err error) {
+	files, err := ioutil.ReadDir(darwinResolverDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to read resolver directory"),
+		}
+		return
+	}
+
+	marker := "# " + p.searchComment() + "\n"
+
+	for _, file := range files {
+		pth := filepath.Join(darwinResolverDir, file.Name())
+
+		data, e := ioutil.ReadFile(pth)
+		if e != nil || !strings.HasPrefix(string(data), marker) {
+			continue
+		}
+
+		e = utils.Remove(pth)
+		if e != nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+func (p *Profile) clearSearchDomainsWin() (err error) {
+	if p.SplitDns {
+		_, err = searchPowershell("Get-DnsClientNrptRule | " +
+			"Where-Object { $_.Comment -eq " +
+			searchPsList([]string{p.searchComment()}) + " } | " +
+			"Remove-DnsClientNrptRule -Force")
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	_, err = searchPowershell("Set-DnsClientGlobalSetting " +
+		"-SuffixSearchList @(" + searchPsList(p.searchDomainsOrig) + ")")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) clearSearchDomains() {
+	p.searchDomainsLock.Lock()
+	defer p.searchDomainsLock.Unlock()
+
+	if !p.searchDomainsSet {
+		return
+	}
+
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		if hasResolvectl() {
+			_, err = searchExec(
+				[]string{"Failed to resolve interface", "No such device"},
+				"resolvectl", "revert", p.searchDomainsIface,
+			)
+		}
+		break
+	case "darwin":
+		if p.SplitDns {
+			err = p.clearSearchDomainsDarwin()
+		}
+		break
+	case "windows":
+		err = p.clearSearchDomainsWin()
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to remove DNS search domains")
+	}
+
+	p.searchDomainsSet = false
+	p.searchDomainsOrig = nil
+	p.searchDomainsIface = ""
+
+	searchFlushDns()
+}
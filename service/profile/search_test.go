@@ -0,0 +1,272 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var searchQuoteReg = regexp.MustCompile(`'((?:[^']|'')*)'`)
+
+func searchQuoted(script string) (vals []string) {
+	for _, match := range searchQuoteReg.FindAllStringSubmatch(script, -1) {
+		vals = append(vals, strings.ReplaceAll(match[1], "''", "'"))
+	}
+	return
+}
+
+type fakeResolver struct {
+	suffixes  []string
+	nrpt      map[string][]string
+	resolved  map[string][]string
+	resolvctl bool
+}
+
+func setFakeResolver(t *testing.T, rslv *fakeResolver) {
+	origExec := searchExec
+	origLookPath := searchLookPath
+	origDir := darwinResolverDir
+	origFlush := searchFlushDns
+
+	rslv.nrpt = map[string][]string{}
+	rslv.resolved = map[string][]string{}
+
+	searchExec = func(ignores []string, name string, args ...string) (
+		output string, err error) {
+
+		switch name {
+		case "powershell.exe":
+			script := args[len(args)-1]
+			switch {
+			case strings.HasPrefix(script, "(Get-DnsClientGlobalSetting)"):
+				output = strings.Join(rslv.suffixes, ",") + "\r\n"
+				break
+			case strings.HasPrefix(script, "Set-DnsClientGlobalSetting"):
+				rslv.suffixes = searchQuoted(script)
+				break
+			case strings.HasPrefix(script, "Add-DnsClientNrptRule"):
+				vals := searchQuoted(script)
+				comment := vals[len(vals)-1]
+				rslv.nrpt[comment] = vals[:len(vals)-1]
+				break
+			case strings.HasPrefix(script, "Get-DnsClientNrptRule"):
+				delete(rslv.nrpt, searchQuoted(script)[0])
+				break
+			default:
+				t.Fatalf("unexpected powershell script %s", script)
+			}
+			break
+		case "resolvectl":
+			switch args[0] {
+			case "domain":
+				rslv.resolved[args[1]] = args[2:]
+				break
+			case "revert":
+				delete(rslv.resolved, args[1])
+				break
+			}
+			break
+		default:
+			t.Fatalf("unexpected command %s", name)
+		}
+		return
+	}
+	searchLookPath = func(file string) (pth string, err error) {
+		if !rslv.resolvctl {
+			err = os.ErrNotExist
+		}
+		pth = "/usr/bin/" + file
+		return
+	}
+	darwinResolverDir = filepath.Join(t.TempDir(), "resolver")
+	searchFlushDns = func() {}
+
+	t.Cleanup(func() {
+		searchExec = origExec
+		searchLookPath = origLookPath
+		darwinResolverDir = origDir
+		searchFlushDns = origFlush
+	})
+}
+
+func TestSearchDomainsFilter(t *testing.T) {
+	prfl := &Profile{
+		Id: "prfl0",
+		SearchDomains: []string{
+			" Corp.Example.com. ", "corp.example.com", "", "bad_domain",
+			"eng.example.com",
+		},
+	}
+
+	domains := prfl.searchDomains()
+	if strings.Join(domains, ",") != "corp.example.com,eng.example.com" {
+		t.Errorf("unexpected search domains %v", domains)
+	}
+}
+
+func TestSearchDomainsRestoreWin(t *testing.T) {
+	rslv := &fakeResolver{
+		suffixes: []string{"home.lan", "Corp.Example.com"},
+	}
+	setFakeResolver(t, rslv)
+
+	prfl := &Profile{
+		Id:            "prfl0",
+		SearchDomains: []string{"corp.example.com", "eng.example.com"},
+	}
+
+	err := prfl.applySearchDomainsWin(prfl.searchDomains(),
+		[]string{"10.8.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := strings.Join(rslv.suffixes, ",")
+	if applied != "corp.example.com,eng.example.com,home.lan" {
+		t.Errorf("unexpected suffix list %s", applied)
+	}
+
+	err = prfl.clearSearchDomainsWin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := strings.Join(rslv.suffixes, ",")
+	if restored != "home.lan,Corp.Example.com" {
+		t.Errorf("original suffix list not restored, got %s", restored)
+	}
+}
+
+func TestSearchDomainsRestoreWinSplit(t *testing.T) {
+	rslv := &fakeResolver{
+		suffixes: []string{"home.lan"},
+	}
+	setFakeResolver(t, rslv)
+	rslv.nrpt["other"] = []string{".other.com", "192.168.1.53"}
+
+	prfl := &Profile{
+		Id:            "prfl0",
+		SearchDomains: []string{"corp.example.com"},
+		SplitDns:      true,
+	}
+
+	err := prfl.applySearchDomainsWin(prfl.searchDomains(),
+		[]string{"10.8.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := strings.Join(rslv.nrpt[prfl.searchComment()], ",")
+	if rule != ".corp.example.com,10.8.0.1" {
+		t.Errorf("unexpected nrpt rule %s", rule)
+	}
+
+	err = prfl.clearSearchDomainsWin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := rslv.nrpt[prfl.searchComment()]; ok {
+		t.Error("nrpt rule not removed")
+	}
+	if len(rslv.nrpt) != 1 || strings.Join(rslv.suffixes, ",") != "home.lan" {
+		t.Errorf("unrelated resolver settings changed %v %v",
+			rslv.nrpt, rslv.suffixes)
+	}
+}
+
+func TestSearchDomainsRestoreDarwinSplit(t *testing.T) {
+	rslv := &fakeResolver{}
+	setFakeResolver(t, rslv)
+
+	err := os.MkdirAll(darwinResolverDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPth := filepath.Join(darwinResolverDir, "home.lan")
+	err = ioutil.WriteFile(otherPth, []byte("nameserver 192.168.1.53\n"),
+		0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prfl := &Profile{
+		Id:            "prfl0",
+		SearchDomains: []string{"corp.example.com", "eng.example.com"},
+		SplitDns:      true,
+	}
+
+	err = prfl.applySearchDomainsDarwin(prfl.searchDomains(),
+		[]string{"10.8.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(
+		filepath.Join(darwinResolverDir, "corp.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "nameserver 10.8.0.1\n") {
+		t.Errorf("unexpected resolver file %q", string(data))
+	}
+
+	err = prfl.clearSearchDomainsDarwin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(darwinResolverDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "home.lan" {
+		t.Errorf("unexpected resolver files after teardown %v", files)
+	}
+}
+
+func TestSearchDomainsRestoreLinux(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
+
+	rslv := &fakeResolver{
+		resolvctl: true,
+	}
+	setFakeResolver(t, rslv)
+	rslv.resolved["eth0"] = []string{"home.lan"}
+
+	prfl := &Profile{
+		Id:            "prfl0",
+		SearchDomains: []string{"corp.example.com"},
+		SplitDns:      true,
+	}
+
+	err := prfl.applySearchDomainsLinux("tun0", prfl.searchDomains(),
+		[]string{"10.8.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prfl.searchDomainsIface = "tun0"
+	prfl.searchDomainsSet = true
+
+	if strings.Join(rslv.resolved["tun0"], ",") != "~corp.example.com" {
+		t.Errorf("unexpected tunnel domains %v", rslv.resolved["tun0"])
+	}
+
+	prfl.clearSearchDomains()
+
+	if _, ok := rslv.resolved["tun0"]; ok {
+		t.Error("tunnel domains not reverted")
+	}
+	if strings.Join(rslv.resolved["eth0"], ",") != "home.lan" {
+		t.Errorf("original domains changed %v", rslv.resolved["eth0"])
+	}
+	if prfl.searchDomainsSet || prfl.searchDomainsIface != "" {
+		t.Error("search domain state not cleared")
+	}
+}
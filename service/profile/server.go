@@ -0,0 +1,85 @@
+package profile
+
+import (
+	"net/url"
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	serverSyncDefault = 6 * time.Hour
+	serverSyncMin     = 5 * time.Minute
+)
+
+func serverPins(importUrl string) []string {
+	u, err := url.Parse(importUrl)
+	if err != nil {
+		return nil
+	}
+
+	return config.Config.ServerPins[u.Hostname()]
+}
+
+func serverSyncInterval() time.Duration {
+	interval := time.Duration(config.Config.ServerSyncInterval) * time.Second
+	if interval == 0 {
+		return serverSyncDefault
+	}
+	if interval < serverSyncMin {
+		return serverSyncMin
+	}
+	return interval
+}
+
+func SyncFromServer(serverUrl, token string) (prfls []*Profile, err error) {
+	importUrl, err := sprofile.ServerUrl(serverUrl, token)
+	if err != nil {
+		return
+	}
+
+	sprfls, err := sprofile.SyncServer(importUrl, serverPins(importUrl))
+	if err != nil {
+		return
+	}
+
+	prfls = []*Profile{}
+	for _, sprfl := range sprfls {
+		prfls = append(prfls, ImportSystemProfile(sprfl))
+	}
+
+	return
+}
+
+func watchServerImports() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		time.Sleep(serverSyncInterval())
+
+		if shutdown {
+			return
+		}
+
+		for _, importUrl := range sprofile.ImportUrls() {
+			_, err := sprofile.SyncServer(importUrl, serverPins(importUrl))
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("profile: Failed to sync server profiles")
+			}
+		}
+	}
+}
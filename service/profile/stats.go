@@ -0,0 +1,29 @@
+package profile
+
+import (
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+	"github.com/sirupsen/logrus"
+)
+
+func (p *Profile) recordSession() {
+	if p.Timestamp == 0 || !p.connected {
+		return
+	}
+
+	err := stats.Record(stats.Session{
+		ProfileId: p.Id,
+		Server:    p.ServerAddr,
+		Start:     p.Timestamp,
+		End:       time.Now().Unix(),
+		RxBytes:   p.rxBytes,
+		TxBytes:   p.txBytes,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to record session stats")
+	}
+}
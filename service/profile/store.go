@@ -0,0 +1,13 @@
+package profile
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+)
+
+// Store guards reads and writes to the profiles directory, it is defined in
+// sprofile which owns the directory and is imported by this package
+type Store = sprofile.Store
+
+func NewStore(dir string) *Store {
+	return sprofile.NewStore(dir)
+}
@@ -11,8 +11,11 @@ import (
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/parser"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/update"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
@@ -250,6 +253,15 @@ func UpdateSystemProfile(prfl *Profile, sPrfl *sprofile.Sprofile) {
 		lastMode = "ovpn"
 	}
 
+	var customRoutes []*CustomRoute
+	for _, route := range sPrfl.CustomRoutes {
+		customRoutes = append(customRoutes, &CustomRoute{
+			Network:   route.Network,
+			Metric:    route.Metric,
+			ViaTunnel: route.ViaTunnel,
+		})
+	}
+
 	prfl.Id = sPrfl.Id
 	prfl.Mode = lastMode
 	prfl.OrgId = sPrfl.OrganizationId
@@ -263,6 +275,10 @@ func UpdateSystemProfile(prfl *Profile, sPrfl *sprofile.Sprofile) {
 	prfl.Password = sPrfl.Password
 	prfl.DynamicFirewall = sPrfl.DynamicFirewall
 	prfl.DisableGateway = sPrfl.DisableGateway
+	prfl.ExcludeRoutes = sPrfl.ExcludeRoutes
+	prfl.CustomRoutes = customRoutes
+	prfl.KillSwitch = sPrfl.KillSwitch
+	prfl.BlockIpv6 = sPrfl.BlockIpv6
 	prfl.SsoAuth = sPrfl.SsoAuth
 	prfl.ServerPublicKey = serverPublicKey
 	prfl.ServerBoxPublicKey = sPrfl.ServerBoxPublicKey
@@ -271,6 +287,15 @@ func UpdateSystemProfile(prfl *Profile, sPrfl *sprofile.Sprofile) {
 	prfl.SystemProfile = sPrfl
 }
 
+func ValidateConfig(data []byte) (err error) {
+	err = parser.Validate(string(data))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func ImportSystemProfile(sPrfl *sprofile.Sprofile) (prfl *Profile) {
 	prfl = &Profile{
 		Id: sPrfl.Id,
@@ -308,6 +333,25 @@ func GetProfile(id string) (prfl *Profile) {
 	return
 }
 
+// Explicit user disconnect, cached one-time passwords are cleared so the
+// next connect prompts again
+func Disconnect(prflId string) {
+	OtpCacheClear(prflId)
+
+	if sprofile.Get(prflId) != nil {
+		DisableOnDemand(prflId)
+		sprofile.Deactivate(prflId)
+		return
+	}
+
+	sprofile.ClearUserState(prflId)
+
+	prfl := GetProfile(prflId)
+	if prfl != nil {
+		prfl.Stop()
+	}
+}
+
 func GetProfiles() (prfls map[string]*Profile) {
 	prfls = map[string]*Profile{}
 
@@ -444,11 +488,10 @@ func SyncSystemProfiles() (err error) {
 	if update {
 		evt := event.Event{
 			Type: "update",
-			Data: &Profile{
-				Id: "",
-			},
 		}
-		evt.Init()
+		evt.Init(&Profile{
+			Id: "",
+		})
 
 		status := GetStatus()
 
@@ -456,12 +499,12 @@ func SyncSystemProfiles() (err error) {
 			evt := event.Event{
 				Type: "connected",
 			}
-			evt.Init()
+			evt.Init(nil)
 		} else {
 			evt := event.Event{
 				Type: "disconnected",
 			}
-			evt.Init()
+			evt.Init(nil)
 		}
 	}
 
@@ -473,6 +516,44 @@ func Shutdown() {
 	sprofile.Shutdown()
 }
 
+func StopAll(timeout time.Duration) {
+	prfls := GetProfiles()
+	if len(prfls) == 0 {
+		return
+	}
+
+	for _, prfl := range prfls {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prfl.Id,
+			"mode":       prfl.Mode,
+			"iface":      prfl.Iface,
+		}).Info("profile: Disconnecting for shutdown")
+
+		prfl.StopBackground()
+	}
+
+	done := make(chan bool)
+	go func() {
+		for _, prfl := range prfls {
+			prfl.Wait()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.WithFields(logrus.Fields{
+			"count": len(prfls),
+		}).Info("profile: All connections disconnected")
+	case <-time.After(timeout):
+		for _, prfl := range GetProfiles() {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": prfl.Id,
+			}).Error("profile: Timeout disconnecting for shutdown")
+		}
+	}
+}
+
 func watchSystemProfiles() {
 	time.Sleep(1 * time.Second)
 	sprofile.Reload(true)
@@ -502,4 +583,22 @@ func watchSystemProfiles() {
 
 func WatchSystemProfiles() {
 	go watchSystemProfiles()
+	go watchServerImports()
+}
+
+func ReloadWg(prflId string, data *WgConf) (err error) {
+	prfl := GetProfile(prflId)
+	if prfl == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Profile not found"),
+		}
+		return
+	}
+
+	err = prfl.ReloadWg(data)
+	if err != nil {
+		return
+	}
+
+	return
 }
@@ -0,0 +1,15 @@
+package profile
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/webhook"
+)
+
+func (p *Profile) sendWebhook(typ, outcome, reason string) {
+	webhook.Send(&webhook.Event{
+		Type:      typ,
+		ProfileId: p.Id,
+		Server:    p.ServerAddr,
+		Outcome:   outcome,
+		Reason:    reason,
+	})
+}
@@ -0,0 +1,466 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	wgExec         = utils.ExecCombinedOutputLogged
+	writeWgConfig  = (*Profile).writeWgConf
+	writeWgSync    = (*Profile).writeWgSyncConf
+	reloadWgConfig = (*Profile).reloadWgFull
+)
+
+func wgNetworks(data *WgConf) (networks set.Set) {
+	networks = set.NewSet()
+
+	for _, route := range data.Routes {
+		networks.Add(route.Network)
+	}
+	for _, route := range data.Routes6 {
+		networks.Add(route.Network)
+	}
+
+	return
+}
+
+func wgStrEqual(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Profile) wgTunIface() string {
+	if runtime.GOOS == "darwin" {
+		return p.Tuniface
+	}
+	return p.Iface
+}
+
+func (p *Profile) writeWgSyncConf(data *WgConf) (pth string, err error) {
+	output := &bytes.Buffer{}
+	err = WgSyncConfTempl.Execute(output, p.wgConfData(data))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "profile: Failed to exec wg sync template"),
+		}
+		return
+	}
+
+	rootDir, err := utils.GetTempDir()
+	if err != nil {
+		return
+	}
+
+	pth = filepath.Join(rootDir, p.Id+".sync.conf")
+
+	_ = os.Remove(pth)
+	err = ioutil.WriteFile(
+		pth,
+		[]byte(output.String()),
+		os.FileMode(0600),
+	)
+	if err != nil {
+		err = &WriteError{
+			errors.Wrap(err, "profile: Failed to write wg sync conf"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Profile) syncWgConf(data *WgConf) (err error) {
+	pth, err := writeWgSync(p, data)
+	if err != nil {
+		return
+	}
+	defer os.Remove(pth)
+
+	_, err = wgExec(
+		nil,
+		p.wgPath,
+		"syncconf", p.wgTunIface(), pth,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) routeWg(add bool, network string) (err error) {
+	iface := p.wgTunIface()
+	ipv6 := strings.Contains(network, ":")
+
+	switch runtime.GOOS {
+	case "linux":
+		action := "del"
+		ignores := []string{"No such process"}
+		if add {
+			action = "add"
+			ignores = []string{"File exists"}
+		}
+
+		args := []string{"route", action, network, "dev", iface}
+		if ipv6 {
+			args = append([]string{"-6"}, args...)
+		}
+
+		_, err = wgExec(ignores, "ip", args...)
+		break
+	case "darwin":
+		action := "delete"
+		ignores := []string{"not in table"}
+		if add {
+			action = "add"
+			ignores = []string{"File exists"}
+		}
+
+		family := "-inet"
+		if ipv6 {
+			family = "-inet6"
+		}
+
+		_, err = wgExec(
+			ignores,
+			"route", "-q", "-n", action, family,
+			network, "-interface", iface,
+		)
+		break
+	case "windows":
+		action := "delete"
+		ignores := []string{"Element not found"}
+		if add {
+			action = "add"
+			ignores = []string{"already exists"}
+		}
+
+		family := "ipv4"
+		if ipv6 {
+			family = "ipv6"
+		}
+
+		_, err = wgExec(
+			ignores,
+			"netsh", "interface", family, action, "route",
+			network, iface, "store=active",
+		)
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func setDnsLinux(iface string, dnsServers []string) (err error) {
+	prefix := ""
+	exists, _ := utils.Exists("/etc/resolvconf/interface-order")
+	if exists {
+		prefix = "tun."
+	}
+
+	if len(dnsServers) == 0 {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"resolvconf", "-d", prefix+iface, "-f",
+		)
+		return
+	}
+
+	input := ""
+	for _, dnsServer := range dnsServers {
+		input += fmt.Sprintf("nameserver %s\n", dnsServer)
+	}
+
+	err = utils.ExecInput(
+		"",
+		input,
+		"resolvconf", "-a", prefix+iface, "-m", "0", "-x",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func setDnsWin(iface string, dnsServers []string) (err error) {
+	families := map[string][]string{
+		"ipv4": {},
+		"ipv6": {},
+	}
+
+	for _, dnsServer := range dnsServers {
+		if strings.Contains(dnsServer, ":") {
+			families["ipv6"] = append(families["ipv6"], dnsServer)
+		} else {
+			families["ipv4"] = append(families["ipv4"], dnsServer)
+		}
+	}
+
+	for family, servers := range families {
+		addr := "none"
+		if len(servers) > 0 {
+			addr = servers[0]
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"netsh", "interface", family, "set", "dnsservers",
+			"name="+iface, "source=static", "address="+addr,
+			"register=none", "validate=no",
+		)
+		if err != nil {
+			return
+		}
+
+		for i, server := range servers {
+			if i == 0 {
+				continue
+			}
+
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"netsh", "interface", family, "add", "dnsservers",
+				"name="+iface, "address="+server,
+				"index="+strconv.Itoa(i+1), "validate=no",
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (p *Profile) reloadWgFull(data *WgConf) (err error) {
+	wgConfPth, err := p.writeWgConf(data)
+	if err != nil {
+		return
+	}
+	p.wgConfPth = wgConfPth
+
+	err = p.confWg(data)
+	if err != nil {
+		return
+	}
+
+	p.Routes = data.Routes
+	p.Routes6 = data.Routes6
+
+	return
+}
+
+func wgEndpoint(data *WgConf) string {
+	return fmt.Sprintf("%s:%d", data.Hostname, data.Port)
+}
+
+// Interface addresses and default route changes require wg-quick to rebuild
+// the interface, other changes are applied in place with wg syncconf.
+func wgNeedsReconfigure(curData, data *WgConf) bool {
+	curNetworks := wgNetworks(curData)
+	newNetworks := wgNetworks(data)
+
+	return data.Address != curData.Address ||
+		data.Address6 != curData.Address6 ||
+		curNetworks.Contains("0.0.0.0/0") !=
+			newNetworks.Contains("0.0.0.0/0") ||
+		curNetworks.Contains("::/0") != newNetworks.Contains("::/0")
+}
+
+func wgNeedsHandshake(curData, data *WgConf) bool {
+	return data.PublicKey != curData.PublicKey ||
+		wgEndpoint(data) != wgEndpoint(curData)
+}
+
+func (p *Profile) waitWgHandshake(prevHandshake int) (err error) {
+	for i := 0; i < 20; i++ {
+		if i%10 == 0 {
+			go p.pingWg()
+		}
+
+		err = p.updateWgHandshake()
+		if err != nil {
+			return
+		}
+
+		if p.wgHandshake != 0 && p.wgHandshake != prevHandshake {
+			return
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	err = &errortypes.UnknownError{
+		errors.New("profile: No wg handshake after reload"),
+	}
+	return
+}
+
+func (p *Profile) ReloadWg(data *WgConf) (err error) {
+	curData := p.wgConf
+	if p.Mode != Wg || curData == nil || p.Iface == "" || p.stop {
+		err = &errortypes.UnknownError{
+			errors.New("profile: Profile wg connection not active"),
+		}
+		return
+	}
+
+	p.filterWgConf(data)
+	p.loadWgPorts(data)
+
+	curNetworks := wgNetworks(curData)
+	newNetworks := wgNetworks(data)
+	dnsChanged := !wgStrEqual(curData.DnsServers, data.DnsServers)
+	handshake := wgNeedsHandshake(curData, data)
+
+	if !handshake && !dnsChanged && curNetworks.IsEqual(newNetworks) &&
+		!wgNeedsReconfigure(curData, data) {
+
+		return
+	}
+
+	if wgNeedsReconfigure(curData, data) {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Info("profile: Reloading wg with full reconfigure")
+
+		err = reloadWgConfig(p, data)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	_, err = writeWgConfig(p, data)
+	if err != nil {
+		return
+	}
+
+	p.wgQuickLock.Lock()
+	defer p.wgQuickLock.Unlock()
+
+	_ = p.updateWgHandshake()
+	prevHandshake := p.wgHandshake
+
+	err = p.syncWgConf(data)
+	if err != nil {
+		return
+	}
+
+	p.wgConf = data
+	p.ServerAddr = data.Hostname
+	p.GatewayAddr = data.Gateway
+	p.GatewayAddr6 = data.Gateway6
+	p.wgServerPublicKey = data.PublicKey
+
+	if handshake {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Info("profile: Peer changed, waiting for wg handshake")
+
+		err = p.waitWgHandshake(prevHandshake)
+		if err != nil {
+			return
+		}
+	} else {
+		err = p.updateWgHandshake()
+		if err != nil {
+			return
+		}
+
+		if p.wgHandshake != prevHandshake {
+			logrus.WithFields(logrus.Fields{
+				"profile_id":     p.Id,
+				"prev_handshake": prevHandshake,
+				"handshake":      p.wgHandshake,
+			}).Warn("profile: Unexpected wg handshake reset on reload")
+		}
+	}
+
+	added := []string{}
+	for networkInf := range newNetworks.Iter() {
+		if !curNetworks.Contains(networkInf) {
+			added = append(added, networkInf.(string))
+		}
+	}
+
+	removed := []string{}
+	for networkInf := range curNetworks.Iter() {
+		if !newNetworks.Contains(networkInf) {
+			removed = append(removed, networkInf.(string))
+		}
+	}
+
+	for _, network := range added {
+		err = p.routeWg(true, network)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, network := range removed {
+		err = p.routeWg(false, network)
+		if err != nil {
+			return
+		}
+	}
+
+	p.Routes = data.Routes
+	p.Routes6 = data.Routes6
+
+	if dnsChanged {
+		switch runtime.GOOS {
+		case "linux":
+			err = setDnsLinux(p.Iface, data.DnsServers)
+			break
+		case "darwin":
+			err = utils.SetScutilDnsServers(
+				"/Network/Pritunl/DNS", data.DnsServers)
+			if err != nil {
+				break
+			}
+			err = utils.CopyScutilDns("/Network/Pritunl/DNS")
+			break
+		case "windows":
+			err = setDnsWin(p.Iface, data.DnsServers)
+			break
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	p.update()
+
+	return
+}
@@ -0,0 +1,179 @@
+package profile
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func testWgConf() *WgConf {
+	return &WgConf{
+		Address:   "10.100.0.2/24",
+		Hostname:  "vpn.example.com",
+		Port:      51820,
+		PublicKey: "serverkey",
+		Routes: []*Route{
+			{Network: "10.100.0.0/24"},
+		},
+		DnsServers: []string{"10.100.0.1"},
+	}
+}
+
+func TestWgReloadAllowedIps(t *testing.T) {
+	curData := testWgConf()
+	data := testWgConf()
+	data.Routes = append(data.Routes, &Route{Network: "10.200.0.0/16"})
+	data.DnsServers = []string{"10.100.0.1", "10.100.0.53"}
+
+	if wgNeedsReconfigure(curData, data) {
+		t.Error("allowed ips change should not rebuild the interface")
+	}
+	if wgNeedsHandshake(curData, data) {
+		t.Error("allowed ips change should not re-handshake")
+	}
+}
+
+func TestWgReloadPeer(t *testing.T) {
+	curData := testWgConf()
+
+	data := testWgConf()
+	data.PublicKey = "newkey"
+	if !wgNeedsHandshake(curData, data) {
+		t.Error("public key change should re-handshake")
+	}
+	if wgNeedsReconfigure(curData, data) {
+		t.Error("public key change should not rebuild the interface")
+	}
+
+	data = testWgConf()
+	data.Port = 51821
+	if !wgNeedsHandshake(curData, data) {
+		t.Error("endpoint change should re-handshake")
+	}
+}
+
+func TestWgReloadAddress(t *testing.T) {
+	curData := testWgConf()
+
+	data := testWgConf()
+	data.Address = "10.100.0.3/24"
+	if !wgNeedsReconfigure(curData, data) {
+		t.Error("address change should rebuild the interface")
+	}
+
+	data = testWgConf()
+	data.Routes = append(data.Routes, &Route{Network: "0.0.0.0/0"})
+	if !wgNeedsReconfigure(curData, data) {
+		t.Error("default route change should rebuild the interface")
+	}
+}
+
+type fakeWgExec struct {
+	cmds        []string
+	fullReloads int
+	confWrites  int
+	syncPth     string
+}
+
+func setFakeWgExec(t *testing.T, fake *fakeWgExec) {
+	origExec := wgExec
+	origWrite := writeWgConfig
+	origSync := writeWgSync
+	origReload := reloadWgConfig
+
+	wgExec = func(ignores []string, name string, args ...string) (
+		output string, err error) {
+
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		fake.cmds = append(fake.cmds, cmd)
+
+		if strings.HasSuffix(cmd, "latest-handshakes") {
+			output = "serverkey\t1700000000\n"
+		}
+		return
+	}
+	writeWgConfig = func(p *Profile, data *WgConf) (pth string, err error) {
+		fake.confWrites += 1
+		pth = "/tmp/" + p.Iface + ".conf"
+		return
+	}
+	writeWgSync = func(p *Profile, data *WgConf) (pth string, err error) {
+		pth = filepath.Join(t.TempDir(), p.Id+".sync.conf")
+		fake.syncPth = pth
+		return
+	}
+	reloadWgConfig = func(p *Profile, data *WgConf) (err error) {
+		fake.fullReloads += 1
+		return
+	}
+
+	t.Cleanup(func() {
+		wgExec = origExec
+		writeWgConfig = origWrite
+		writeWgSync = origSync
+		reloadWgConfig = origReload
+	})
+}
+
+func TestWgReloadKeepsConnection(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
+
+	fake := &fakeWgExec{}
+	setFakeWgExec(t, fake)
+
+	curData := testWgConf()
+	curData.Routes = append(curData.Routes,
+		&Route{Network: "10.150.0.0/16"})
+
+	prfl := &Profile{
+		Id:                "prfl0",
+		Mode:              Wg,
+		Iface:             "wg0",
+		Routes:            curData.Routes,
+		wgPath:            "wg",
+		wgConf:            curData,
+		wgServerPublicKey: curData.PublicKey,
+	}
+
+	data := testWgConf()
+	data.Routes = append(data.Routes, &Route{Network: "10.200.0.0/16"})
+
+	err := prfl.ReloadWg(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"wg show wg0 latest-handshakes",
+		"wg syncconf wg0 " + fake.syncPth,
+		"wg show wg0 latest-handshakes",
+		"ip route add 10.200.0.0/16 dev wg0",
+		"ip route del 10.150.0.0/16 dev wg0",
+	}
+	if strings.Join(fake.cmds, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected commands:\n%s", strings.Join(fake.cmds, "\n"))
+	}
+
+	for _, cmd := range fake.cmds {
+		if strings.Contains(cmd, "wg-quick") ||
+			strings.Contains(cmd, " link ") {
+
+			t.Errorf("interface changed by reload: %s", cmd)
+		}
+	}
+	if fake.fullReloads != 0 {
+		t.Error("allowed ips change ran a full reload")
+	}
+	if fake.confWrites != 1 {
+		t.Errorf("expected one conf write, got %d", fake.confWrites)
+	}
+	if prfl.wgHandshake != 1700000000 {
+		t.Errorf("handshake reset to %d", prfl.wgHandshake)
+	}
+	if prfl.wgConf != data || len(prfl.Routes) != 2 {
+		t.Error("reloaded configuration not applied")
+	}
+}
@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	wgPortAttempts = 10
+)
+
+var (
+	wgHandshakeInterval = 500 * time.Millisecond
+	checkWgHandshake    = (*Profile).updateWgHandshake
+	setWgEndpoint       = (*Profile).setWgEndpoint
+	sendWgPing          = func(p *Profile) {
+		go p.pingWg()
+	}
+	wgPortCache = struct {
+		sync.Mutex
+		m map[string]int
+	}{
+		m: map[string]int{},
+	}
+)
+
+func wgPortCandidates(primary int, ports []int, cached int) (
+	candidates []int) {
+
+	candidates = []int{}
+	exists := map[int]bool{}
+
+	valid := map[int]bool{
+		primary: true,
+	}
+	for _, port := range ports {
+		valid[port] = true
+	}
+
+	add := func(port int) {
+		if port <= 0 || port > 65535 || exists[port] {
+			return
+		}
+		exists[port] = true
+		candidates = append(candidates, port)
+	}
+
+	if valid[cached] {
+		add(cached)
+	}
+	add(primary)
+	for _, port := range ports {
+		add(port)
+	}
+
+	return
+}
+
+func (p *Profile) loadWgPorts(data *WgConf) {
+	wgPortCache.Lock()
+	cached := wgPortCache.m[p.Id]
+	wgPortCache.Unlock()
+
+	p.wgPorts = wgPortCandidates(data.Port, p.WgPorts, cached)
+	p.wgPortIndex = 0
+
+	if len(p.wgPorts) > 0 {
+		data.Port = p.wgPorts[0]
+	}
+}
+
+func (p *Profile) nextWgPort() {
+	data := p.wgConf
+	if data == nil || p.wgPortIndex+1 >= len(p.wgPorts) {
+		return
+	}
+
+	p.wgPortIndex += 1
+	port := p.wgPorts[p.wgPortIndex]
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id":    p.Id,
+		"previous_port": data.Port,
+		"port":          port,
+	}).Warn("profile: No wg handshake, trying next port")
+
+	err := setWgEndpoint(p, data, port)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"port":       port,
+			"error":      err,
+		}).Error("profile: Failed to set wg endpoint port")
+		return
+	}
+
+	data.Port = port
+}
+
+func (p *Profile) setWgEndpoint(data *WgConf, port int) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		p.wgPath,
+		"set", p.wgTunIface(),
+		"peer", data.PublicKey,
+		"endpoint", fmt.Sprintf("%s:%d", data.Hostname, port),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Poll for the first handshake, moving to the next candidate port after
+// wgPortAttempts polls without one
+func (p *Profile) waitWgConnect() (err error) {
+	attempts := 30
+	if len(p.wgPorts) > 1 {
+		attempts = wgPortAttempts * len(p.wgPorts)
+	}
+
+	for i := 0; i < attempts; i++ {
+		if p.stop {
+			return
+		}
+
+		if i != 0 && i%wgPortAttempts == 0 && len(p.wgPorts) > 1 {
+			p.nextWgPort()
+		}
+
+		if i%10 == 0 {
+			sendWgPing(p)
+		}
+
+		err = checkWgHandshake(p)
+		if err != nil {
+			return
+		}
+
+		if p.stop || p.wgHandshake != 0 {
+			return
+		}
+
+		time.Sleep(wgHandshakeInterval)
+	}
+
+	return
+}
+
+func (p *Profile) storeWgPort() {
+	if p.wgConf == nil || len(p.WgPorts) == 0 {
+		return
+	}
+
+	wgPortCache.Lock()
+	wgPortCache.m[p.Id] = p.wgConf.Port
+	wgPortCache.Unlock()
+}
@@ -0,0 +1,163 @@
+package profile
+
+import (
+	"testing"
+)
+
+type fakeWgPeer struct {
+	open      int
+	checks    int
+	endpoints []int
+}
+
+func setFakeWgPeer(t *testing.T, peer *fakeWgPeer) {
+	origInterval := wgHandshakeInterval
+	origCheck := checkWgHandshake
+	origEndpoint := setWgEndpoint
+	origPing := sendWgPing
+
+	wgHandshakeInterval = 0
+	checkWgHandshake = func(p *Profile) (err error) {
+		peer.checks += 1
+		if p.wgConf.Port == peer.open {
+			p.wgHandshake = 1
+		}
+		return
+	}
+	setWgEndpoint = func(p *Profile, data *WgConf, port int) (err error) {
+		peer.endpoints = append(peer.endpoints, port)
+		return
+	}
+	sendWgPing = func(p *Profile) {}
+
+	wgPortCache.Lock()
+	wgPortCache.m = map[string]int{}
+	wgPortCache.Unlock()
+
+	t.Cleanup(func() {
+		wgHandshakeInterval = origInterval
+		checkWgHandshake = origCheck
+		setWgEndpoint = origEndpoint
+		sendWgPing = origPing
+
+		wgPortCache.Lock()
+		wgPortCache.m = map[string]int{}
+		wgPortCache.Unlock()
+	})
+}
+
+func TestWgPortCandidates(t *testing.T) {
+	ports := wgPortCandidates(51820, []int{443, 51820, 0, 70000, 53}, 0)
+	if !intsEqual(ports, []int{51820, 443, 53}) {
+		t.Errorf("unexpected candidates %v", ports)
+	}
+
+	ports = wgPortCandidates(51820, []int{443, 53}, 53)
+	if !intsEqual(ports, []int{53, 51820, 443}) {
+		t.Errorf("cached port not tried first %v", ports)
+	}
+
+	ports = wgPortCandidates(51820, []int{443}, 8080)
+	if !intsEqual(ports, []int{51820, 443}) {
+		t.Errorf("stale cached port used %v", ports)
+	}
+}
+
+func TestWgPortFallback(t *testing.T) {
+	peer := &fakeWgPeer{
+		open: 443,
+	}
+	setFakeWgPeer(t, peer)
+
+	prfl := &Profile{
+		Id:      "prfl0",
+		WgPorts: []int{443, 53},
+	}
+	prfl.wgConf = testWgConf()
+	prfl.loadWgPorts(prfl.wgConf)
+
+	err := prfl.waitWgConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prfl.wgHandshake == 0 {
+		t.Fatal("handshake not detected")
+	}
+	if prfl.wgConf.Port != 443 {
+		t.Errorf("expected port 443, got %d", prfl.wgConf.Port)
+	}
+	if !intsEqual(peer.endpoints, []int{443}) {
+		t.Errorf("unexpected endpoint changes %v", peer.endpoints)
+	}
+	if peer.checks != wgPortAttempts+1 {
+		t.Errorf("unexpected handshake checks %d", peer.checks)
+	}
+
+	prfl.storeWgPort()
+
+	// Reconnect in the same session starts on the working port
+	peer.checks = 0
+	peer.endpoints = nil
+	prfl2 := &Profile{
+		Id:      "prfl0",
+		WgPorts: []int{443, 53},
+	}
+	prfl2.wgConf = testWgConf()
+	prfl2.loadWgPorts(prfl2.wgConf)
+
+	if prfl2.wgConf.Port != 443 {
+		t.Errorf("cached port not used, got %d", prfl2.wgConf.Port)
+	}
+
+	err = prfl2.waitWgConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.checks != 1 || len(peer.endpoints) != 0 {
+		t.Errorf("unexpected port search with cached port %d %v",
+			peer.checks, peer.endpoints)
+	}
+}
+
+func TestWgPortExhausted(t *testing.T) {
+	peer := &fakeWgPeer{
+		open: 8443,
+	}
+	setFakeWgPeer(t, peer)
+
+	prfl := &Profile{
+		Id:      "prfl0",
+		WgPorts: []int{443, 53},
+	}
+	prfl.wgConf = testWgConf()
+	prfl.loadWgPorts(prfl.wgConf)
+
+	err := prfl.waitWgConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prfl.wgHandshake != 0 {
+		t.Error("unexpected handshake")
+	}
+	if !intsEqual(peer.endpoints, []int{443, 53}) {
+		t.Errorf("unexpected endpoint changes %v", peer.endpoints)
+	}
+	if peer.checks != wgPortAttempts*3 {
+		t.Errorf("unexpected handshake checks %d", peer.checks)
+	}
+
+}
+
+func intsEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
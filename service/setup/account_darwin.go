@@ -0,0 +1,13 @@
+package setup
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func AccountExists(account string) (err error) {
+	err = &errortypes.UnknownError{
+		errors.New("setup: Service account not supported on platform"),
+	}
+	return
+}
@@ -0,0 +1,20 @@
+package setup
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+func AccountExists(account string) (err error) {
+	_, _, _, err = windows.LookupSID("", account)
+	if err != nil {
+		err = &errortypes.NotFoundError{
+			errors.Wrapf(err, "setup: Service account '%s' not found",
+				account),
+		}
+		return
+	}
+
+	return
+}
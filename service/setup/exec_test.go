@@ -0,0 +1,137 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeResult struct {
+	output string
+	code   int
+}
+
+type fakeExitError struct {
+	code int
+}
+
+func (e *fakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e *fakeExitError) ExitCode() int {
+	return e.code
+}
+
+type fakeExec struct {
+	lock    sync.Mutex
+	calls   []string
+	handler func(call string) fakeResult
+}
+
+func (f *fakeExec) Calls() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+func setFakeExec(t *testing.T,
+	handler func(call string) fakeResult) (fake *fakeExec) {
+
+	fake = &fakeExec{
+		handler: handler,
+	}
+
+	orig := runCommand
+	runCommand = func(ctx context.Context, cmd *exec.Cmd) (err error) {
+		args := append([]string{filepath.Base(cmd.Path)}, cmd.Args[1:]...)
+		call := strings.Join(args, " ")
+
+		fake.lock.Lock()
+		fake.calls = append(fake.calls, call)
+		fake.lock.Unlock()
+
+		result := handler(call)
+		if cmd.Stdout != nil {
+			_, _ = cmd.Stdout.Write([]byte(result.output))
+		}
+		if result.code != 0 {
+			err = &fakeExitError{
+				code: result.code,
+			}
+		}
+
+		return
+	}
+
+	t.Cleanup(func() {
+		runCommand = orig
+	})
+
+	return
+}
+
+func scState(state int) fakeResult {
+	return fakeResult{
+		output: fmt.Sprintf("SERVICE_NAME: pritunl\n"+
+			"        TYPE               : 10  WIN32_OWN_PROCESS\n"+
+			"        STATE              : %d  STATE\n", state),
+	}
+}
+
+func TestServiceExists(t *testing.T) {
+	setFakeExec(t, func(call string) fakeResult {
+		if call == "sc.exe query pritunl" {
+			return scState(ServiceRunning)
+		}
+		return fakeResult{
+			output: "The specified service does not exist",
+			code:   scServiceNotExist,
+		}
+	})
+
+	exists, err := ServiceExists("pritunl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("expected service to exist")
+	}
+
+	exists, err = ServiceExists("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("expected service to not exist")
+	}
+}
+
+func TestScExecIgnores(t *testing.T) {
+	fake := setFakeExec(t, func(call string) fakeResult {
+		return fakeResult{
+			code: scServiceNotRunning,
+		}
+	})
+
+	err := ScExec([]int{scServiceNotRunning}, "stop", "pritunl")
+	if err != nil {
+		t.Errorf("ignored exit code returned error: %s", err)
+	}
+
+	err = ScExec(nil, "stop", "pritunl")
+	if err == nil {
+		t.Error("expected error for exit code")
+	} else if !strings.Contains(err.Error(), "(1062)") {
+		t.Errorf("exit code missing from error: %s", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 2 || calls[0] != "sc.exe stop pritunl" {
+		t.Errorf("unexpected calls %v", calls)
+	}
+}
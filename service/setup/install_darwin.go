@@ -0,0 +1,57 @@
+package setup
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	launchdService = "com.pritunl.service"
+	launchdPath    = "/Library/LaunchDaemons/com.pritunl.service.plist"
+)
+
+type darwinInstaller struct {
+	account string
+}
+
+func (d *darwinInstaller) Install() (err error) {
+	if d.account != "" {
+		err = AccountExists(d.account)
+		if err != nil {
+			return
+		}
+	}
+
+	_, _ = ExecCombinedOutput("", "launchctl",
+		"enable", "system/"+launchdService)
+
+	output, err := ExecCombinedOutput("", "launchctl", "load", launchdPath)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "setup: Failed to load launchd service: %s",
+				output),
+		}
+		return
+	}
+
+	return
+}
+
+func (d *darwinInstaller) Uninstall() (err error) {
+	output, err := ExecCombinedOutput("", "launchctl", "unload", launchdPath)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "setup: Failed to unload launchd service: %s",
+				output),
+		}
+		return
+	}
+
+	return
+}
+
+func newInstaller(account, password string) Installer {
+	return &darwinInstaller{
+		account: account,
+	}
+}
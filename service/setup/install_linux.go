@@ -0,0 +1,77 @@
+package setup
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	systemdService = "pritunl-client"
+)
+
+type linuxInstaller struct {
+	account string
+}
+
+func (l *linuxInstaller) systemctl(arg ...string) (err error) {
+	output, err := ExecCombinedOutput("", "systemctl", arg...)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "setup: Failed to exec 'systemctl %s': %s",
+				arg[0], output),
+		}
+		return
+	}
+
+	return
+}
+
+func (l *linuxInstaller) Install() (err error) {
+	if l.account != "" {
+		err = AccountExists(l.account)
+		if err != nil {
+			return
+		}
+	}
+
+	err = l.systemctl("daemon-reload")
+	if err != nil {
+		return
+	}
+
+	err = l.systemctl("enable", systemdService)
+	if err != nil {
+		return
+	}
+
+	err = l.systemctl("restart", systemdService)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (l *linuxInstaller) Uninstall() (err error) {
+	errs := []error{}
+
+	err = l.systemctl("stop", systemdService)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = l.systemctl("disable", systemdService)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = joinErrors(errs)
+
+	return
+}
+
+func newInstaller(account, password string) Installer {
+	return &linuxInstaller{
+		account: account,
+	}
+}
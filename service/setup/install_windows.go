@@ -0,0 +1,172 @@
+package setup
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	serviceName        = "pritunl"
+	serviceDisplayName = "Pritunl Client Helper Service"
+	tunTapRetries      = 3
+)
+
+func serviceArgs(action, binPath, account, password string) (
+	args []string) {
+
+	args = []string{
+		action, serviceName,
+		"start=auto",
+		"displayname=" + serviceDisplayName,
+		fmt.Sprintf(`binpath="%s"`, binPath),
+	}
+
+	if account != "" {
+		args = append(args, "obj="+account)
+		if password != "" {
+			args = append(args, "password="+password)
+		}
+	}
+
+	return
+}
+
+type windowsInstaller struct {
+	account  string
+	password string
+}
+
+func (w *windowsInstaller) Install() (err error) {
+	binPath := filepath.Join(RootDir(), "pritunl-service.exe")
+	errs := []error{}
+
+	binExists, err := utils.ExistsFile(binPath)
+	if err != nil {
+		return
+	}
+
+	if !binExists {
+		err = &errortypes.NotFoundError{
+			errors.Newf("setup: Service binary not found at %s", binPath),
+		}
+		return
+	}
+
+	if w.account != "" {
+		err = AccountExists(w.account)
+		if err != nil {
+			return
+		}
+	}
+
+	exists, err := ServiceExists(serviceName)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = ScExec([]int{scServiceNotRunning}, "stop", serviceName)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		err = waitForServiceState(
+			serviceName, ServiceStopped, ServiceTimeout)
+		if err != nil {
+			errs = append(errs, err)
+			err = joinErrors(errs)
+			return
+		}
+	}
+
+	err = TunTapInstallRetry(tunTapRetries)
+	if err != nil {
+		errs = append(errs, err)
+		err = joinErrors(errs)
+		return
+	}
+
+	err = TunTapClean()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	action := "create"
+	if exists {
+		action = "config"
+	}
+
+	err = ScExec(nil, serviceArgs(
+		action,
+		binPath,
+		w.account,
+		w.password,
+	)...)
+	if err != nil {
+		errs = append(errs, err)
+		err = joinErrors(errs)
+		return
+	}
+
+	err = ScExec(nil, "start", serviceName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = joinErrors(errs)
+
+	return
+}
+
+func (w *windowsInstaller) Uninstall() (err error) {
+	errs := []error{}
+
+	exists, err := ServiceExists(serviceName)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = ScExec(
+			[]int{scServiceNotExist, scServiceNotRunning},
+			"stop", serviceName,
+		)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			err = waitForServiceState(
+				serviceName, ServiceStopped, ServiceTimeout)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		err = ScExec(
+			[]int{scServiceNotExist, scServiceDeleted},
+			"delete", serviceName,
+		)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err = TunTapUninstall()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = joinErrors(errs)
+
+	return
+}
+
+func newInstaller(account, password string) Installer {
+	return &windowsInstaller{
+		account:  account,
+		password: password,
+	}
+}
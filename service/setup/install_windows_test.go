@@ -0,0 +1,191 @@
+package setup
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func TestServiceArgsDefault(t *testing.T) {
+	args := strings.Join(serviceArgs(
+		"create", `C:\Pritunl\pritunl-service.exe`, "", "secret"), " ")
+
+	expected := `create pritunl start=auto ` +
+		`displayname=Pritunl Client Helper Service ` +
+		`binpath="C:\Pritunl\pritunl-service.exe"`
+	if args != expected {
+		t.Errorf("unexpected default args %q", args)
+	}
+}
+
+func TestServiceArgsAccount(t *testing.T) {
+	args := serviceArgs("config", `C:\Pritunl\pritunl-service.exe`,
+		`CORP\svc-vpn`, "secret")
+
+	if len(args) < 2 || args[len(args)-2] != `obj=CORP\svc-vpn` {
+		t.Errorf("missing account arg in %q", args)
+	}
+	if args[len(args)-1] != "password=secret" {
+		t.Errorf("missing password arg in %q", args)
+	}
+}
+
+func TestServiceArgsAccountNoPassword(t *testing.T) {
+	args := serviceArgs("create", `C:\Pritunl\pritunl-service.exe`,
+		"NT AUTHORITY\\LocalService", "")
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "password=") {
+			t.Errorf("unexpected password arg in %q", args)
+		}
+	}
+	if args[len(args)-1] != "obj=NT AUTHORITY\\LocalService" {
+		t.Errorf("missing account arg in %q", args)
+	}
+}
+
+func setInstallRoot(t *testing.T, binary bool) {
+	dir := t.TempDir()
+	if binary {
+		err := ioutil.WriteFile(
+			filepath.Join(dir, "pritunl-service.exe"), []byte{}, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orig := rootDirPath
+	rootDirPath = dir
+	t.Cleanup(func() {
+		rootDirPath = orig
+	})
+}
+
+func callActions(calls []string) (actions []string) {
+	actions = []string{}
+	for _, call := range calls {
+		fields := strings.Fields(call)
+		if len(fields) > 2 {
+			fields = fields[:2]
+		}
+		actions = append(actions, strings.Join(fields, " "))
+	}
+	return
+}
+
+func TestInstallFresh(t *testing.T) {
+	setInstallRoot(t, true)
+
+	fake := setFakeExec(t, func(call string) fakeResult {
+		if call == "sc.exe query pritunl" {
+			return fakeResult{
+				code: scServiceNotExist,
+			}
+		}
+		return fakeResult{}
+	})
+
+	err := Default().Install()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"sc.exe query",
+		"pnputil.exe -a",
+		"tapctl.exe list",
+		"sc.exe create",
+		"sc.exe start",
+	}
+	actions := callActions(fake.Calls())
+	if strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected install sequence %v", actions)
+	}
+}
+
+func TestInstallUpgrade(t *testing.T) {
+	setInstallRoot(t, true)
+
+	queries := 0
+	fake := setFakeExec(t, func(call string) fakeResult {
+		if call == "sc.exe query pritunl" {
+			queries += 1
+			if queries == 1 {
+				return scState(ServiceRunning)
+			}
+			return scState(ServiceStopped)
+		}
+		return fakeResult{}
+	})
+
+	err := Default().Install()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"sc.exe query",
+		"sc.exe stop",
+		"sc.exe query",
+		"pnputil.exe -a",
+		"tapctl.exe list",
+		"sc.exe config",
+		"sc.exe start",
+	}
+	actions := callActions(fake.Calls())
+	if strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected upgrade sequence %v", actions)
+	}
+}
+
+func TestInstallErrors(t *testing.T) {
+	setInstallRoot(t, true)
+
+	setFakeExec(t, func(call string) fakeResult {
+		if call == "sc.exe query pritunl" {
+			return fakeResult{
+				code: scServiceNotExist,
+			}
+		}
+		if strings.HasPrefix(call, "sc.exe start") {
+			return fakeResult{
+				code: 1053,
+			}
+		}
+		return fakeResult{}
+	})
+
+	err := Default().Install()
+	if err == nil {
+		t.Fatal("expected install error")
+	}
+	if !strings.Contains(err.Error(), "(1053)") {
+		t.Errorf("exit code missing from error: %s", err)
+	}
+}
+
+func TestInstallMissingBinary(t *testing.T) {
+	setInstallRoot(t, false)
+
+	fake := setFakeExec(t, func(call string) fakeResult {
+		return fakeResult{}
+	})
+
+	err := Default().Install()
+	if err == nil {
+		t.Fatal("expected error for missing service binary")
+	}
+	if _, ok := err.(*errortypes.NotFoundError); !ok {
+		t.Errorf("unexpected error type %T", err)
+	}
+	if !strings.Contains(err.Error(), "Service binary not found at") {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("unexpected commands before binary check %v", calls)
+	}
+}
@@ -0,0 +1,64 @@
+package setup
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const PasswordEnv = "PRITUNL_SERVICE_PASSWORD"
+
+type Installer interface {
+	Install() error
+	Uninstall() error
+}
+
+func Default() Installer {
+	return newInstaller("", "")
+}
+
+func Install() (err error) {
+	err = Default().Install()
+	return
+}
+
+func InstallWithAccount(account, password string) (err error) {
+	err = newInstaller(account, password).Install()
+	return
+}
+
+func Uninstall() (err error) {
+	err = Default().Uninstall()
+	return
+}
+
+// Service account password is read from the environment or the first line
+// of stdin to keep it out of the process arguments, the environment
+// variable is cleared after it is read
+func ReadPassword(stdin io.Reader, useStdin bool) (
+	password string, err error) {
+
+	password = os.Getenv(PasswordEnv)
+	_ = os.Unsetenv(PasswordEnv)
+
+	if !useStdin {
+		return
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "setup: Failed to read password from stdin"),
+		}
+		return
+	}
+	err = nil
+
+	password = strings.TrimRight(line, "\r\n")
+
+	return
+}
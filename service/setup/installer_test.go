@@ -0,0 +1,48 @@
+package setup
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadPasswordEnv(t *testing.T) {
+	t.Setenv(PasswordEnv, "env-secret")
+
+	password, err := ReadPassword(strings.NewReader(""), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "env-secret" {
+		t.Errorf("unexpected password %q", password)
+	}
+
+	if _, ok := os.LookupEnv(PasswordEnv); ok {
+		t.Error("password environment variable not cleared")
+	}
+}
+
+func TestReadPasswordStdin(t *testing.T) {
+	t.Setenv(PasswordEnv, "")
+
+	password, err := ReadPassword(
+		strings.NewReader("stdin-secret\r\nignored\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "stdin-secret" {
+		t.Errorf("unexpected password %q", password)
+	}
+}
+
+func TestReadPasswordNone(t *testing.T) {
+	t.Setenv(PasswordEnv, "")
+
+	password, err := ReadPassword(strings.NewReader("unused\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "" {
+		t.Errorf("unexpected password %q", password)
+	}
+}
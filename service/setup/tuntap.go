@@ -1,12 +1,30 @@
 package setup
 
 import (
+	"context"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/command"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	tunTapNoSignature            = 0x800B0100
+	tunTapUntrustedRoot          = 0x800B0109
+	tunTapAuthenticodeDisallowed = 0x800F0240
+	tunTapTrustNotEstablished    = 0x800F0242
+	tunTapPublisherNotTrusted    = 0x800F0243
+	tunTapCancelled              = 0x800704C7
+	tunTapCancelledCode          = 1223
+)
+
+var (
+	TunTapRetryDelay = 2 * time.Second
 )
 
 func TunTapPath() string {
@@ -46,18 +64,39 @@ func TunTapGet() (adpaters []string, err error) {
 	return
 }
 
+func tunTapInstallFatal(code int) bool {
+	if code < 0 {
+		return true
+	}
+
+	switch uint32(code) {
+	case tunTapNoSignature, tunTapUntrustedRoot,
+		tunTapAuthenticodeDisallowed, tunTapTrustNotEstablished,
+		tunTapPublisherNotTrusted, tunTapCancelled, tunTapCancelledCode:
+
+		return true
+	}
+
+	return false
+}
+
 func TunTapInstall() (err error) {
-	cmd := command.Command(
+	cmd := command.New(
 		"pnputil.exe",
 		"-a", "oemvista.inf",
 		"-i",
 	)
 	cmd.Dir = TunTapPath()
 
-	err = cmd.Run()
+	if dryRun(cmd) {
+		return
+	}
+
+	err = runCommand(context.Background(), cmd.Cmd)
 	if err != nil {
 		err = &errortypes.ExecError{
-			errors.Wrap(err, "setup: Driver setup error"),
+			errors.Wrapf(err, "setup: Driver setup error (%d)",
+				exitCode(err)),
 		}
 		return
 	}
@@ -65,6 +104,30 @@ func TunTapInstall() (err error) {
 	return
 }
 
+func TunTapInstallRetry(retries int) (err error) {
+	delay := TunTapRetryDelay
+
+	for i := 0; ; i++ {
+		err = TunTapInstall()
+		if err == nil {
+			return
+		}
+
+		code := exitCode(errors.RootError(err))
+		if i+1 >= retries || tunTapInstallFatal(code) {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"exit_code": code,
+			"delay":     delay.String(),
+		}).Warn("setup: Driver setup failed, retrying")
+
+		sleep(delay)
+		delay *= 2
+	}
+}
+
 func TunTapClean() (err error) {
 	adapters, err := TunTapGet()
 	if err != nil {
@@ -72,17 +135,22 @@ func TunTapClean() (err error) {
 	}
 
 	for _, adapter := range adapters {
-		cmd := command.Command(
+		cmd := command.New(
 			TapCtlPath(),
 			"delete",
 			adapter,
 		)
 		cmd.Dir = TunTapPath()
 
-		err = cmd.Run()
+		if dryRun(cmd) {
+			continue
+		}
+
+		err = runCommand(context.Background(), cmd.Cmd)
 		if err != nil {
 			err = &errortypes.ExecError{
-				errors.Wrap(err, "setup: Driver removal error"),
+				errors.Wrapf(err, "setup: Driver removal error (%d)",
+					exitCode(err)),
 			}
 			return
 		}
@@ -90,3 +158,35 @@ func TunTapClean() (err error) {
 
 	return
 }
+
+func TunTapUninstall() (err error) {
+	exists, err := utils.ExistsFile(TapCtlPath())
+	if err != nil || !exists {
+		return
+	}
+
+	adapters, err := TunTapGet()
+	if err != nil {
+		return
+	}
+
+	errs := []error{}
+	for _, adapter := range adapters {
+		output, e := ExecCombinedOutput(
+			TunTapPath(),
+			TapCtlPath(),
+			"delete",
+			adapter,
+		)
+		if e != nil && !strings.Contains(output, "No devices") {
+			errs = append(errs, &errortypes.ExecError{
+				errors.Wrapf(e, "setup: Driver removal error (%d)",
+					exitCode(e)),
+			})
+		}
+	}
+
+	err = joinErrors(errs)
+
+	return
+}
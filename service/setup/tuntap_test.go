@@ -0,0 +1,100 @@
+package setup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const tunTapBusy = 0x800F0203
+
+func setFakeSleep(t *testing.T) (delays *[]time.Duration) {
+	delays = &[]time.Duration{}
+
+	orig := sleep
+	sleep = func(delay time.Duration) {
+		*delays = append(*delays, delay)
+	}
+
+	t.Cleanup(func() {
+		sleep = orig
+	})
+
+	return
+}
+
+func TestTunTapInstallRetry(t *testing.T) {
+	delays := setFakeSleep(t)
+	fake := setFakeExec(t, func(call string) fakeResult {
+		return fakeResult{
+			code: tunTapBusy,
+		}
+	})
+
+	err := TunTapInstallRetry(3)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := err.(*errortypes.ExecError); !ok {
+		t.Errorf("unexpected error type %T", err)
+	}
+
+	if len(fake.Calls()) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(fake.Calls()))
+	}
+
+	expected := []time.Duration{2 * time.Second, 4 * time.Second}
+	if len(*delays) != len(expected) {
+		t.Fatalf("unexpected delays %v", *delays)
+	}
+	for i, delay := range expected {
+		if (*delays)[i] != delay {
+			t.Errorf("delay %d expected %s got %s", i, delay, (*delays)[i])
+		}
+	}
+}
+
+func TestTunTapInstallRetrySuccess(t *testing.T) {
+	delays := setFakeSleep(t)
+
+	attempts := 0
+	fake := setFakeExec(t, func(call string) fakeResult {
+		attempts += 1
+		if attempts < 2 {
+			return fakeResult{
+				code: tunTapBusy,
+			}
+		}
+		return fakeResult{}
+	})
+
+	err := TunTapInstallRetry(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Calls()) != 2 || len(*delays) != 1 {
+		t.Errorf("unexpected attempts %d delays %v",
+			len(fake.Calls()), *delays)
+	}
+}
+
+func TestTunTapInstallRetryFatal(t *testing.T) {
+	delays := setFakeSleep(t)
+	fake := setFakeExec(t, func(call string) fakeResult {
+		return fakeResult{
+			code: tunTapCancelledCode,
+		}
+	})
+
+	err := TunTapInstallRetry(3)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if len(fake.Calls()) != 1 || len(*delays) != 0 {
+		t.Errorf("fatal error retried, attempts %d delays %v",
+			len(fake.Calls()), *delays)
+	}
+}
@@ -1,24 +0,0 @@
-package setup
-
-import (
-	"fmt"
-	"os"
-
-	"github.com/pritunl/pritunl-client-electron/service/command"
-)
-
-func Uninstall() {
-	cmd := command.Command("sc.exe", "stop", "pritunl")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Run()
-	cmd = command.Command("sc.exe", "delete", "pritunl")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Run()
-
-	err := TunTapClean()
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-}
@@ -1,13 +1,50 @@
 package setup
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	scServiceNotExist   = 1060
+	scServiceNotRunning = 1062
+	scServiceDeleted    = 1072
+)
+
+const (
+	ServiceStopped      = 1
+	ServiceStartPending = 2
+	ServiceStopPending  = 3
+	ServiceRunning      = 4
+)
+
+var (
+	ServiceTimeout = 30 * time.Second
+	CommandTimeout = 60 * time.Second
+	DryRun         = false
+)
+
+var (
+	rootDirPath = ""
+	runCommand  = command.Run
+	sleep       = time.Sleep
 )
 
 func RootDir() string {
+	if rootDirPath != "" {
+		return rootDirPath
+	}
+
 	rootDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
 	if err != nil {
 		panic(err)
@@ -16,15 +53,234 @@ func RootDir() string {
 }
 
 func ExecOutput(dir, name string, arg ...string) (output string, err error) {
+	buf := &bytes.Buffer{}
+
 	cmd := command.Command(name, arg...)
 	cmd.Dir = dir
+	cmd.Stdout = buf
+	cmd.Stderr = os.Stderr
+
+	err = runCommand(context.Background(), cmd)
+	if err != nil {
+		return
+	}
+	output = buf.String()
+
+	return
+}
+
+func ExecCombinedOutput(dir, name string, arg ...string) (
+	output string, err error) {
+
+	buf := &bytes.Buffer{}
+
+	cmd := command.New(name, arg...)
+	cmd.Dir = dir
+	cmd.Stdout = buf
+	cmd.Stderr = buf
+
+	if dryRun(cmd) {
+		return
+	}
+
+	err = runCommand(context.Background(), cmd.Cmd)
+	output = buf.String()
+
+	return
+}
+
+func dryRun(cmd *command.Cmd) bool {
+	if !DryRun {
+		return false
+	}
+
+	fmt.Println("setup: Dry run:", cmd.String())
+
+	return true
+}
+
+func exitCode(err error) int {
+	if exitErr, ok := err.(interface{ ExitCode() int }); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+func ScExec(ignores []int, arg ...string) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	cmd := &command.Cmd{
+		Cmd: command.CommandContext(ctx, "sc.exe", arg...),
+	}
+	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	outputByt, err := cmd.Output()
+	for i, a := range arg {
+		if strings.HasPrefix(a, "password=") {
+			cmd.Sensitive(i)
+		}
+	}
+
+	if dryRun(cmd) {
+		return
+	}
+
+	err = runCommand(ctx, cmd.Cmd)
+	if err != nil {
+		code := exitCode(err)
+		for _, ignore := range ignores {
+			if code == ignore {
+				err = nil
+				return
+			}
+		}
+
+		action := ""
+		if len(arg) > 0 {
+			action = arg[0]
+		}
+
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "setup: Failed to exec 'sc.exe %s' (%d)",
+				action, code),
+		}
+		return
+	}
+
+	return
+}
+
+func scQuery(ctx context.Context, name string) (output string, err error) {
+	buf := &bytes.Buffer{}
+
+	cmd := command.CommandContext(ctx, "sc.exe", "query", name)
+	cmd.Stdout = buf
+	cmd.Stderr = buf
+
+	err = runCommand(ctx, cmd)
+	output = buf.String()
+
+	return
+}
+
+func ServiceExists(name string) (exists bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	output, err := scQuery(ctx, name)
+	if err != nil {
+		if exitCode(err) == scServiceNotExist {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "setup: Failed to query service (%d): %s",
+				exitCode(err), strings.TrimSpace(output)),
+		}
+		return
+	}
+
+	exists = true
+
+	return
+}
+
+func ServiceState(name string) (state int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	output, err := scQuery(ctx, name)
 	if err != nil {
+		if exitCode(err) == scServiceNotExist {
+			err = nil
+			state = ServiceStopped
+			return
+		}
+
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "setup: Failed to query service (%d): %s",
+				exitCode(err), strings.TrimSpace(output)),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "STATE" {
+			continue
+		}
+
+		state, err = strconv.Atoi(fields[2])
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "setup: Failed to parse service state"),
+			}
+			return
+		}
+
 		return
 	}
-	output = string(outputByt)
+
+	err = &errortypes.ParseError{
+		errors.New("setup: Failed to find service state"),
+	}
+
+	return
+}
+
+func waitForServiceState(name string, state int,
+	timeout time.Duration) (err error) {
+
+	if DryRun {
+		return
+	}
+
+	start := time.Now()
+
+	for {
+		curState, e := ServiceState(name)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if curState == state {
+			return
+		}
+
+		if time.Since(start) > timeout {
+			err = &errortypes.ExecError{
+				errors.Newf("setup: Timeout waiting for service state "+
+					"%d, last state %d", state, curState),
+			}
+			return
+		}
+
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+func joinErrors(errs []error) (err error) {
+	if len(errs) == 0 {
+		return
+	}
+
+	if len(errs) == 1 {
+		err = errs[0]
+		return
+	}
+
+	msgs := []string{}
+	for _, e := range errs {
+		msgs = append(msgs, errors.GetMessage(e))
+	}
+
+	err = &errortypes.ExecError{
+		errors.Wrapf(errs[0], "setup: Multiple errors: %s",
+			strings.Join(msgs, "; ")),
+	}
 
 	return
 }
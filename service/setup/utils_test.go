@@ -0,0 +1,60 @@
+package setup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitForServiceStopped(t *testing.T) {
+	states := []int{
+		ServiceStopPending,
+		ServiceStopPending,
+		ServiceStopped,
+	}
+
+	queries := 0
+	setFakeExec(t, func(call string) fakeResult {
+		state := states[len(states)-1]
+		if queries < len(states) {
+			state = states[queries]
+		}
+		queries += 1
+		return scState(state)
+	})
+
+	err := waitForServiceState("pritunl", ServiceStopped, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries != 3 {
+		t.Errorf("expected 3 state queries, got %d", queries)
+	}
+}
+
+func TestWaitForServiceTimeout(t *testing.T) {
+	setFakeExec(t, func(call string) fakeResult {
+		return scState(ServiceStopPending)
+	})
+
+	err := waitForServiceState("pritunl", ServiceStopped,
+		300*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
+func TestServiceStateMissing(t *testing.T) {
+	setFakeExec(t, func(call string) fakeResult {
+		return fakeResult{
+			code: scServiceNotExist,
+		}
+	})
+
+	state, err := ServiceState("pritunl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != ServiceStopped {
+		t.Errorf("missing service should report stopped, got %d", state)
+	}
+}
@@ -0,0 +1,229 @@
+package socket
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	socketGroup     = "pritunl"
+	consoleInterval = 10 * time.Second
+	groupCacheTtl   = 60 * time.Second
+)
+
+var (
+	consoleUidCur = -1
+	groupCache    = map[int]*groupMember{}
+	cacheLock     = sync.RWMutex{}
+)
+
+type groupMember struct {
+	member    bool
+	timestamp time.Time
+}
+
+type listener struct {
+	net.Listener
+}
+
+func (l *listener) Accept() (conn net.Conn, err error) {
+	for {
+		conn, err = l.Listener.Accept()
+		if err != nil {
+			return
+		}
+
+		uid, e := peerUid(conn)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": e,
+			}).Error("socket: Failed to get peer credentials")
+			_ = conn.Close()
+			continue
+		}
+
+		if !allowed(uid) {
+			logrus.WithFields(logrus.Fields{
+				"uid": uid,
+			}).Warn("socket: Rejected connection from unauthorized user")
+			_ = conn.Close()
+			continue
+		}
+
+		return
+	}
+}
+
+func lookupGroupMember(uid int) (member bool) {
+	grp, err := user.LookupGroup(socketGroup)
+	if err != nil {
+		return
+	}
+
+	usr, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return
+	}
+
+	if usr.Gid == grp.Gid {
+		member = true
+		return
+	}
+
+	groupIds, err := usr.GroupIds()
+	if err != nil {
+		return
+	}
+
+	for _, groupId := range groupIds {
+		if groupId == grp.Gid {
+			member = true
+			return
+		}
+	}
+
+	return
+}
+
+func groupAllowed(uid int) bool {
+	cacheLock.RLock()
+	cached := groupCache[uid]
+	cacheLock.RUnlock()
+
+	if cached != nil && time.Since(cached.timestamp) < groupCacheTtl {
+		return cached.member
+	}
+
+	member := lookupGroupMember(uid)
+
+	cacheLock.Lock()
+	groupCache[uid] = &groupMember{
+		member:    member,
+		timestamp: time.Now(),
+	}
+	cacheLock.Unlock()
+
+	return member
+}
+
+// Allow root, configured users, members of the pritunl group and the
+// current console user
+func allowed(uid int) bool {
+	if uid == 0 {
+		return true
+	}
+
+	for _, allowedUid := range config.Config.AllowedUids {
+		if uid == allowedUid {
+			return true
+		}
+	}
+
+	cacheLock.RLock()
+	consUid := consoleUidCur
+	cacheLock.RUnlock()
+
+	if consUid > 0 && uid == consUid {
+		return true
+	}
+
+	return groupAllowed(uid)
+}
+
+func updateConsole() {
+	uid, err := consoleUid()
+	if err != nil {
+		uid = -1
+	}
+
+	cacheLock.Lock()
+	consoleUidCur = uid
+	cacheLock.Unlock()
+}
+
+func watchConsole() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("socket: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		time.Sleep(consoleInterval)
+		updateConsole()
+	}
+}
+
+// Socket is owned by root and the pritunl group when available, access is
+// always verified with the peer credentials on accept
+func setPerms(pth string) (err error) {
+	mode := os.FileMode(0666)
+
+	grp, e := user.LookupGroup(socketGroup)
+	if e == nil {
+		gid, e := strconv.Atoi(grp.Gid)
+		if e == nil {
+			e = os.Chown(pth, 0, gid)
+			if e == nil {
+				mode = 0660
+			} else {
+				logrus.WithFields(logrus.Fields{
+					"group": socketGroup,
+					"error": e,
+				}).Error("socket: Failed to set socket group")
+			}
+		}
+	}
+
+	err = os.Chmod(pth, mode)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "socket: Failed to chmod unix socket"),
+		}
+		return
+	}
+
+	return
+}
+
+func Listen(pth string) (lstn net.Listener, err error) {
+	_ = os.Remove(pth)
+
+	unixLstn, err := net.Listen("unix", pth)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "socket: Failed to create unix socket"),
+		}
+		return
+	}
+
+	err = setPerms(pth)
+	if err != nil {
+		_ = unixLstn.Close()
+		return
+	}
+
+	updateConsole()
+	go watchConsole()
+
+	lstn = &listener{
+		Listener: unixLstn,
+	}
+
+	return
+}
@@ -0,0 +1,71 @@
+package socket
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+func peerUid(conn net.Conn) (uid int, err error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		err = &errortypes.ReadError{
+			errors.New("socket: Connection is not a unix socket"),
+		}
+		return
+	}
+
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "socket: Failed to get raw connection"),
+		}
+		return
+	}
+
+	var cred *unix.Xucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd),
+			unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "socket: Failed to get peer credentials"),
+		}
+		return
+	}
+
+	uid = int(cred.Uid)
+
+	return
+}
+
+func consoleUid() (uid int, err error) {
+	stat, err := os.Stat("/dev/console")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "socket: Failed to stat console"),
+		}
+		return
+	}
+
+	uid = fileUid(stat)
+
+	return
+}
+
+func fileUid(stat os.FileInfo) int {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1
+	}
+	return int(sys.Uid)
+}
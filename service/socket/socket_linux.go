@@ -0,0 +1,82 @@
+package socket
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"golang.org/x/sys/unix"
+)
+
+func peerUid(conn net.Conn) (uid int, err error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		err = &errortypes.ReadError{
+			errors.New("socket: Connection is not a unix socket"),
+		}
+		return
+	}
+
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "socket: Failed to get raw connection"),
+		}
+		return
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd),
+			unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "socket: Failed to get peer credentials"),
+		}
+		return
+	}
+
+	uid = int(cred.Uid)
+
+	return
+}
+
+func consoleUid() (uid int, err error) {
+	output, err := utils.ExecOutput("loginctl", "show-seat", "seat0",
+		"--property=ActiveSession", "--value")
+	if err != nil {
+		return
+	}
+
+	session := strings.TrimSpace(output)
+	if session == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("socket: Failed to find active session"),
+		}
+		return
+	}
+
+	output, err = utils.ExecOutput("loginctl", "show-session", session,
+		"--property=User", "--value")
+	if err != nil {
+		return
+	}
+
+	uid, err = strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "socket: Failed to parse session user"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,81 @@
+package socket
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"golang.org/x/sys/unix"
+)
+
+func socketPair(t *testing.T) (conn net.Conn) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := os.NewFile(uintptr(fds[0]), "socketpair")
+	defer file.Close()
+	t.Cleanup(func() {
+		_ = unix.Close(fds[1])
+	})
+
+	conn, err = net.FileConn(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return
+}
+
+func TestPeerUid(t *testing.T) {
+	conn := socketPair(t)
+
+	uid, err := peerUid(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uid != os.Getuid() {
+		t.Errorf("expected uid %d got %d", os.Getuid(), uid)
+	}
+}
+
+func TestAllowedMismatch(t *testing.T) {
+	origConf := config.Config
+	config.Config = &config.ConfigData{}
+	t.Cleanup(func() {
+		config.Config = origConf
+	})
+
+	cacheLock.Lock()
+	origConsole := consoleUidCur
+	consoleUidCur = 1000
+	cacheLock.Unlock()
+	t.Cleanup(func() {
+		cacheLock.Lock()
+		consoleUidCur = origConsole
+		cacheLock.Unlock()
+	})
+
+	peer := 4000123
+	if allowed(peer) {
+		t.Errorf("mismatched uid %d allowed", peer)
+	}
+
+	if !allowed(0) {
+		t.Error("root not allowed")
+	}
+	if !allowed(1000) {
+		t.Error("console user not allowed")
+	}
+
+	config.Config.AllowedUids = []int{peer}
+	if !allowed(peer) {
+		t.Errorf("configured uid %d not allowed", peer)
+	}
+}
@@ -0,0 +1,22 @@
+package socket
+
+import (
+	"net"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func peerUid(conn net.Conn) (uid int, err error) {
+	err = &errortypes.UnknownError{
+		errors.New("socket: Peer credentials not supported"),
+	}
+	return
+}
+
+func consoleUid() (uid int, err error) {
+	err = &errortypes.UnknownError{
+		errors.New("socket: Console user not supported"),
+	}
+	return
+}
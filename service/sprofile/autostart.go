@@ -0,0 +1,115 @@
+package sprofile
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	CredentialsEmbedded = "embedded"
+	CredentialsMachine  = "machine"
+)
+
+// Auto start profiles connect at boot before any user logs in, credentials
+// come from the key embedded in the profile or a machine certificate
+func (s *Sprofile) CredentialSource() string {
+	if s.CertPath != "" || s.KeyPath != "" {
+		return CredentialsMachine
+	}
+
+	if strings.Contains(s.OvpnData, "<cert>") &&
+		strings.Contains(s.OvpnData, "<key>") {
+
+		return CredentialsEmbedded
+	}
+
+	return ""
+}
+
+func (s *Sprofile) ValidateAutoStart() (err error) {
+	if !s.AutoStart {
+		return
+	}
+
+	if s.SsoAuth || s.Interactive() {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Auto start profile cannot require " +
+				"interactive authentication"),
+		}
+		return
+	}
+
+	switch s.CredentialSource() {
+	case CredentialsMachine:
+		if s.CertPath == "" || s.KeyPath == "" {
+			err = &errortypes.ParseError{
+				errors.New("sprofile: Machine certificate requires " +
+					"cert and key path"),
+			}
+			return
+		}
+
+		for _, pth := range []string{s.CertPath, s.KeyPath} {
+			exists, _ := utils.ExistsFile(pth)
+			if !exists {
+				err = &errortypes.NotFoundError{
+					errors.Newf("sprofile: Machine certificate file "+
+						"'%s' not found", pth),
+				}
+				return
+			}
+		}
+		break
+	case CredentialsEmbedded:
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Auto start profile requires an " +
+				"embedded key or machine certificate"),
+		}
+		return
+	}
+
+	return
+}
+
+func readPem(pth string) (data string, err error) {
+	dataByt, err := ioutil.ReadFile(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "sprofile: Failed to read '%s'", pth),
+		}
+		return
+	}
+
+	data = strings.TrimSpace(strings.ReplaceAll(string(dataByt), "\r", ""))
+	if !strings.HasPrefix(data, "-----BEGIN ") {
+		data = ""
+		err = &errortypes.ParseError{
+			errors.Newf("sprofile: Invalid PEM data in '%s'", pth),
+		}
+		return
+	}
+	data += "\n"
+
+	return
+}
+
+func (s *Sprofile) LoadMachineCert() (cert, key string, err error) {
+	cert, err = readPem(s.CertPath)
+	if err != nil {
+		return
+	}
+
+	key, err = readPem(s.KeyPath)
+	if err != nil {
+		cert = ""
+		return
+	}
+
+	return
+}
@@ -0,0 +1,259 @@
+package sprofile
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	serverTimeout = 12 * time.Second
+)
+
+func serverClient(pins []string) *http.Client {
+	tlsConf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		MaxVersion: tls.VersionTLS13,
+	}
+
+	if len(pins) > 0 {
+		tlsConf.VerifyPeerCertificate = func(rawCerts [][]byte,
+			_ [][]*x509.Certificate) error {
+
+			for _, rawCert := range rawCerts {
+				cert, err := x509.ParseCertificate(rawCert)
+				if err != nil {
+					continue
+				}
+
+				hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				pin := base64.StdEncoding.EncodeToString(hash[:])
+
+				for _, allowed := range pins {
+					allowed = strings.TrimPrefix(
+						strings.TrimSpace(allowed), "sha256/")
+					if subtle.ConstantTimeCompare(
+						[]byte(pin), []byte(allowed)) == 1 {
+
+						return nil
+					}
+				}
+			}
+
+			return &errortypes.RequestError{
+				errors.New("sprofile: Server certificate pin mismatch"),
+			}
+		}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig:     tlsConf,
+		},
+		Timeout: serverTimeout,
+	}
+}
+
+func ServerUrl(serverUrl, token string) (importUrl string, err error) {
+	serverUrl = strings.TrimSpace(serverUrl)
+	if !strings.Contains(serverUrl, "://") {
+		serverUrl = "https://" + serverUrl
+	}
+
+	u, err := url.Parse(serverUrl)
+	if err != nil || u.Host == "" {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Invalid server URL"),
+		}
+		return
+	}
+
+	if u.Scheme != "https" {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Server URL must use HTTPS"),
+		}
+		return
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" || strings.ContainsAny(token, "/?#") {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Invalid server token"),
+		}
+		return
+	}
+
+	importUrl = "https://" + u.Host + "/ku/" + url.PathEscape(token)
+
+	return
+}
+
+func fetchServer(importUrl string, pins []string) (
+	confs map[string]string, err error) {
+
+	req, err := http.NewRequest("GET", importUrl, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Server import request error"),
+		}
+		return
+	}
+
+	req.Header.Set("User-Agent", "pritunl")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := serverClient(pins).Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Server import connection error"),
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		err = &errortypes.NotFoundError{
+			errors.New("sprofile: Invalid or expired profile token"),
+		}
+		return
+	}
+
+	if res.StatusCode != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("sprofile: Bad status %d code from server",
+				res.StatusCode),
+		}
+		return
+	}
+
+	confs = map[string]string{}
+	err = json.NewDecoder(res.Body).Decode(&confs)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse server response"),
+		}
+		return
+	}
+
+	return
+}
+
+func SyncServer(importUrl string, pins []string) (
+	prfls []*Sprofile, err error) {
+
+	confs, err := fetchServer(importUrl, pins)
+	if err != nil {
+		return
+	}
+
+	existing := map[string]*Sprofile{}
+	allPrfls, err := GetAll()
+	if err != nil {
+		return
+	}
+	for _, prfl := range allPrfls {
+		if prfl.ImportUrl == importUrl {
+			existing[prfl.ImportName] = prfl
+		}
+	}
+
+	names := []string{}
+	for name := range confs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	prfls = []*Sprofile{}
+	for _, name := range names {
+		prfl, e := ParseImport([]byte(confs[name]))
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"name":  name,
+				"error": e,
+			}).Error("sprofile: Failed to parse server profile")
+			continue
+		}
+
+		prfl.ImportUrl = importUrl
+		prfl.ImportName = name
+		prfl.Stale = false
+
+		curPrfl := existing[name]
+		if curPrfl != nil {
+			prfl.Id = curPrfl.Id
+			prfl.Password = curPrfl.Password
+			prfl.LastMode = curPrfl.LastMode
+			prfl.Disabled = curPrfl.Disabled
+			delete(existing, name)
+		} else {
+			prfl.Id, err = NewId()
+			if err != nil {
+				return
+			}
+		}
+
+		err = prfl.Commit()
+		if err != nil {
+			return
+		}
+
+		prfls = append(prfls, prfl)
+	}
+
+	for _, prfl := range existing {
+		if prfl.Stale {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prfl.Id,
+			"name":       prfl.ImportName,
+		}).Warn("sprofile: Profile removed from server, marking stale")
+
+		prfl.Stale = true
+		err = prfl.Commit()
+		if err != nil {
+			return
+		}
+	}
+
+	err = Reload(false)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func ImportUrls() (importUrls []string) {
+	importUrls = []string{}
+
+	prfls, err := GetAll()
+	if err != nil {
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, prfl := range prfls {
+		if prfl.ImportUrl == "" || seen[prfl.ImportUrl] {
+			continue
+		}
+		seen[prfl.ImportUrl] = true
+		importUrls = append(importUrls, prfl.ImportUrl)
+	}
+
+	return
+}
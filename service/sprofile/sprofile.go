@@ -8,9 +8,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -18,7 +16,7 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
-	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/keystore"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
@@ -41,67 +39,98 @@ type SyncData struct {
 	Conf      string `json:"conf"`
 }
 
+type CustomRoute struct {
+	Network   string `json:"network"`
+	Metric    int    `json:"metric"`
+	ViaTunnel bool   `json:"via_tunnel"`
+}
+
 type Sprofile struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	State              bool     `json:"-"`
-	Wg                 bool     `json:"wg"`
-	LastMode           string   `json:"last_mode"`
-	OrganizationId     string   `json:"organization_id"`
-	Organization       string   `json:"organization"`
-	ServerId           string   `json:"server_id"`
-	Server             string   `json:"server"`
-	UserId             string   `json:"user_id"`
-	User               string   `json:"user"`
-	PreConnectMsg      string   `json:"pre_connect_msg"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DisableGateway     bool     `json:"disable_gateway"`
-	SsoAuth            bool     `json:"sso_auth"`
-	PasswordMode       string   `json:"password_mode"`
-	Token              bool     `json:"token"`
-	TokenTtl           int      `json:"token_ttl"`
-	Disabled           bool     `json:"disabled"`
-	SyncTime           int64    `json:"sync_time"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncHash           string   `json:"sync_hash"`
-	SyncSecret         string   `json:"sync_secret"`
-	SyncToken          string   `json:"sync_token"`
-	ServerPublicKey    []string `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	OvpnData           string   `json:"ovpn_data"`
-	Path               string   `json:"-"`
-	Password           string   `json:"password"`
-	AuthErrorCount     int      `json:"-"`
+	Id                 string         `json:"id"`
+	Name               string         `json:"name"`
+	State              bool           `json:"-"`
+	Wg                 bool           `json:"wg"`
+	LastMode           string         `json:"last_mode"`
+	OrganizationId     string         `json:"organization_id"`
+	Organization       string         `json:"organization"`
+	ServerId           string         `json:"server_id"`
+	Server             string         `json:"server"`
+	UserId             string         `json:"user_id"`
+	User               string         `json:"user"`
+	PreConnectMsg      string         `json:"pre_connect_msg"`
+	DynamicFirewall    bool           `json:"dynamic_firewall"`
+	DisableGateway     bool           `json:"disable_gateway"`
+	ExcludeRoutes      []string       `json:"exclude_routes"`
+	CustomRoutes       []*CustomRoute `json:"custom_routes"`
+	KillSwitch         bool           `json:"kill_switch"`
+	BlockIpv6          bool           `json:"block_ipv6"`
+	SsoAuth            bool           `json:"sso_auth"`
+	PasswordMode       string         `json:"password_mode"`
+	Token              bool           `json:"token"`
+	TokenTtl           int            `json:"token_ttl"`
+	Disabled           bool           `json:"disabled"`
+	SyncTime           int64          `json:"sync_time"`
+	SyncHosts          []string       `json:"sync_hosts"`
+	SyncHash           string         `json:"sync_hash"`
+	SyncSecret         string         `json:"sync_secret"`
+	SyncToken          string         `json:"sync_token"`
+	ImportUrl          string         `json:"import_url"`
+	ImportName         string         `json:"import_name"`
+	Stale              bool           `json:"stale"`
+	ServerPublicKey    []string       `json:"server_public_key"`
+	ServerBoxPublicKey string         `json:"server_box_public_key"`
+	OvpnData           string         `json:"ovpn_data"`
+	OnDemandDomains    []string       `json:"on_demand_domains"`
+	OnDemandCidrs      []string       `json:"on_demand_cidrs"`
+	AutoStart          bool           `json:"auto_start"`
+	CertPath           string         `json:"cert_path"`
+	KeyPath            string         `json:"key_path"`
+	Path               string         `json:"-"`
+	Password           string         `json:"password,omitempty"`
+	PasswordData       string         `json:"password_data,omitempty"`
+	AuthErrorCount     int            `json:"-"`
 }
 
 type SprofileClient struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	State              bool     `json:"state"`
-	Wg                 bool     `json:"wg"`
-	LastMode           string   `json:"last_mode"`
-	OrganizationId     string   `json:"organization_id"`
-	Organization       string   `json:"organization"`
-	ServerId           string   `json:"server_id"`
-	Server             string   `json:"server"`
-	UserId             string   `json:"user_id"`
-	User               string   `json:"user"`
-	PreConnectMsg      string   `json:"pre_connect_msg"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DisableGateway     bool     `json:"disable_Gateway"`
-	SsoAuth            bool     `json:"sso_auth"`
-	PasswordMode       string   `json:"password_mode"`
-	Token              bool     `json:"token"`
-	TokenTtl           int      `json:"token_ttl"`
-	Disabled           bool     `json:"disabled"`
-	SyncTime           int64    `json:"sync_time"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncHash           string   `json:"sync_hash"`
-	SyncSecret         string   `json:"sync_secret"`
-	SyncToken          string   `json:"sync_token"`
-	ServerPublicKey    []string `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	OvpnData           string   `json:"ovpn_data"`
+	Id                 string         `json:"id"`
+	Name               string         `json:"name"`
+	State              bool           `json:"state"`
+	Wg                 bool           `json:"wg"`
+	LastMode           string         `json:"last_mode"`
+	OrganizationId     string         `json:"organization_id"`
+	Organization       string         `json:"organization"`
+	ServerId           string         `json:"server_id"`
+	Server             string         `json:"server"`
+	UserId             string         `json:"user_id"`
+	User               string         `json:"user"`
+	PreConnectMsg      string         `json:"pre_connect_msg"`
+	DynamicFirewall    bool           `json:"dynamic_firewall"`
+	DisableGateway     bool           `json:"disable_Gateway"`
+	ExcludeRoutes      []string       `json:"exclude_routes"`
+	CustomRoutes       []*CustomRoute `json:"custom_routes"`
+	KillSwitch         bool           `json:"kill_switch"`
+	BlockIpv6          bool           `json:"block_ipv6"`
+	SsoAuth            bool           `json:"sso_auth"`
+	PasswordMode       string         `json:"password_mode"`
+	Token              bool           `json:"token"`
+	TokenTtl           int            `json:"token_ttl"`
+	Disabled           bool           `json:"disabled"`
+	SyncTime           int64          `json:"sync_time"`
+	SyncHosts          []string       `json:"sync_hosts"`
+	SyncHash           string         `json:"sync_hash"`
+	SyncSecret         string         `json:"sync_secret"`
+	SyncToken          string         `json:"sync_token"`
+	ImportUrl          string         `json:"import_url"`
+	ImportName         string         `json:"import_name"`
+	Stale              bool           `json:"stale"`
+	ServerPublicKey    []string       `json:"server_public_key"`
+	ServerBoxPublicKey string         `json:"server_box_public_key"`
+	OvpnData           string         `json:"ovpn_data"`
+	OnDemandDomains    []string       `json:"on_demand_domains"`
+	OnDemandCidrs      []string       `json:"on_demand_cidrs"`
+	AutoStart          bool           `json:"auto_start"`
+	CertPath           string         `json:"cert_path"`
+	KeyPath            string         `json:"key_path"`
 }
 
 func (s *Sprofile) BasePath() string {
@@ -109,6 +138,20 @@ func (s *Sprofile) BasePath() string {
 	return filepath.Join(prflsPath, s.Id)
 }
 
+func (s *Sprofile) Interactive() bool {
+	if s.PasswordMode == "" {
+		return false
+	}
+
+	for _, mode := range strings.Split(s.PasswordMode, "_") {
+		if mode == "otp" || mode == "pin" {
+			return true
+		}
+	}
+
+	return s.Password == ""
+}
+
 func (s *Sprofile) Client() (sprflc *SprofileClient) {
 	sprflc = &SprofileClient{
 		Id:                 s.Id,
@@ -125,6 +168,10 @@ func (s *Sprofile) Client() (sprflc *SprofileClient) {
 		PreConnectMsg:      s.PreConnectMsg,
 		DynamicFirewall:    s.DynamicFirewall,
 		DisableGateway:     s.DisableGateway,
+		ExcludeRoutes:      s.ExcludeRoutes,
+		CustomRoutes:       s.CustomRoutes,
+		KillSwitch:         s.KillSwitch,
+		BlockIpv6:          s.BlockIpv6,
 		SsoAuth:            s.SsoAuth,
 		PasswordMode:       s.PasswordMode,
 		Token:              s.Token,
@@ -135,9 +182,17 @@ func (s *Sprofile) Client() (sprflc *SprofileClient) {
 		SyncHash:           s.SyncHash,
 		SyncSecret:         s.SyncSecret,
 		SyncToken:          s.SyncToken,
+		ImportUrl:          s.ImportUrl,
+		ImportName:         s.ImportName,
+		Stale:              s.Stale,
 		ServerPublicKey:    s.ServerPublicKey,
 		ServerBoxPublicKey: s.ServerBoxPublicKey,
 		OvpnData:           s.OvpnData,
+		OnDemandDomains:    s.OnDemandDomains,
+		OnDemandCidrs:      s.OnDemandCidrs,
+		AutoStart:          s.AutoStart,
+		CertPath:           s.CertPath,
+		KeyPath:            s.KeyPath,
 	}
 
 	return
@@ -152,6 +207,23 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		}
 	}
 
+	var excludeRoutes []string
+	if s.ExcludeRoutes != nil {
+		excludeRoutes = []string{}
+		for _, route := range s.ExcludeRoutes {
+			excludeRoutes = append(excludeRoutes, route)
+		}
+	}
+
+	var customRoutes []*CustomRoute
+	if s.CustomRoutes != nil {
+		customRoutes = []*CustomRoute{}
+		for _, route := range s.CustomRoutes {
+			routeCopy := *route
+			customRoutes = append(customRoutes, &routeCopy)
+		}
+	}
+
 	var serverPublicKey []string
 	if s.ServerPublicKey != nil {
 		serverPublicKey = []string{}
@@ -160,6 +232,22 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		}
 	}
 
+	var onDemandDomains []string
+	if s.OnDemandDomains != nil {
+		onDemandDomains = []string{}
+		for _, domain := range s.OnDemandDomains {
+			onDemandDomains = append(onDemandDomains, domain)
+		}
+	}
+
+	var onDemandCidrs []string
+	if s.OnDemandCidrs != nil {
+		onDemandCidrs = []string{}
+		for _, cidr := range s.OnDemandCidrs {
+			onDemandCidrs = append(onDemandCidrs, cidr)
+		}
+	}
+
 	sprfl = &Sprofile{
 		Id:                 s.Id,
 		Name:               s.Name,
@@ -175,6 +263,10 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		PreConnectMsg:      s.PreConnectMsg,
 		DynamicFirewall:    s.DynamicFirewall,
 		DisableGateway:     s.DisableGateway,
+		ExcludeRoutes:      excludeRoutes,
+		CustomRoutes:       customRoutes,
+		KillSwitch:         s.KillSwitch,
+		BlockIpv6:          s.BlockIpv6,
 		SsoAuth:            s.SsoAuth,
 		PasswordMode:       s.PasswordMode,
 		Token:              s.Token,
@@ -185,11 +277,20 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		SyncHash:           s.SyncHash,
 		SyncSecret:         s.SyncSecret,
 		SyncToken:          s.SyncToken,
+		ImportUrl:          s.ImportUrl,
+		ImportName:         s.ImportName,
+		Stale:              s.Stale,
 		ServerPublicKey:    serverPublicKey,
 		ServerBoxPublicKey: s.ServerBoxPublicKey,
 		OvpnData:           s.OvpnData,
+		OnDemandDomains:    onDemandDomains,
+		OnDemandCidrs:      onDemandCidrs,
+		AutoStart:          s.AutoStart,
+		CertPath:           s.CertPath,
+		KeyPath:            s.KeyPath,
 		Path:               s.Path,
 		Password:           s.Password,
+		PasswordData:       s.PasswordData,
 		AuthErrorCount:     s.AuthErrorCount,
 	}
 
@@ -197,9 +298,7 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 }
 
 func (s *Sprofile) GetOutput() (data string, err error) {
-	logPth := s.BasePath() + ".log"
-
-	exists, err := utils.Exists(logPth)
+	exists, err := store.Exists(s.Id + ".log")
 	if err != nil {
 		err = &errortypes.ReadError{
 			errors.Wrap(err, "sprofile: Failed to check log file"),
@@ -208,11 +307,9 @@ func (s *Sprofile) GetOutput() (data string, err error) {
 	}
 
 	if exists {
-		dataByt, e := ioutil.ReadFile(logPth)
+		dataByt, e := store.Read(s.Id + ".log")
 		if e != nil {
-			err = &errortypes.ReadError{
-				errors.Wrap(e, "sprofile: Failed to read log file"),
-			}
+			err = e
 			return
 		}
 
@@ -223,53 +320,8 @@ func (s *Sprofile) GetOutput() (data string, err error) {
 }
 
 func (s *Sprofile) PushOutput(line string) (err error) {
-	logPth1 := s.BasePath() + ".log"
-	logPth2 := s.BasePath() + ".log.1"
-
-	file, err := os.OpenFile(logPth1,
-		os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		err = &errortypes.WriteError{
-			errors.Wrap(err, "sprofile: Failed to open log file"),
-		}
-		return
-	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "sprofile: Failed to stat log file"),
-		}
-		return
-	}
-
-	if stat.Size() >= 200000 {
-		os.Remove(logPth2)
-		err = os.Rename(logPth1, logPth2)
-		if err != nil {
-			err = &errortypes.WriteError{
-				errors.Wrap(err, "sprofile: Failed to rotate log file"),
-			}
-			return
-		}
-
-		file.Close()
-		file, err = os.OpenFile(logPth1,
-			os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-		if err != nil {
-			err = &errortypes.WriteError{
-				errors.Wrap(err, "sprofile: Failed to open log file"),
-			}
-			return
-		}
-	}
-
-	_, err = file.Write([]byte(line))
+	err = store.Append(s.Id+".log", []byte(line), 200000)
 	if err != nil {
-		err = &errortypes.WriteError{
-			errors.Wrap(err, "sprofile: Failed to write to log file"),
-		}
 		return
 	}
 
@@ -515,16 +567,17 @@ func (s *Sprofile) Sync() (updated bool, err error) {
 }
 
 func (s *Sprofile) Commit() (err error) {
-	prflsPath := GetPath()
-
-	err = platform.MkdirSecure(prflsPath)
-	if err != nil {
-		return
+	prfl := s.Copy()
+	prfl.PasswordData = ""
+	if prfl.Password != "" {
+		prfl.PasswordData, err = keystore.Encrypt(prfl.Password)
+		if err != nil {
+			return
+		}
+		prfl.Password = ""
 	}
 
-	pth := filepath.Join(prflsPath, s.Id+".conf")
-
-	data, err := json.Marshal(s)
+	data, err := json.Marshal(prfl)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.Wrap(err, "sprofiles: Failed to parse profile data"),
@@ -532,7 +585,7 @@ func (s *Sprofile) Commit() (err error) {
 		return
 	}
 
-	err = utils.CreateWrite(pth, string(data), 0600)
+	err = store.Write(s.Id+".conf", data)
 	if err != nil {
 		return
 	}
@@ -543,13 +596,7 @@ func (s *Sprofile) Commit() (err error) {
 }
 
 func (s *Sprofile) Delete() (err error) {
-	prflPth := s.BasePath() + ".conf"
-	logPth1 := s.BasePath() + ".log"
-	logPth2 := s.BasePath() + ".log.1"
-
-	_ = utils.Remove(prflPth)
-	_ = utils.Remove(logPth1)
-	_ = utils.Remove(logPth2)
+	_ = store.Remove(s.Id+".conf", s.Id+".log", s.Id+".log.1")
 
 	return
 }
@@ -0,0 +1,183 @@
+package sprofile
+
+import (
+	"encoding/json"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const stateName = "state.json"
+
+type connState struct {
+	Profiles map[string]bool   `json:"profiles"`
+	Users    map[string]string `json:"users"`
+}
+
+// State is only accessed with cacheLock held
+func loadState() (state *connState) {
+	state = &connState{
+		Profiles: map[string]bool{},
+		Users:    map[string]string{},
+	}
+
+	exists, err := utils.Exists(store.Path(stateName))
+	if err != nil || !exists {
+		return
+	}
+
+	data, err := store.Read(stateName)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("sprofile: Failed to read connection state")
+		return
+	}
+
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("sprofile: Failed to parse connection state")
+		state.Profiles = map[string]bool{}
+		state.Users = map[string]string{}
+		return
+	}
+
+	if state.Profiles == nil {
+		state.Profiles = map[string]bool{}
+	}
+	if state.Users == nil {
+		state.Users = map[string]string{}
+	}
+
+	return
+}
+
+func saveState(state *connState) (err error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to marshal connection state"),
+		}
+		return
+	}
+
+	err = store.Write(stateName, data)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func updateState(prflId string, update func(state *connState) bool) {
+	state := loadState()
+
+	if !update(state) {
+		return
+	}
+
+	err := saveState(state)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+			"error":      err,
+		}).Error("sprofile: Failed to save connection state")
+	}
+}
+
+func setState(prflId string, active bool) {
+	updateState(prflId, func(state *connState) bool {
+		state.Profiles[prflId] = active
+		return true
+	})
+}
+
+func clearState(prflId string) {
+	updateState(prflId, func(state *connState) bool {
+		_, ok := state.Profiles[prflId]
+		_, userOk := state.Users[prflId]
+		if !ok && !userOk {
+			return false
+		}
+
+		delete(state.Profiles, prflId)
+		delete(state.Users, prflId)
+		return true
+	})
+}
+
+// Store non-secret reconnect data for a user profile so a reconnect can be
+// offered after a service restart
+func SetUserState(prflId, data string) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	updateState(prflId, func(state *connState) bool {
+		state.Users[prflId] = data
+		return true
+	})
+}
+
+func ClearUserState(prflId string) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	updateState(prflId, func(state *connState) bool {
+		if _, ok := state.Users[prflId]; !ok {
+			return false
+		}
+
+		delete(state.Users, prflId)
+		return true
+	})
+}
+
+func GetUserStates() (states map[string]string) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	states = loadState().Users
+
+	return
+}
+
+// Profiles are started if they were active when the service stopped and
+// are not disabled, auto start profiles are always started at boot
+func restoreState(prfls []*Sprofile, state *connState) {
+	for _, prfl := range prfls {
+		active, ok := state.Profiles[prfl.Id]
+		if !ok || prfl.AutoStart {
+			active = true
+		}
+		if prfl.Disabled {
+			active = false
+		}
+
+		if active && prfl.AutoStart {
+			err := prfl.ValidateAutoStart()
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": prfl.Id,
+					"error":      err,
+				}).Error("sprofile: Skipping auto start for profile " +
+					"without machine credentials")
+				active = false
+			}
+		}
+
+		if active && prfl.Interactive() {
+			logrus.WithFields(logrus.Fields{
+				"profile_id":    prfl.Id,
+				"password_mode": prfl.PasswordMode,
+			}).Warn("sprofile: Skipping auto start for profile " +
+				"requiring interactive authentication")
+			active = false
+		}
+
+		prfl.State = active
+	}
+}
@@ -0,0 +1,57 @@
+package sprofile
+
+import (
+	"testing"
+)
+
+func TestRestoreState(t *testing.T) {
+	origStore := store
+	store = NewStore(t.TempDir())
+	t.Cleanup(func() {
+		store = origStore
+	})
+
+	setState("active", true)
+	setState("disconnected", false)
+	setState("disabled", true)
+	setState("interactive", true)
+	SetUserState("user", "data")
+	ClearUserState("user")
+	SetUserState("user2", "data2")
+
+	prfls := []*Sprofile{
+		{Id: "active"},
+		{Id: "disconnected"},
+		{Id: "disabled", Disabled: true},
+		{Id: "interactive", PasswordMode: "otp"},
+		{Id: "new"},
+		{Id: "new_disabled", Disabled: true},
+	}
+
+	restoreState(prfls, loadState())
+
+	expected := map[string]bool{
+		"active":       true,
+		"disconnected": false,
+		"disabled":     false,
+		"interactive":  false,
+		"new":          true,
+		"new_disabled": false,
+	}
+	for _, prfl := range prfls {
+		if prfl.State != expected[prfl.Id] {
+			t.Errorf("profile %s expected state %t got %t",
+				prfl.Id, expected[prfl.Id], prfl.State)
+		}
+	}
+
+	users := GetUserStates()
+	if len(users) != 1 || users["user2"] != "data2" {
+		t.Errorf("unexpected user states %v", users)
+	}
+
+	clearState("active")
+	if _, ok := loadState().Profiles["active"]; ok {
+		t.Error("cleared profile state still present")
+	}
+}
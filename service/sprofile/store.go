@@ -0,0 +1,250 @@
+package sprofile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const storeLockName = ".lock"
+
+var store = NewStore(GetPath())
+
+type Store struct {
+	dir  string
+	lock sync.RWMutex
+}
+
+func NewStore(dir string) *Store {
+	return &Store{
+		dir: dir,
+	}
+}
+
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *Store) lockFile(exclusive bool) (file *os.File, err error) {
+	exists, err := utils.ExistsDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = platform.MkdirSecure(s.dir)
+		if err != nil {
+			return
+		}
+	}
+
+	file, err = os.OpenFile(s.Path(storeLockName),
+		os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "sprofile: Failed to open store lock"),
+		}
+		return
+	}
+
+	err = platform.LockFile(file, exclusive)
+	if err != nil {
+		file.Close()
+		file = nil
+		return
+	}
+
+	return
+}
+
+func (s *Store) unlockFile(file *os.File) {
+	_ = platform.UnlockFile(file)
+	_ = file.Close()
+}
+
+func (s *Store) Read(name string) (data []byte, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	file, err := s.lockFile(false)
+	if err != nil {
+		return
+	}
+	defer s.unlockFile(file)
+
+	data, err = ioutil.ReadFile(s.Path(name))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read profile file"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *Store) Exists(name string) (exists bool, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	exists, err = utils.Exists(s.Path(name))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *Store) Write(name string, data []byte) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer s.unlockFile(file)
+
+	err = s.write(name, data)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Read, modify and write a file while holding the exclusive lock so
+// concurrent updates from other processes are not lost
+func (s *Store) Update(name string,
+	update func(data []byte) ([]byte, error)) (err error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer s.unlockFile(file)
+
+	data, err := ioutil.ReadFile(s.Path(name))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "sprofile: Failed to read profile file"),
+			}
+			return
+		}
+		data = nil
+		err = nil
+	}
+
+	data, err = update(data)
+	if err != nil {
+		return
+	}
+
+	err = s.write(name, data)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *Store) write(name string, data []byte) (err error) {
+	pth := s.Path(name)
+	tmpPth := pth + ".tmp"
+
+	err = utils.CreateWrite(tmpPth, string(data), 0600)
+	if err != nil {
+		_ = os.Remove(tmpPth)
+		return
+	}
+
+	err = os.Rename(tmpPth, pth)
+	if err != nil {
+		_ = os.Remove(tmpPth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "sprofile: Failed to replace profile file"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *Store) Remove(names ...string) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer s.unlockFile(file)
+
+	for _, name := range names {
+		e := utils.Remove(s.Path(name))
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// Append to a log file, the file is rotated to name.1 once it exceeds
+// maxSize
+func (s *Store) Append(name string, data []byte, maxSize int64) (
+	err error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	lockFile, err := s.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer s.unlockFile(lockFile)
+
+	pth := s.Path(name)
+
+	stat, err := os.Stat(pth)
+	if err == nil && stat.Size() >= maxSize {
+		_ = os.Remove(pth + ".1")
+		err = os.Rename(pth, pth+".1")
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "sprofile: Failed to rotate log file"),
+			}
+			return
+		}
+	}
+	err = nil
+
+	file, err := os.OpenFile(pth, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "sprofile: Failed to open log file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "sprofile: Failed to write to log file"),
+		}
+		return
+	}
+
+	return
+}
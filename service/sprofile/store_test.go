@@ -0,0 +1,176 @@
+package sprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type storeDoc struct {
+	Writer  int    `json:"writer"`
+	Payload string `json:"payload"`
+}
+
+func TestStoreConcurrentReadWrite(t *testing.T) {
+	strStore := NewStore(t.TempDir())
+
+	writers := 16
+	readers := 16
+	iterations := 50
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, (writers+readers)*iterations)
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+
+			doc := &storeDoc{
+				Writer:  writer,
+				Payload: strings.Repeat(strconv.Itoa(writer), 4096),
+			}
+			data, _ := json.Marshal(doc)
+
+			for j := 0; j < iterations; j++ {
+				err := strStore.Write("test.conf", data)
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				data, err := strStore.Read("test.conf")
+				if err != nil {
+					// File may not be written yet
+					continue
+				}
+
+				doc := &storeDoc{}
+				err = json.Unmarshal(data, doc)
+				if err != nil {
+					errs <- fmt.Errorf("corrupt read: %s", err)
+					continue
+				}
+
+				expected := strings.Repeat(strconv.Itoa(doc.Writer), 4096)
+				if doc.Payload != expected {
+					errs <- fmt.Errorf("torn write from writer %d",
+						doc.Writer)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestStoreConcurrentUpdate(t *testing.T) {
+	dir := t.TempDir()
+
+	// Separate stores share only the file lock, as separate processes would
+	stores := []*Store{
+		NewStore(dir),
+		NewStore(dir),
+	}
+
+	goroutines := 32
+	iterations := 20
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, goroutines*iterations)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(strStore *Store) {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				err := strStore.Update("count.conf",
+					func(data []byte) ([]byte, error) {
+						count := 0
+						if len(data) > 0 {
+							n, err := strconv.Atoi(string(data))
+							if err != nil {
+								return nil, err
+							}
+							count = n
+						}
+
+						return []byte(strconv.Itoa(count + 1)), nil
+					})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(stores[i%len(stores)])
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	data, err := stores[0].Read("count.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, _ := strconv.Atoi(string(data))
+	if count != goroutines*iterations {
+		t.Errorf("lost writes, expected %d got %d",
+			goroutines*iterations, count)
+	}
+}
+
+func TestStoreAppendMissingDir(t *testing.T) {
+	strStore := NewStore(filepath.Join(t.TempDir(), "profiles"))
+
+	_, err := strStore.Read("missing.conf")
+	if err == nil {
+		t.Fatal("expected error reading missing file")
+	}
+
+	err = strStore.Append("test.log", []byte("line\n"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = strStore.Append("test.log", []byte("line2\n"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := strStore.Read("test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "line2\n" {
+		t.Errorf("log not rotated, got %q", data)
+	}
+
+	data, err = strStore.Read("test.log.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "line\n" {
+		t.Errorf("unexpected rotated log %q", data)
+	}
+}
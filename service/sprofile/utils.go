@@ -2,7 +2,6 @@ package sprofile
 
 import (
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -12,6 +11,8 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/keystore"
+	"github.com/pritunl/pritunl-client-electron/service/parser"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -40,6 +41,32 @@ func Activate(prflId, mode, password string) (err error) {
 			if err != nil {
 				return
 			}
+
+			setState(prflId, true)
+		}
+		prflsCache = append(prflsCache, prfl)
+	}
+
+	cache = prflsCache
+
+	return
+}
+
+func ClearPassword(prflId string) (err error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	prflsCache := []*Sprofile{}
+
+	for _, prfl := range cache {
+		if (prflId == "" || prfl.Id == prflId) && prfl.Password != "" {
+			prfl = prfl.Copy()
+			prfl.Password = ""
+
+			err = prfl.Commit()
+			if err != nil {
+				return
+			}
 		}
 		prflsCache = append(prflsCache, prfl)
 	}
@@ -58,6 +85,7 @@ func Deactivate(prflId string) {
 	for _, prfl := range cache {
 		if prfl.Id == prflId {
 			prfl.State = false
+			setState(prflId, false)
 		}
 		prflsCache = append(prflsCache, prfl)
 	}
@@ -149,12 +177,11 @@ func GetAllClient() (prfls []*SprofileClient, err error) {
 }
 
 func Remove(prflId string) {
-	prflsPath := GetPath()
-	prflPth := filepath.Join(prflsPath, fmt.Sprintf("%s.conf", prflId))
-	logPth := filepath.Join(prflsPath, fmt.Sprintf("%s.log", prflId))
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
 
-	_ = os.Remove(prflPth)
-	_ = os.Remove(logPth)
+	_ = store.Remove(prflId+".conf", prflId+".log", prflId+".log.1")
+	clearState(prflId)
 
 	cacheStale = true
 }
@@ -192,7 +219,7 @@ func Reload(init bool) (err error) {
 			continue
 		}
 
-		data, e := ioutil.ReadFile(pth)
+		data, e := store.Read(name)
 		if e != nil {
 			logrus.WithFields(logrus.Fields{
 				"path":  pth,
@@ -217,9 +244,26 @@ func Reload(init bool) (err error) {
 			continue
 		}
 
-		if init {
-			prfl.State = !prfl.Disabled
-		} else {
+		if prfl.PasswordData != "" {
+			prfl.Password, e = keystore.Decrypt(prfl.PasswordData)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"path":  pth,
+					"error": e,
+				}).Error("sprofile: Failed to decrypt saved password")
+				prfl.Password = ""
+			}
+		} else if prfl.Password != "" {
+			e = prfl.Commit()
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"path":  pth,
+					"error": e,
+				}).Error("sprofile: Failed to encrypt saved password")
+			}
+		}
+
+		if !init {
 			curPrfl := curPrfls[prfl.Id]
 			if curPrfl != nil {
 				prfl.State = curPrfl.State
@@ -229,6 +273,10 @@ func Reload(init bool) (err error) {
 		prfls = append(prfls, prfl)
 	}
 
+	if init {
+		restoreState(prfls, loadState())
+	}
+
 	cache = prfls
 	cacheStale = false
 
@@ -236,10 +284,7 @@ func Reload(init bool) (err error) {
 }
 
 func ClearLog(prflId string) (err error) {
-	prflsPath := GetPath()
-	pth := filepath.Join(prflsPath, fmt.Sprintf("%s.log", prflId))
-
-	err = utils.CreateWriteLock(pth, "", 0600)
+	err = store.Write(prflId+".log", []byte{})
 	if err != nil {
 		return
 	}
@@ -260,3 +305,85 @@ func Shutdown() {
 
 	cache = prflsCache
 }
+
+func ParseImport(data []byte) (prfl *Sprofile, err error) {
+	jsonData := ""
+	jsonFound := false
+	jsonLoaded := false
+	ovpnData := ""
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !jsonLoaded && !jsonFound && line == "#{" {
+			jsonFound = true
+			jsonLoaded = true
+		}
+
+		if jsonFound && strings.HasPrefix(line, "#") {
+			if line == "#}" {
+				jsonFound = false
+			}
+			jsonData += strings.Replace(line, "#", "", 1)
+		} else {
+			ovpnData += line + "\n"
+		}
+	}
+
+	if !jsonLoaded || jsonFound {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Import conf data missing"),
+		}
+		return
+	}
+
+	prfl = &Sprofile{}
+	err = json.Unmarshal([]byte(jsonData), prfl)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse import conf data"),
+		}
+		return
+	}
+
+	prfl.OvpnData = strings.TrimSpace(ovpnData)
+	if prfl.OvpnData == "" {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Import profile data missing"),
+		}
+		return
+	}
+	prfl.OvpnData += "\n"
+
+	err = parser.Validate(prfl.OvpnData)
+	if err != nil {
+		return
+	}
+
+	prfl.Password = ""
+	prfl.PasswordData = ""
+	prfl.LastMode = ""
+
+	return
+}
+
+func NewId() (prflId string, err error) {
+	for {
+		id, e := utils.RandStr(16)
+		if e != nil {
+			err = e
+			return
+		}
+		prflId = strings.ToLower(id)
+
+		exists, e := store.Exists(prflId + ".conf")
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !exists && Get(prflId) == nil {
+			break
+		}
+	}
+
+	return
+}
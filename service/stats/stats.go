@@ -0,0 +1,200 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	retention     = 30 * 24 * time.Hour
+	pruneInterval = 24 * time.Hour
+)
+
+var (
+	lock      = sync.Mutex{}
+	lastPrune time.Time
+)
+
+type Session struct {
+	ProfileId string `json:"profile_id"`
+	Server    string `json:"server"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+}
+
+func readSessions() (sessions []Session, err error) {
+	sessions = []Session{}
+
+	file, err := os.Open(utils.GetStatsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "stats: Failed to open stats file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		sess := Session{}
+		e := json.Unmarshal(scanner.Bytes(), &sess)
+		if e != nil {
+			continue
+		}
+
+		sessions = append(sessions, sess)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "stats: Failed to read stats file"),
+		}
+		return
+	}
+
+	return
+}
+
+func prune() (err error) {
+	sessions, err := readSessions()
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-retention).Unix()
+	pth := utils.GetStatsPath()
+	tmpPth := filepath.Join(filepath.Dir(pth), ".stats.tmp")
+
+	file, err := os.OpenFile(tmpPth,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "stats: Failed to open temporary stats file"),
+		}
+		return
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, sess := range sessions {
+		if sess.End < cutoff {
+			continue
+		}
+
+		data, e := json.Marshal(sess)
+		if e != nil {
+			continue
+		}
+
+		_, _ = writer.Write(append(data, '\n'))
+	}
+
+	err = writer.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		_ = os.Remove(tmpPth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "stats: Failed to write temporary stats file"),
+		}
+		return
+	}
+
+	err = os.Rename(tmpPth, pth)
+	if err != nil {
+		_ = os.Remove(tmpPth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "stats: Failed to replace stats file"),
+		}
+		return
+	}
+
+	return
+}
+
+func Record(sess Session) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "stats: Failed to marshal session"),
+		}
+		return
+	}
+
+	file, err := os.OpenFile(utils.GetStatsPath(),
+		os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "stats: Failed to open stats file"),
+		}
+		return
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "stats: Failed to write stats file"),
+		}
+		return
+	}
+
+	if time.Since(lastPrune) > pruneInterval {
+		lastPrune = time.Now()
+
+		err = prune()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func Query(profileId string, since time.Time) (sessions []Session, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	allSessions, err := readSessions()
+	if err != nil {
+		return
+	}
+
+	sinceUnix := since.Unix()
+	sessions = []Session{}
+	for _, sess := range allSessions {
+		if profileId != "" && sess.ProfileId != profileId {
+			continue
+		}
+		if sess.End < sinceUnix {
+			continue
+		}
+
+		sessions = append(sessions, sess)
+	}
+
+	return
+}
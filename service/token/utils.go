@@ -1,13 +1,22 @@
 package token
 
-var store = map[string]*Token{}
+import (
+	"sync"
+)
 
-func Get(profile, pubKey, pubBoxKey string) *Token {
+var store = struct {
+	sync.Mutex
+	m map[string]*Token
+}{
+	m: map[string]*Token{},
+}
+
+func get(profile, pubKey, pubBoxKey string) *Token {
 	if profile == "" {
 		return nil
 	}
 
-	tokn := store[profile]
+	tokn := store.m[profile]
 
 	if tokn != nil && pubKey == tokn.ServerPublicKey &&
 		pubBoxKey == tokn.ServerBoxPublicKey {
@@ -18,10 +27,20 @@ func Get(profile, pubKey, pubBoxKey string) *Token {
 	return nil
 }
 
+func Get(profile, pubKey, pubBoxKey string) *Token {
+	store.Lock()
+	defer store.Unlock()
+
+	return get(profile, pubKey, pubBoxKey)
+}
+
 func Update(profile, pubKey, pubBoxKey string, ttl int) (
 	tokn *Token, err error) {
 
-	tokn = Get(profile, pubKey, pubBoxKey)
+	store.Lock()
+	defer store.Unlock()
+
+	tokn = get(profile, pubKey, pubBoxKey)
 	if tokn == nil {
 		tokn = &Token{
 			Profile:            profile,
@@ -34,7 +53,7 @@ func Update(profile, pubKey, pubBoxKey string, ttl int) (
 			return
 		}
 
-		store[profile] = tokn
+		store.m[profile] = tokn
 	}
 
 	tokn.Ttl = ttl
@@ -48,5 +67,18 @@ func Update(profile, pubKey, pubBoxKey string, ttl int) (
 }
 
 func Clear(profile string) {
-	delete(store, profile)
+	store.Lock()
+	delete(store.m, profile)
+	store.Unlock()
+}
+
+func Profiles() (prfls []string) {
+	store.Lock()
+	defer store.Unlock()
+
+	prfls = []string{}
+	for prfl := range store.m {
+		prfls = append(prfls, prfl)
+	}
+	return
 }
@@ -2,21 +2,43 @@ package tuntap
 
 import (
 	"fmt"
+	"net"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
+const maxAdapters = 32
+
 var (
 	curSize  = 0
 	taps     = []string{}
+	tapsUsed = set.NewSet()
+	tapsHeld = set.NewSet()
 	tapsLock = sync.Mutex{}
 )
 
+var (
+	listAdapters  = Get
+	adapterActive = ifaceActive
+	createAdapter = create
+)
+
+type Inventory struct {
+	Size     int      `json:"size"`
+	Adapters []string `json:"adapters"`
+	InUse    []string `json:"in_use"`
+	Held     []string `json:"held"`
+	Free     []string `json:"free"`
+}
+
 func getToolpath() string {
 	pth := filepath.Join(utils.GetRootDir(), "..",
 		"tuntap_win", "tapctl.exe")
@@ -77,7 +99,58 @@ func Clean() (err error) {
 		)
 	}
 
+	tapsLock.Lock()
 	curSize = 0
+	taps = []string{}
+	tapsUsed = set.NewSet()
+	tapsHeld = set.NewSet()
+	tapsLock.Unlock()
+
+	return
+}
+
+// The TAP driver only reports media connected while a process has the
+// adapter open, an existing adapter that is up is held by another process
+func ifaceActive(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return false
+	}
+
+	return iface.Flags&net.FlagUp != 0
+}
+
+func create(tapName string) (err error) {
+	toolpath := getToolpath()
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		toolpath,
+		"create",
+		"--name", tapName,
+	)
+	if err != nil {
+		_, _ = utils.ExecCombinedOutputLogged(
+			[]string{
+				"No devices",
+			},
+			toolpath,
+			"delete",
+			tapName,
+		)
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			toolpath,
+			"create",
+			"--name", tapName,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	time.Sleep(200 * time.Millisecond)
 
 	return
 }
@@ -86,8 +159,6 @@ func Resize(size int) (err error) {
 	tapsLock.Lock()
 	defer tapsLock.Unlock()
 
-	toolpath := getToolpath()
-
 	if size <= 3 {
 		size = 3
 	} else if size < 6 {
@@ -97,32 +168,37 @@ func Resize(size int) (err error) {
 	}
 
 	add := size - curSize
+	if add <= 0 {
+		return
+	}
 
-	for i := 0; i < add; i++ {
-		tapName := fmt.Sprintf("Pritunl %d", curSize+1)
+	existing := set.NewSet()
+	adapters, e := listAdapters()
+	if e == nil {
+		for _, adapter := range adapters {
+			existing.Add(adapter)
+		}
+	}
 
-		_, err = utils.ExecCombinedOutputLogged(
-			nil,
-			toolpath,
-			"create",
-			"--name", tapName,
-		)
-		if err != nil {
-			_, _ = utils.ExecCombinedOutputLogged(
-				[]string{
-					"No devices",
-				},
-				toolpath,
-				"delete",
-				tapName,
-			)
-
-			_, err = utils.ExecCombinedOutputLogged(
-				nil,
-				toolpath,
-				"create",
-				"--name", tapName,
-			)
+	for i := curSize + tapsHeld.Len(); add > 0; i++ {
+		if i >= maxAdapters {
+			err = &errortypes.NotFoundError{
+				errors.Newf("tuntap: No free TAP adapters, %d adapters "+
+					"are held by other processes, run TunTapClean to "+
+					"remove stale adapters", tapsHeld.Len()),
+			}
+			return
+		}
+
+		tapName := fmt.Sprintf("Pritunl %d", i+1)
+
+		if existing.Contains(tapName) {
+			if adapterActive(tapName) {
+				tapsHeld.Add(tapName)
+				continue
+			}
+		} else {
+			err = createAdapter(tapName)
 			if err != nil {
 				_ = Clean()
 				return
@@ -130,9 +206,8 @@ func Resize(size int) (err error) {
 		}
 
 		curSize += 1
+		add -= 1
 		taps = append(taps, tapName)
-
-		time.Sleep(200 * time.Millisecond)
 	}
 
 	sort.Strings(taps)
@@ -144,11 +219,21 @@ func Size() int {
 	return curSize
 }
 
-func Acquire() (tap string) {
+func Acquire() (tap string, err error) {
 	tapsLock.Lock()
 	defer tapsLock.Unlock()
 
+	if len(taps) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.Newf("tuntap: No free TAP adapters, all %d adapters "+
+				"are in use, run TunTapClean to remove stale adapters",
+				curSize),
+		}
+		return
+	}
+
 	tap, taps = taps[0], taps[1:]
+	tapsUsed.Add(tap)
 
 	return
 }
@@ -157,6 +242,43 @@ func Release(tap string) {
 	tapsLock.Lock()
 	defer tapsLock.Unlock()
 
+	if !tapsUsed.Contains(tap) {
+		return
+	}
+	tapsUsed.Remove(tap)
+
 	taps = append(taps, tap)
 	sort.Strings(taps)
 }
+
+func GetInventory() (inv *Inventory, err error) {
+	adapters, err := listAdapters()
+	if err != nil {
+		return
+	}
+
+	tapsLock.Lock()
+	defer tapsLock.Unlock()
+
+	inv = &Inventory{
+		Size:     curSize,
+		Adapters: adapters,
+		InUse:    []string{},
+		Held:     []string{},
+		Free:     []string{},
+	}
+
+	for tapInf := range tapsUsed.Iter() {
+		inv.InUse = append(inv.InUse, tapInf.(string))
+	}
+	sort.Strings(inv.InUse)
+
+	for tapInf := range tapsHeld.Iter() {
+		inv.Held = append(inv.Held, tapInf.(string))
+	}
+	sort.Strings(inv.Held)
+
+	inv.Free = append(inv.Free, taps...)
+
+	return
+}
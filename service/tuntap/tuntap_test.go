@@ -0,0 +1,154 @@
+package tuntap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/godropbox/container/set"
+)
+
+type fakeAdapters struct {
+	adapters []string
+	active   map[string]bool
+	created  []string
+}
+
+func setFakeAdapters(t *testing.T, fake *fakeAdapters) {
+	origList := listAdapters
+	origActive := adapterActive
+	origCreate := createAdapter
+
+	listAdapters = func() ([]string, error) {
+		return append([]string{}, fake.adapters...), nil
+	}
+	adapterActive = func(name string) bool {
+		return fake.active[name]
+	}
+	createAdapter = func(name string) error {
+		fake.adapters = append(fake.adapters, name)
+		fake.created = append(fake.created, name)
+		return nil
+	}
+
+	reset := func() {
+		curSize = 0
+		taps = []string{}
+		tapsUsed = set.NewSet()
+		tapsHeld = set.NewSet()
+	}
+	reset()
+
+	t.Cleanup(func() {
+		listAdapters = origList
+		adapterActive = origActive
+		createAdapter = origCreate
+		reset()
+	})
+}
+
+func TestResizeReusesFree(t *testing.T) {
+	fake := &fakeAdapters{
+		adapters: []string{"Pritunl 1", "Pritunl 2", "Pritunl 3"},
+		active: map[string]bool{
+			"Pritunl 1": true,
+			"Pritunl 2": true,
+		},
+	}
+	setFakeAdapters(t, fake)
+
+	err := Resize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(fake.created, ",") != "Pritunl 4,Pritunl 5" {
+		t.Errorf("unexpected created adapters %v", fake.created)
+	}
+
+	tap, err := Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tap != "Pritunl 3" {
+		t.Errorf("expected free adapter to be reused, got %s", tap)
+	}
+
+	inv, err := GetInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(inv.Held, ",") != "Pritunl 1,Pritunl 2" {
+		t.Errorf("unexpected held adapters %v", inv.Held)
+	}
+	if strings.Join(inv.InUse, ",") != "Pritunl 3" {
+		t.Errorf("unexpected in use adapters %v", inv.InUse)
+	}
+	if strings.Join(inv.Free, ",") != "Pritunl 4,Pritunl 5" {
+		t.Errorf("unexpected free adapters %v", inv.Free)
+	}
+}
+
+func TestAcquireAllInUse(t *testing.T) {
+	fake := &fakeAdapters{
+		adapters: []string{"Pritunl 1", "Pritunl 2", "Pritunl 3"},
+		active:   map[string]bool{},
+	}
+	setFakeAdapters(t, fake)
+
+	err := Resize(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.created) != 0 {
+		t.Errorf("unexpected created adapters %v", fake.created)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = Acquire()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = Acquire()
+	if err == nil {
+		t.Fatal("expected error with all adapters in use")
+	}
+	if !strings.Contains(err.Error(), "TunTapClean") {
+		t.Errorf("error does not suggest cleanup: %s", err)
+	}
+
+	Release("Pritunl 2")
+
+	tap, err := Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tap != "Pritunl 2" {
+		t.Errorf("expected released adapter, got %s", tap)
+	}
+}
+
+func TestResizeAllHeld(t *testing.T) {
+	fake := &fakeAdapters{
+		active: map[string]bool{},
+	}
+	for i := 1; i <= maxAdapters; i++ {
+		name := fmt.Sprintf("Pritunl %d", i)
+		fake.adapters = append(fake.adapters, name)
+		fake.active[name] = true
+	}
+	setFakeAdapters(t, fake)
+
+	err := Resize(3)
+	if err == nil {
+		t.Fatal("expected error with all adapters held")
+	}
+	if !strings.Contains(err.Error(), "TunTapClean") {
+		t.Errorf("error does not suggest cleanup: %s", err)
+	}
+	if len(fake.created) != 0 {
+		t.Errorf("unexpected created adapters %v", fake.created)
+	}
+}
@@ -154,7 +154,7 @@ func ExecOutput(name string, arg ...string) (output string, err error) {
 func ExecOutputLogged(ignores []string, name string, arg ...string) (
 	output string, err error) {
 
-	cmd := command.Command(name, arg...)
+	cmd := command.New(name, arg...)
 
 	stdout := &bytes.Buffer{}
 	stderr := &bytes.Buffer{}
@@ -185,7 +185,7 @@ func ExecOutputLogged(ignores []string, name string, arg ...string) (
 			"output":       output,
 			"error_output": errOutput,
 			"cmd":          name,
-			"arg":          arg,
+			"arg":          cmd.RedactedArgs(),
 			"error":        err,
 		}).Error("utils: Process exec error")
 		return
@@ -216,7 +216,18 @@ func ExecCombinedOutput(name string, arg ...string) (
 func ExecCombinedOutputLogged(ignores []string, name string, arg ...string) (
 	output string, err error) {
 
-	cmd := command.Command(name, arg...)
+	output, err = ExecCmdCombinedOutputLogged(
+		ignores, command.New(name, arg...))
+	return
+}
+
+func ExecCmdCombinedOutputLogged(ignores []string, cmd *command.Cmd) (
+	output string, err error) {
+
+	name := cmd.Path
+	if len(cmd.Args) > 0 {
+		name = cmd.Args[0]
+	}
 
 	outputByt, err := cmd.CombinedOutput()
 	if outputByt != nil {
@@ -240,7 +251,7 @@ func ExecCombinedOutputLogged(ignores []string, name string, arg ...string) (
 		logrus.WithFields(logrus.Fields{
 			"output": output,
 			"cmd":    name,
-			"arg":    arg,
+			"arg":    cmd.RedactedArgs(),
 			"error":  err,
 		}).Error("utils: Process exec error")
 		return
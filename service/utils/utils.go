@@ -314,6 +314,46 @@ func CopyScutilDns(src string) (err error) {
 	return
 }
 
+func SetScutilDnsServers(key string, dnsServers []string) (err error) {
+	cmd := command.Command("/usr/sbin/scutil")
+	cmd.Stdin = strings.NewReader(
+		fmt.Sprintf("open\n"+
+			"get State:%s\n"+
+			"d.add ServerAddresses * %s\n"+
+			"set State:%s\n"+
+			"quit\n", key, strings.Join(dnsServers, " "), key))
+
+	err = cmd.Run()
+	if err != nil {
+		err = &CommandError{
+			errors.Wrap(err, "utils: Failed to exec scutil"),
+		}
+		return
+	}
+
+	return
+}
+
+func SetScutilSearchDomains(key string, domains []string) (err error) {
+	cmd := command.Command("/usr/sbin/scutil")
+	cmd.Stdin = strings.NewReader(
+		fmt.Sprintf("open\n"+
+			"get State:%s\n"+
+			"d.add SearchDomains * %s\n"+
+			"set State:%s\n"+
+			"quit\n", key, strings.Join(domains, " "), key))
+
+	err = cmd.Run()
+	if err != nil {
+		err = &CommandError{
+			errors.Wrap(err, "utils: Failed to exec scutil"),
+		}
+		return
+	}
+
+	return
+}
+
 func BackupScutilDns() (err error) {
 	serviceId, err := GetScutilService()
 	if err != nil {
@@ -680,6 +720,93 @@ func GetAuthPath() (pth string) {
 	return
 }
 
+func GetStatsPath() (pth string) {
+	if constants.Development {
+		pth = filepath.Join(GetRootDir(), "..", "dev")
+
+		_ = os.MkdirAll(pth, 0755)
+
+		pth = filepath.Join(pth, "stats")
+		return
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		pth = filepath.Join(GetWinDrive(), "ProgramData", "Pritunl")
+
+		_ = platform.MkdirReadSecure(pth)
+
+		pth = filepath.Join(pth, "stats")
+		break
+	case "linux", "darwin":
+		pth = filepath.Join(string(filepath.Separator),
+			"var", "lib", "pritunl-client", "stats")
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+
+	return
+}
+
+func GetSettingsPath() (pth string) {
+	if constants.Development {
+		pth = filepath.Join(GetRootDir(), "..", "dev")
+
+		_ = os.MkdirAll(pth, 0755)
+
+		pth = filepath.Join(pth, "settings.json")
+		return
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		pth = filepath.Join(GetWinDrive(), "ProgramData", "Pritunl")
+
+		_ = platform.MkdirReadSecure(pth)
+
+		pth = filepath.Join(pth, "settings.json")
+		break
+	case "linux", "darwin":
+		pth = filepath.Join(string(filepath.Separator),
+			"var", "lib", "pritunl-client", "settings.json")
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+
+	return
+}
+
+func GetKillSwitchPath() (pth string) {
+	if constants.Development {
+		pth = filepath.Join(GetRootDir(), "..", "dev")
+
+		_ = os.MkdirAll(pth, 0755)
+
+		pth = filepath.Join(pth, "killswitch")
+		return
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		pth = filepath.Join(GetWinDrive(), "ProgramData", "Pritunl")
+
+		_ = platform.MkdirReadSecure(pth)
+
+		pth = filepath.Join(pth, "killswitch")
+		break
+	case "linux", "darwin":
+		pth = filepath.Join(string(filepath.Separator),
+			"var", "run", "pritunl.killswitch")
+		break
+	default:
+		panic("profile: Not implemented")
+	}
+
+	return
+}
+
 func GetLogPath() (pth string) {
 	if constants.Development {
 		pth = filepath.Join(GetRootDir(), "..", "dev", "log")
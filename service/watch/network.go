@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	networkDebounce = 3 * time.Second
+	networkCooldown = 10 * time.Second
+	networkPoll     = 10 * time.Second
+)
+
+func networkNotifyBackground(notify chan bool) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	err := networkNotify(notify)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("watch: Network change notify failed, using polling")
+	}
+
+	for {
+		time.Sleep(networkPoll)
+		networkSignal(notify)
+	}
+}
+
+func networkSignal(notify chan bool) {
+	select {
+	case notify <- true:
+	default:
+	}
+}
+
+func networkDebounceWait(notify chan bool) {
+	timer := time.NewTimer(networkDebounce)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-notify:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(networkDebounce)
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+func networkWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	notify := make(chan bool, 1)
+	go networkNotifyBackground(notify)
+
+	curKey := defaultRouteKey()
+
+	for {
+		<-notify
+		networkDebounceWait(notify)
+
+		profile.RefreshLocalRoutes()
+
+		key := defaultRouteKey()
+		if key == "" || key == curKey {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"previous": curKey,
+			"current":  key,
+		}).Info("watch: Default route changed")
+
+		curKey = key
+
+		profile.ResetOnDemand()
+
+		if !profile.GetStatus() {
+			continue
+		}
+
+		restartLock.Lock()
+		if utils.SinceAbs(lastRestart) < networkCooldown {
+			restartLock.Unlock()
+			continue
+		}
+		lastRestart = time.Now()
+		restartLock.Unlock()
+
+		logrus.Warn("watch: Network changed reconnecting...")
+
+		profile.RestartConnected(profile.ReconnectNetworkChange)
+	}
+}
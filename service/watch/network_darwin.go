@@ -0,0 +1,70 @@
+package watch
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func networkNotify(notify chan bool) (err error) {
+	cmd := command.Command("route", "-n", "monitor")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to get route monitor stdout"),
+		}
+		return
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to start route monitor"),
+		}
+		return
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "got message of size") {
+			networkSignal(notify)
+		}
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Route monitor exited"),
+		}
+		return
+	}
+
+	return
+}
+
+func defaultRouteKey() string {
+	output, err := utils.ExecOutput("netstat", "-rn", "-f", "inet")
+	if err != nil {
+		return ""
+	}
+
+	routes := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "default" ||
+			strings.HasPrefix(fields[3], "utun") {
+
+			continue
+		}
+
+		routes = append(routes, fields[1]+" "+fields[3])
+	}
+
+	return strings.Join(routes, ",")
+}
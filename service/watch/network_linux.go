@@ -0,0 +1,67 @@
+package watch
+
+import (
+	"strings"
+	"syscall"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIpv4Ifaddr = 0x10
+	rtmgrpIpv4Route  = 0x40
+)
+
+func networkNotify(notify chan bool) (err error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW,
+		syscall.NETLINK_ROUTE)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to open netlink socket"),
+		}
+		return
+	}
+	defer syscall.Close(fd)
+
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIpv4Ifaddr | rtmgrpIpv4Route,
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to bind netlink socket"),
+		}
+		return
+	}
+
+	buf := make([]byte, syscall.Getpagesize())
+	for {
+		n, _, e := syscall.Recvfrom(fd, buf, 0)
+		if e != nil {
+			if e == syscall.EINTR {
+				continue
+			}
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "watch: Failed to read netlink socket"),
+			}
+			return
+		}
+
+		if n > 0 {
+			networkSignal(notify)
+		}
+	}
+}
+
+func defaultRouteKey() string {
+	output, err := utils.ExecOutput(
+		"ip", "-4", "route", "show", "default", "table", "main")
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(output)
+}
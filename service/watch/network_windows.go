@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"net"
+	"strings"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	iphlpapi                = windows.NewLazySystemDLL("iphlpapi.dll")
+	notifyRouteChange2      = iphlpapi.NewProc("NotifyRouteChange2")
+	notifyIpInterfaceChange = iphlpapi.NewProc("NotifyIpInterfaceChange")
+)
+
+func networkNotify(notify chan bool) (err error) {
+	callback := windows.NewCallback(
+		func(context, row, notificationType uintptr) uintptr {
+			networkSignal(notify)
+			return 0
+		},
+	)
+
+	var routeHandle windows.Handle
+	ret, _, _ := notifyRouteChange2.Call(
+		uintptr(windows.AF_UNSPEC),
+		callback,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&routeHandle)),
+	)
+	if ret != 0 {
+		err = &errortypes.ExecError{
+			errors.Wrap(windows.Errno(ret),
+				"watch: Failed to register route change callback"),
+		}
+		return
+	}
+
+	var ifaceHandle windows.Handle
+	ret, _, _ = notifyIpInterfaceChange.Call(
+		uintptr(windows.AF_UNSPEC),
+		callback,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&ifaceHandle)),
+	)
+	if ret != 0 {
+		err = &errortypes.ExecError{
+			errors.Wrap(windows.Errno(ret),
+				"watch: Failed to register interface change callback"),
+		}
+		return
+	}
+
+	select {}
+}
+
+func defaultRouteKey() string {
+	output, err := utils.ExecOutput("route", "print", "-4", "0.0.0.0")
+	if err != nil {
+		return ""
+	}
+
+	routes := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "0.0.0.0" ||
+			fields[1] != "0.0.0.0" || net.ParseIP(fields[2]) == nil {
+
+			continue
+		}
+
+		routes = append(routes, fields[2]+" "+fields[3])
+	}
+
+	return strings.Join(routes, ",")
+}
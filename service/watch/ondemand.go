@@ -0,0 +1,32 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	onDemandPoll = 3 * time.Second
+)
+
+func onDemandWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		time.Sleep(onDemandPoll)
+
+		profile.CheckOnDemand()
+	}
+}
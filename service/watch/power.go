@@ -0,0 +1,58 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	powerSuspend  = "suspend"
+	powerResume   = "resume"
+	powerCooldown = 10 * time.Second
+)
+
+func powerHandle(evt string) {
+	switch evt {
+	case powerSuspend:
+		logrus.Info("watch: System suspending")
+
+		profile.Suspend()
+		break
+	case powerResume:
+		restartLock.Lock()
+		restart := utils.SinceAbs(lastRestart) > powerCooldown
+		if restart {
+			lastRestart = time.Now()
+		}
+		restartLock.Unlock()
+
+		logrus.Warn("watch: System resumed reconnecting...")
+
+		profile.Resume(restart)
+		break
+	}
+}
+
+func powerWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	err := powerNotify(powerHandle)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("watch: Power event notify failed")
+	}
+}
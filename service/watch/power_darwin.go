@@ -0,0 +1,56 @@
+package watch
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func powerNotify(handler func(evt string)) (err error) {
+	cmd := command.Command(
+		"/usr/bin/log", "stream",
+		"--style", "compact",
+		"--predicate", `process == "kernel" AND `+
+			`(eventMessage CONTAINS "System Sleep" OR `+
+			`eventMessage CONTAINS "System Wake")`,
+	)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to get power log stdout"),
+		}
+		return
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to start power log stream"),
+		}
+		return
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "System Sleep") {
+			handler(powerSuspend)
+		} else if strings.Contains(line, "System Wake") {
+			handler(powerResume)
+		}
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Power log stream exited"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,97 @@
+package watch
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func powerInhibit() (cmd *exec.Cmd) {
+	cmd = command.Command(
+		"systemd-inhibit",
+		"--what=sleep",
+		"--mode=delay",
+		"--who=Pritunl",
+		"--why=Disconnecting VPN",
+		"sleep", "infinity",
+	)
+
+	err := cmd.Start()
+	if err != nil {
+		cmd = nil
+		return
+	}
+
+	return
+}
+
+func powerRelease(cmd *exec.Cmd) {
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+}
+
+func powerNotify(handler func(evt string)) (err error) {
+	cmd := command.Command(
+		"gdbus", "monitor", "--system",
+		"--dest", "org.freedesktop.login1",
+		"--object-path", "/org/freedesktop/login1",
+	)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to get login1 monitor stdout"),
+		}
+		return
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to start login1 monitor"),
+		}
+		return
+	}
+
+	inhibitor := powerInhibit()
+	defer func() {
+		powerRelease(inhibitor)
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "PrepareForSleep") {
+			continue
+		}
+
+		if strings.Contains(line, "(true,)") {
+			handler(powerSuspend)
+			powerRelease(inhibitor)
+			inhibitor = nil
+		} else if strings.Contains(line, "(false,)") {
+			if inhibitor == nil {
+				inhibitor = powerInhibit()
+			}
+			handler(powerResume)
+		}
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Login1 monitor exited"),
+		}
+		return
+	}
+
+	return
+}
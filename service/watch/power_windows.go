@@ -0,0 +1,61 @@
+package watch
+
+import (
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	deviceNotifyCallback  = 2
+	pbtApmSuspend         = 0x4
+	pbtApmResumeAutomatic = 0x12
+)
+
+var (
+	powrprof                               = windows.NewLazySystemDLL("powrprof.dll")
+	powerRegisterSuspendResumeNotification = powrprof.NewProc(
+		"PowerRegisterSuspendResumeNotification")
+	powerParams *deviceNotifySubscribeParameters
+)
+
+type deviceNotifySubscribeParameters struct {
+	Callback uintptr
+	Context  uintptr
+}
+
+func powerNotify(handler func(evt string)) (err error) {
+	powerParams = &deviceNotifySubscribeParameters{
+		Callback: windows.NewCallback(
+			func(context, typ, setting uintptr) uintptr {
+				switch typ {
+				case pbtApmSuspend:
+					handler(powerSuspend)
+					break
+				case pbtApmResumeAutomatic:
+					handler(powerResume)
+					break
+				}
+				return 0
+			},
+		),
+	}
+
+	var handle windows.Handle
+	ret, _, _ := powerRegisterSuspendResumeNotification.Call(
+		deviceNotifyCallback,
+		uintptr(unsafe.Pointer(powerParams)),
+		uintptr(unsafe.Pointer(&handle)),
+	)
+	if ret != 0 {
+		err = &errortypes.ExecError{
+			errors.Wrap(windows.Errno(ret),
+				"watch: Failed to register power notification"),
+		}
+		return
+	}
+
+	select {}
+}
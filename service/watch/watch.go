@@ -221,4 +221,7 @@ func dnsWatch() {
 func StartWatch() {
 	go wakeWatch()
 	go dnsWatch()
+	go networkWatch()
+	go powerWatch()
+	go onDemandWatch()
 }
@@ -0,0 +1,204 @@
+// Delivery of connection lifecycle events to a monitoring webhook.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Connect     = "connect"
+	Disconnect  = "disconnect"
+	AuthFailure = "auth_failure"
+	Reconnect   = "reconnect"
+)
+
+const (
+	queueSize      = 100
+	defaultTimeout = 10 * time.Second
+	defaultRetries = 3
+	retryDelay     = 2 * time.Second
+)
+
+var (
+	queue      = []*Event{}
+	queueLock  = sync.Mutex{}
+	queueWake  = make(chan bool, 1)
+	workerOnce = sync.Once{}
+)
+
+type Event struct {
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	ProfileId string    `json:"profile_id"`
+	Server    string    `json:"server"`
+	Timestamp time.Time `json:"timestamp"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func timeout() time.Duration {
+	if config.Config.EventWebhookTimeout > 0 {
+		return time.Duration(
+			config.Config.EventWebhookTimeout) * time.Second
+	}
+	return defaultTimeout
+}
+
+func retries() int {
+	if config.Config.EventWebhookRetries > 0 {
+		return config.Config.EventWebhookRetries
+	}
+	return defaultRetries
+}
+
+func post(url string, evt *Event) (err error) {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "webhook: Failed to marshal event"),
+		}
+		return
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "webhook: Failed to create request"),
+		}
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pritunl-client")
+
+	client := &http.Client{
+		Timeout: timeout(),
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "webhook: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = &errortypes.RequestError{
+			errors.Newf("webhook: Bad status code %d", resp.StatusCode),
+		}
+		return
+	}
+
+	return
+}
+
+func deliver(evt *Event) {
+	url := config.Config.EventWebhookUrl
+	if url == "" {
+		return
+	}
+
+	attempts := retries() + 1
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * retryDelay)
+		}
+
+		err := post(url, evt)
+		if err == nil {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": evt.ProfileId,
+			"type":       evt.Type,
+			"attempt":    i + 1,
+			"error":      err,
+		}).Warn("webhook: Failed to deliver event")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": evt.ProfileId,
+		"type":       evt.Type,
+	}).Error("webhook: Dropping event after retries exceeded")
+}
+
+func pop() (evt *Event) {
+	queueLock.Lock()
+	if len(queue) > 0 {
+		evt = queue[0]
+		queue = queue[1:]
+	}
+	queueLock.Unlock()
+	return
+}
+
+func worker() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("webhook: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		<-queueWake
+
+		for {
+			evt := pop()
+			if evt == nil {
+				break
+			}
+
+			deliver(evt)
+		}
+	}
+}
+
+func Send(evt *Event) {
+	if config.Config.EventWebhookUrl == "" {
+		return
+	}
+
+	workerOnce.Do(func() {
+		go worker()
+	})
+
+	evt.Id = utils.Uuid()
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now()
+	}
+
+	queueLock.Lock()
+	if len(queue) >= queueSize {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": queue[0].ProfileId,
+			"type":       queue[0].Type,
+		}).Warn("webhook: Queue full, dropping oldest event")
+		queue = queue[1:]
+	}
+	queue = append(queue, evt)
+	queueLock.Unlock()
+
+	select {
+	case queueWake <- true:
+	default:
+	}
+}
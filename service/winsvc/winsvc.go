@@ -8,6 +8,7 @@ import (
 
 type Service struct {
 	quit chan bool
+	stop func()
 }
 
 func (s *Service) Init(env svc.Environment) (err error) {
@@ -19,6 +20,10 @@ func (s *Service) Start() (err error) {
 }
 
 func (s *Service) Stop() (err error) {
+	if s.stop != nil {
+		s.stop()
+	}
+
 	return
 }
 
@@ -34,9 +39,10 @@ func (s *Service) Run() (err error) {
 	return
 }
 
-func New() (service *Service) {
+func New(stop func()) (service *Service) {
 	service = &Service{
 		quit: make(chan bool),
+		stop: stop,
 	}
 
 	return
@@ -11,7 +11,7 @@ export APP_VER="$(cat client/package.json | grep version | cut -d '"' -f 4)"
 # Service
 cd service
 go get
-go build -v
+go build -v -ldflags "-X github.com/pritunl/pritunl-client-electron/service/constants.Commit=$(git rev-parse --short HEAD) -X github.com/pritunl/pritunl-client-electron/service/constants.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
 cd ..
 mkdir -p build/resources
 cp service/service build/resources/pritunl-service
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
+const servicePkg = "github.com/pritunl/pritunl-client-electron/service"
+
 const signtool = "C:\\Program Files (x86)\\Windows Kits\\10\\bin\\10.0.22621.0\\x64\\signtool.exe"
 
 func main() {
@@ -57,7 +62,17 @@ func main() {
 		panic(err)
 	}
 
-	cmd = exec.Command("go", "build", "-v", "-ldflags", "-H windowsgui")
+	commit, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		panic(err)
+	}
+
+	ldflags := fmt.Sprintf("-H windowsgui "+
+		"-X %s/constants.Commit=%s -X %s/constants.BuildDate=%s",
+		servicePkg, strings.TrimSpace(string(commit)),
+		servicePkg, time.Now().UTC().Format(time.RFC3339))
+
+	cmd = exec.Command("go", "build", "-v", "-ldflags", ldflags)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err = cmd.Run()